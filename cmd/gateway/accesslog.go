@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit"
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// readAccessLog lê ACCESS_LOG_*.
+func readAccessLog(r *configReader, cfg *config) {
+	cfg.accessLog = strings.ToLower(strings.TrimSpace(r.string("ACCESS_LOG", "none")))
+	cfg.accessLogFile = r.string("ACCESS_LOG_FILE", "")
+	cfg.accessLogMaxSizeMB = r.int("ACCESS_LOG_MAX_SIZE_MB", 100)
+	cfg.accessLogMaxBackups = r.int("ACCESS_LOG_MAX_BACKUPS", 5)
+	cfg.accessLogSampleRatio = r.float("ACCESS_LOG_SAMPLE_RATIO", 1)
+	cfg.accessLogLevel = readLevel(r, "ACCESS_LOG_LEVEL", slog.LevelInfo)
+	cfg.accessLogRejectedLevel = readLevel(r, "ACCESS_LOG_REJECTED_LEVEL", slog.LevelWarn)
+
+	switch cfg.accessLog {
+	case "", "none", "stdout":
+	case "file":
+		if strings.TrimSpace(cfg.accessLogFile) == "" {
+			r.failf("ACCESS_LOG_FILE is required when ACCESS_LOG=file")
+		}
+	default:
+		r.failf("ACCESS_LOG: %q is not supported (use none, stdout or file)", cfg.accessLog)
+	}
+	if cfg.accessLogMaxSizeMB <= 0 {
+		r.failf("ACCESS_LOG_MAX_SIZE_MB must be > 0")
+	}
+	if cfg.accessLogMaxBackups < 0 {
+		r.failf("ACCESS_LOG_MAX_BACKUPS must be >= 0")
+	}
+	if cfg.accessLogSampleRatio < 0 || cfg.accessLogSampleRatio > 1 {
+		r.failf("ACCESS_LOG_SAMPLE_RATIO must be between 0 and 1")
+	}
+}
+
+func readLevel(r *configReader, key string, def slog.Level) slog.Level {
+	v := r.string(key, "")
+	if v == "" {
+		return def
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(v)); err != nil {
+		r.failf("%s: %q is not a level (use debug, info, warn or error)", key, v)
+		return def
+	}
+	return l
+}
+
+func (cfg config) accessLogEnabled() bool {
+	return cfg.accessLog != "" && cfg.accessLog != "none"
+}
+
+// openAccessLog abre a saída de ACCESS_LOG.
+func openAccessLog(cfg config) (io.WriteCloser, error) {
+	switch cfg.accessLog {
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "file":
+		return openRotatingFile(cfg.accessLogFile, int64(cfg.accessLogMaxSizeMB)<<20, cfg.accessLogMaxBackups)
+	}
+	return nil, fmt.Errorf("access log is disabled")
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// accessLogger escreve uma linha JSON (log/slog) por requisição.
+//
+// Requisições recusadas pelos middlewares (rate limit, listas de acesso,
+// concorrência) saem em rejectedLevel e erros do upstream (5xx) em Error;
+// as demais saem em Info, amostradas por sampleRatio. Recusas e erros nunca
+// são descartados pela amostragem.
+type accessLogger struct {
+	log           *slog.Logger
+	sampleRatio   float64
+	rejectedLevel slog.Level
+	upstream      string
+	proxies       *ratelimit.TrustedProxies
+	sample        func() float64
+}
+
+func newAccessLogger(cfg config, w io.Writer, upstream string) *accessLogger {
+	return &accessLogger{
+		log:           slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: cfg.accessLogLevel})),
+		sampleRatio:   cfg.accessLogSampleRatio,
+		rejectedLevel: cfg.accessLogRejectedLevel,
+		upstream:      upstream,
+		proxies:       cfg.forwardingProxies(),
+		sample:        rand.Float64,
+	}
+}
+
+func (a *accessLogger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, info := ratelimit.WithRequestInfo(r.Context())
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		a.write(r, sw, info, time.Since(start))
+	})
+}
+
+func (a *accessLogger) write(r *http.Request, sw *statusWriter, info *ratelimit.RequestInfo, elapsed time.Duration) {
+	status := sw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	level := slog.LevelInfo
+	switch {
+	case info.Outcome == ratelimit.OutcomeRejected || info.Outcome == domain.OutcomeDenylisted || info.Outcome == domain.OutcomeConcurrency:
+		level = a.rejectedLevel
+	case status >= 500:
+		level = slog.LevelError
+	case a.sampleRatio < 1 && a.sample() >= a.sampleRatio:
+		return
+	}
+	ctx := r.Context()
+	if !a.log.Enabled(ctx, level) {
+		return
+	}
+
+	key := info.Key
+	if key == "" {
+		key = ratelimit.ClientIP(r, a.proxies)
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int64("bytes", sw.bytes),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+		slog.String("client_key", key),
+		slog.String("outcome", info.Outcome),
+		slog.Float64("concurrency_wait_ms", float64(info.ConcurrencyWait.Microseconds())/1000),
+		slog.String("upstream", a.upstream),
+	}
+	for _, kv := range [][2]string{{"reason", info.Reason}, {"policy", info.Policy}, {"limit", info.Limit}} {
+		if kv[1] != "" {
+			attrs = append(attrs, slog.String(kv[0], kv[1]))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+	a.log.LogAttrs(context.WithoutCancel(ctx), level, "access", attrs...)
+}
+
+// rotatingFile é um arquivo que, ao passar de maxSize bytes, vira file.1
+// (file.1 vira file.2 e assim por diante, mantendo até backups cópias).
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	rf.f, rf.size = f, st.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	if rf.backups == 0 {
+		_ = os.Remove(rf.path)
+	} else {
+		_ = os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.backups))
+		for i := rf.backups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+			// continua no arquivo atual: a próxima escrita tenta de novo
+			return errors.Join(err, rf.open())
+		}
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
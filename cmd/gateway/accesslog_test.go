@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func TestAccessLogger_LogsOutcomeAndSamplesSuccesses(t *testing.T) {
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":            "http://up",
+		"RATE_RPS":                "0.01",
+		"RATE_BURST":              "2",
+		"ACCESS_LOG":              "stdout",
+		"ACCESS_LOG_SAMPLE_RATIO": "0.5",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("hello")) })
+	gw := newGateway(upstream, infra.NewStore(cfg.rateRPS, cfg.rateBurst), nil)
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	al := newAccessLogger(cfg, &buf, "up:80")
+	samples := []float64{0.1, 0.9}
+	al.sample = func() float64 {
+		v := samples[0]
+		samples = samples[1:]
+		return v
+	}
+	h := al.middleware(gw)
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://example/items", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", l, err)
+		}
+		lines = append(lines, m)
+	}
+	// 1ª atendida entra na amostra, 2ª fica de fora, a recusa sempre sai
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %s", len(lines), buf.String())
+	}
+	ok, limited := lines[0], lines[1]
+	if ok["level"] != "INFO" || ok["status"].(float64) != 200 || ok["bytes"].(float64) != 5 ||
+		ok["outcome"] != "allowed" || ok["client_key"] != "10.0.0.1" || ok["upstream"] != "up:80" || ok["policy"] != "default" {
+		t.Fatalf("unexpected success line: %v", ok)
+	}
+	if limited["level"] != "WARN" || limited["status"].(float64) != 429 || limited["outcome"] != "rejected" || limited["reason"] != "rate_limited" {
+		t.Fatalf("unexpected rate limited line: %v", limited)
+	}
+	if _, ok := limited["concurrency_wait_ms"]; !ok {
+		t.Fatalf("expected concurrency wait in the line: %v", limited)
+	}
+}
+
+func TestRotatingFile_KeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = rf.Close()
+
+	for file, want := range map[string]string{path: "dddddddd\n", path + ".1": "cccccccc\n", path + ".2": "bbbbbbbb\n"} {
+		b, _ := os.ReadFile(file)
+		if string(b) != want {
+			t.Fatalf("%s: expected %q, got %q", filepath.Base(file), want, b)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 backups")
+	}
+}
+
+func TestRotatingFile_ReopensWhenRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	// um diretório não vazio em access.log.1 faz o rename falhar
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("aaaaaaaa\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("bbbbbbbb\n")); err == nil {
+		t.Fatal("expected the failed rotation to be reported")
+	}
+
+	// com o caminho livre, o mesmo arquivo continua gravando e roda
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("cccccccc\n")); err != nil {
+		t.Fatalf("expected writes to resume after the failed rotation, got %v", err)
+	}
+	for file, want := range map[string]string{path: "cccccccc\n", path + ".1": "aaaaaaaa\n"} {
+		b, _ := os.ReadFile(file)
+		if string(b) != want {
+			t.Fatalf("%s: expected %q, got %q", filepath.Base(file), want, b)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/application"
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// Limites da listagem de chaves (GET /keys?limit=).
+const (
+	adminDefaultKeys = 100
+	adminMaxKeys     = 1000
+)
+
+// adminDefaultExempt é a duração de uma isenção sem ?ttl=.
+const adminDefaultExempt = 15 * time.Minute
+
+// adminHandler é a API admin (ADMIN_ADDR), em JSON:
+//
+//	GET    /keys?contains=&limit=         chaves com estado no store do rate limit
+//	GET    /keys/{key}                    saldo, último acesso, bloqueio e isenção
+//	DELETE /keys/{key}                    zera o bucket e o bloqueio da chave
+//	PUT    /exemptions/{key}?ttl=         isenta a chave do rate limit (padrão 15m)
+//	DELETE /exemptions/{key}              remove a isenção
+//	GET    /concurrency                   ocupação do pool de concorrência
+//	GET    /stats                         totais de RATE_STATS_*
+//	GET    /stats/series?from=&to=&step=  série por minuto (padrão: última hora)
+//	GET    /stats/routes?limit=           rotas com mais requisições
+//	GET    /stats/keys/denied?limit=      chaves com mais negações
+//
+// Opera no mesmo store do rate limit (memória: só esta réplica; Redis: todas);
+// /stats lê o Redis de RATE_STATS_* e responde 501 se ele estiver desligado.
+// Todas as rotas exigem "Authorization: Bearer <ADMIN_TOKEN>".
+func (g *gateway) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", g.adminListKeys)
+	mux.HandleFunc("GET /keys/{key...}", g.adminGetKey)
+	mux.HandleFunc("DELETE /keys/{key...}", g.adminResetKey)
+	mux.HandleFunc("PUT /exemptions/{key...}", g.adminExempt)
+	mux.HandleFunc("DELETE /exemptions/{key...}", g.adminUnexempt)
+	mux.HandleFunc("GET /concurrency", g.adminConcurrency)
+	mux.HandleFunc("GET /stats", g.adminStatsTotals)
+	mux.HandleFunc("GET /stats/series", g.adminStatsSeries)
+	mux.HandleFunc("GET /stats/routes", g.adminStatsRoutes)
+	mux.HandleFunc("GET /stats/keys/denied", g.adminStatsDeniedKeys)
+	return g.adminAuth(mux)
+}
+
+// adminAuth exige o ADMIN_TOKEN atual (recarregável no hot reload).
+func (g *gateway) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := g.config().adminToken
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || want == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(want)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			adminError(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type adminKeyState struct {
+	Key string `json:"key"`
+	// Tokens e Limit ficam de fora quando a política da chave depende da
+	// credencial (JWT, API key) e o store não a guarda (Redis).
+	Tokens     *float64   `json:"tokens,omitempty"`
+	Limit      *int       `json:"limit,omitempty"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	BlockedFor int        `json:"blocked_seconds"`
+	ExemptFor  int        `json:"exempt_seconds"`
+	HasState   bool       `json:"has_state"`
+}
+
+func (g *gateway) adminListKeys(w http.ResponseWriter, r *http.Request) {
+	is, ok := g.store.(domain.InspectableStore)
+	if !ok {
+		adminError(w, http.StatusNotImplemented, "rate limit store does not support inspection")
+		return
+	}
+	limit := adminDefaultKeys
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			adminError(w, http.StatusBadRequest, "limit must be an integer > 0")
+			return
+		}
+		limit = min(n, adminMaxKeys)
+	}
+
+	keys, err := is.Keys(r.Context(), r.URL.Query().Get("contains"), limit)
+	if err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = string(k)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": out, "truncated": len(out) >= limit})
+}
+
+func (g *gateway) adminGetKey(w http.ResponseWriter, r *http.Request) {
+	is, ok := g.store.(domain.InspectableStore)
+	if !ok {
+		adminError(w, http.StatusNotImplemented, "rate limit store does not support inspection")
+		return
+	}
+	key := domain.Key(r.PathValue("key"))
+	var (
+		st      domain.KeyState
+		found   bool
+		err     error
+		balance = true
+	)
+	if pi, ok := g.store.(domain.PolicyInspector); ok {
+		// o Redis não guarda a política da chave: calcula com a dela
+		p, known := g.keyPolicy(key)
+		st, found, err = pi.InspectWithPolicy(r.Context(), key, p)
+		balance = known
+	} else {
+		st, found, err = is.Inspect(r.Context(), key)
+	}
+	if err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	var exempt time.Duration
+	if es, ok := g.store.(domain.ExemptionStore); ok {
+		exempt = es.ExemptFor(exemptionKey(key))
+	}
+	if !found && exempt <= 0 {
+		adminError(w, http.StatusNotFound, "key has no rate limit state")
+		return
+	}
+
+	out := adminKeyState{
+		Key:        string(key),
+		BlockedFor: ceilSeconds(st.BlockedFor),
+		ExemptFor:  ceilSeconds(exempt),
+		HasState:   found,
+	}
+	if balance {
+		out.Tokens, out.Limit = &st.Tokens, &st.Limit
+	}
+	if !st.LastSeen.IsZero() {
+		out.LastSeen = &st.LastSeen
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (g *gateway) adminResetKey(w http.ResponseWriter, r *http.Request) {
+	is, ok := g.store.(domain.InspectableStore)
+	if !ok {
+		adminError(w, http.StatusNotImplemented, "rate limit store does not support reset")
+		return
+	}
+	if err := is.Reset(r.Context(), domain.Key(r.PathValue("key"))); err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *gateway) adminExempt(w http.ResponseWriter, r *http.Request) {
+	es, ok := g.store.(domain.ExemptionStore)
+	if !ok {
+		adminError(w, http.StatusNotImplemented, "rate limit store does not support exemptions")
+		return
+	}
+	ttl := adminDefaultExempt
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			adminError(w, http.StatusBadRequest, "ttl must be a positive duration (ex: 30m)")
+			return
+		}
+		ttl = d
+	}
+	key := exemptionKey(domain.Key(r.PathValue("key")))
+	es.Exempt(key, ttl)
+	writeJSON(w, http.StatusOK, map[string]any{"key": string(key), "exempt_seconds": ceilSeconds(ttl)})
+}
+
+func (g *gateway) adminUnexempt(w http.ResponseWriter, r *http.Request) {
+	es, ok := g.store.(domain.ExemptionStore)
+	if !ok {
+		adminError(w, http.StatusNotImplemented, "rate limit store does not support exemptions")
+		return
+	}
+	es.Exempt(exemptionKey(domain.Key(r.PathValue("key"))), 0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// exemptionKey é a chave do cliente de um bucket listado em /keys: o
+// Middleware consulta as isenções com a chave sem o scope da regra
+// ("rule:<regra>:<chave>"), e a isenção vale para todos os limites.
+func exemptionKey(k domain.Key) domain.Key {
+	_, raw, _ := application.SplitScopedKey(k)
+	return raw
+}
+
+// keyPolicy resolve a política com que o Middleware limita o bucket key:
+// a da regra (bucket "rule:<regra>:<chave>") ou a padrão, trocada pela do
+// token se houver. ok=false quando ela depende da credencial (identidade de
+// JWT ou API key) ou de um limite extra, que a API admin não conhece.
+func (g *gateway) keyPolicy(key domain.Key) (p domain.Policy, ok bool) {
+	g.mu.Lock()
+	cfg, rules, tokens := g.cfg, g.rules, g.tokens
+	g.mu.Unlock()
+
+	p = domain.Policy{RPS: cfg.rateRPS, Burst: cfg.rateBurst}
+	scope, raw, scoped := application.SplitScopedKey(key)
+	if scoped {
+		if p, ok = rules.Policy(scope); !ok {
+			return p, false
+		}
+	}
+	switch {
+	case strings.HasPrefix(string(raw), domain.HeaderKeyPrefix):
+		if tokens != nil {
+			if tp, ok := tokens.Resolve(raw); ok {
+				return tp, true
+			}
+		}
+		return p, true
+	case isIPKey(string(raw)):
+		return p, true
+	default:
+		return p, false
+	}
+}
+
+// isIPKey informa se k é um IP ou uma sub-rede agregada (RATE_IPV4_PREFIX).
+func isIPKey(k string) bool {
+	if _, err := netip.ParseAddr(k); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(k)
+	return err == nil
+}
+
+func (g *gateway) adminConcurrency(w http.ResponseWriter, _ *http.Request) {
+	g.mu.Lock()
+	pool := g.pool
+	g.mu.Unlock()
+
+	out := map[string]any{"enabled": pool != nil}
+	if pi, ok := pool.(domain.SlotPoolInspector); ok {
+		u := pi.Usage()
+		out["capacity"], out["in_use"], out["waiting"] = u.Capacity, u.InUse, u.Waiting
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func adminError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// ceilSeconds arredonda para cima, como o Retry-After.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+// Limites dos rankings (GET /stats/routes e /stats/keys/denied?limit=).
+const (
+	adminDefaultTop = 10
+	adminMaxTop     = 1000
+)
+
+// adminDefaultSeries é o intervalo de GET /stats/series sem ?from=.
+const adminDefaultSeries = time.Hour
+
+type adminCounts struct {
+	Allowed int64 `json:"allowed"`
+	Denied  int64 `json:"denied"`
+}
+
+func countsJSON(c domain.StatsCounts) adminCounts {
+	return adminCounts{Allowed: c.Allowed, Denied: c.Denied}
+}
+
+type adminPoint struct {
+	At time.Time `json:"at"`
+	adminCounts
+}
+
+type adminRoute struct {
+	Route string `json:"route"`
+	adminCounts
+}
+
+type adminKey struct {
+	Key string `json:"key"`
+	adminCounts
+}
+
+// statsReaderOr501 devolve o leitor de RATE_STATS_* ou responde 501.
+func (g *gateway) statsReaderOr501(w http.ResponseWriter) domain.StatsReader {
+	if g.statsReader == nil {
+		adminError(w, http.StatusNotImplemented, "stats are disabled (RATE_STATS_ENABLED)")
+	}
+	return g.statsReader
+}
+
+func (g *gateway) adminStatsTotals(w http.ResponseWriter, r *http.Request) {
+	sr := g.statsReaderOr501(w)
+	if sr == nil {
+		return
+	}
+	c, err := sr.Totals(r.Context())
+	if err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, countsJSON(c))
+}
+
+// adminStatsSeries devolve a série de [from, to) somada a cada step (múltiplo
+// de 1m). from e to aceitam RFC 3339 ou uma duração relativa a agora (-2h).
+func (g *gateway) adminStatsSeries(w http.ResponseWriter, r *http.Request) {
+	sr := g.statsReaderOr501(w)
+	if sr == nil {
+		return
+	}
+	now := time.Now()
+	q := r.URL.Query()
+	from, err := parseStatsTime(q.Get("from"), now, now.Add(-adminDefaultSeries))
+	if err != nil {
+		adminError(w, http.StatusBadRequest, "from: "+err.Error())
+		return
+	}
+	to, err := parseStatsTime(q.Get("to"), now, now)
+	if err != nil {
+		adminError(w, http.StatusBadRequest, "to: "+err.Error())
+		return
+	}
+	if !to.After(from) {
+		adminError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	step := time.Minute
+	if v := q.Get("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d%time.Minute != 0 {
+			adminError(w, http.StatusBadRequest, "step must be a multiple of 1m (ex: 5m)")
+			return
+		}
+		step = d
+	}
+
+	points, err := sr.Series(r.Context(), from, to)
+	switch {
+	case errors.Is(err, infra.ErrNoSeries):
+		adminError(w, http.StatusNotImplemented, "time series is disabled (RATE_STATS_BUCKET=none)")
+		return
+	case errors.Is(err, infra.ErrSeriesRange):
+		adminError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	out := []adminPoint{}
+	per := int(step / time.Minute)
+	for i, p := range points {
+		if i%per == 0 {
+			out = append(out, adminPoint{At: p.At})
+		}
+		last := &out[len(out)-1]
+		last.Allowed += p.Allowed
+		last.Denied += p.Denied
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"step_seconds": int(step.Seconds()), "points": out})
+}
+
+func (g *gateway) adminStatsRoutes(w http.ResponseWriter, r *http.Request) {
+	sr := g.statsReaderOr501(w)
+	if sr == nil {
+		return
+	}
+	n, ok := topLimit(w, r)
+	if !ok {
+		return
+	}
+	routes, err := sr.TopRoutes(r.Context(), n)
+	if err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	out := make([]adminRoute, len(routes))
+	for i, rs := range routes {
+		out[i] = adminRoute{Route: rs.Route, adminCounts: countsJSON(rs.StatsCounts)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"routes": out})
+}
+
+func (g *gateway) adminStatsDeniedKeys(w http.ResponseWriter, r *http.Request) {
+	sr := g.statsReaderOr501(w)
+	if sr == nil {
+		return
+	}
+	n, ok := topLimit(w, r)
+	if !ok {
+		return
+	}
+	keys, err := sr.TopDeniedKeys(r.Context(), n)
+	if err != nil {
+		adminError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	out := make([]adminKey, len(keys))
+	for i, ks := range keys {
+		out[i] = adminKey{Key: string(ks.Key), adminCounts: countsJSON(ks.StatsCounts)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": out})
+}
+
+func topLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return adminDefaultTop, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		adminError(w, http.StatusBadRequest, "limit must be an integer > 0")
+		return 0, false
+	}
+	return min(n, adminMaxTop), true
+}
+
+// parseStatsTime lê um instante RFC 3339 ou uma duração relativa a now
+// ("-90m"); vazio devolve def.
+func parseStatsTime(v string, now, def time.Time) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, errors.New("use RFC 3339 or a duration relative to now (ex: -2h)")
+	}
+	return now.Add(d), nil
+}
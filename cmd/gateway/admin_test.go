@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func TestAdmin_InspectResetAndExempt(t *testing.T) {
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":    "http://up",
+		"RATE_RPS":        "0.01",
+		"RATE_BURST":      "1",
+		"CONCURRENCY_MAX": "4",
+		"ADMIN_ADDR":      "127.0.0.1:0",
+		"ADMIN_TOKEN":     "adm1n",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gw := newGateway(http.NotFoundHandler(), infra.NewStore(cfg.rateRPS, cfg.rateBurst), nil)
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	admin := gw.adminHandler()
+
+	proxied := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w.Code
+	}
+	call := func(method, target, token string) (int, map[string]any) {
+		r := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		var body map[string]any
+		_ = json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+
+	if code, _ := call(http.MethodGet, "/keys", ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+	if code, _ := call(http.MethodGet, "/keys", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", code)
+	}
+
+	proxied()
+	if code := proxied(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected client to be limited, got %d", code)
+	}
+
+	code, body := call(http.MethodGet, "/keys?contains=10.0", "adm1n")
+	if code != http.StatusOK || len(body["keys"].([]any)) != 1 {
+		t.Fatalf("expected the limited key listed, got %d %v", code, body)
+	}
+	code, body = call(http.MethodGet, "/keys/10.0.0.1", "adm1n")
+	if code != http.StatusOK || body["tokens"].(float64) >= 1 || body["last_seen"] == nil {
+		t.Fatalf("expected empty bucket for the key, got %d %v", code, body)
+	}
+	if code, _ := call(http.MethodGet, "/keys/10.9.9.9", "adm1n"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown key, got %d", code)
+	}
+
+	if code, _ := call(http.MethodDelete, "/keys/10.0.0.1", "adm1n"); code != http.StatusNoContent {
+		t.Fatalf("expected 204 on reset, got %d", code)
+	}
+	if code := proxied(); code != http.StatusNotFound {
+		t.Fatalf("expected reset key to pass again, got %d", code)
+	}
+
+	if code, body := call(http.MethodPut, "/exemptions/10.0.0.1?ttl=1m", "adm1n"); code != http.StatusOK || body["exempt_seconds"].(float64) != 60 {
+		t.Fatalf("expected exemption, got %d %v", code, body)
+	}
+	for i := 0; i < 3; i++ {
+		if code := proxied(); code != http.StatusNotFound {
+			t.Fatalf("request %d: expected exempt key to bypass the limit, got %d", i+1, code)
+		}
+	}
+	if code, _ := call(http.MethodPut, "/exemptions/10.0.0.1?ttl=soon", "adm1n"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid ttl, got %d", code)
+	}
+	call(http.MethodDelete, "/exemptions/10.0.0.1", "adm1n")
+	if code := proxied(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected limit back after removing the exemption, got %d", code)
+	}
+
+	code, body = call(http.MethodGet, "/concurrency", "adm1n")
+	if code != http.StatusOK || body["capacity"].(float64) != 4 || body["in_use"].(float64) != 0 {
+		t.Fatalf("unexpected concurrency usage: %d %v", code, body)
+	}
+
+	if _, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL": "http://up",
+		"ADMIN_ADDR":   ":9090",
+	}, nil)); err == nil || !strings.Contains(err.Error(), "ADMIN_TOKEN") {
+		t.Fatalf("expected ADMIN_TOKEN to be required, got %v", err)
+	}
+}
+
+func TestAdmin_Stats(t *testing.T) {
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL": "http://up",
+		"RATE_RPS":     "0.01",
+		"RATE_BURST":   "1",
+		"ADMIN_ADDR":   "127.0.0.1:0",
+		"ADMIN_TOKEN":  "adm1n",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	call := func(gw *gateway, target string) (int, map[string]any) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer adm1n")
+		w := httptest.NewRecorder()
+		gw.adminHandler().ServeHTTP(w, r)
+		var body map[string]any
+		_ = json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+
+	disabled := newGateway(http.NotFoundHandler(), infra.NewStore(cfg.rateRPS, cfg.rateBurst), nil)
+	if err := disabled.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := call(disabled, "/stats"); code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without stats, got %d", code)
+	}
+
+	stats := infra.NewMemoryStatsStore(infra.WithTrackKeys(true))
+	gw := newGateway(http.NotFoundHandler(), infra.NewStore(cfg.rateRPS, cfg.rateBurst), stats)
+	gw.statsReader = stats
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.1", "10.0.0.1", "10.0.0.2"} {
+		r := httptest.NewRequest(http.MethodGet, "http://example/a", nil)
+		r.RemoteAddr = ip + ":1234"
+		gw.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	code, body := call(gw, "/stats")
+	if code != http.StatusOK || body["allowed"].(float64) != 2 || body["denied"].(float64) != 2 {
+		t.Fatalf("expected 2 allowed and 2 denied, got %d %v", code, body)
+	}
+
+	code, body = call(gw, "/stats/series?from=-10m&step=5m")
+	points, _ := body["points"].([]any)
+	if code != http.StatusOK || len(points) < 2 || body["step_seconds"].(float64) != 300 {
+		t.Fatalf("expected points of 5 minutes, got %d %v", code, body)
+	}
+	if last := points[len(points)-1].(map[string]any); last["allowed"].(float64) != 2 || last["denied"].(float64) != 2 {
+		t.Fatalf("expected the current point to hold all requests, got %v", last)
+	}
+	for _, q := range []string{"from=yesterday", "step=90s", "from=-1h&to=-2h", "from=-192h"} {
+		if code, _ := call(gw, "/stats/series?"+q); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, code)
+		}
+	}
+
+	code, body = call(gw, "/stats/routes?limit=5")
+	routes, _ := body["routes"].([]any)
+	if code != http.StatusOK || len(routes) != 1 || routes[0].(map[string]any)["route"] != "GET /a" {
+		t.Fatalf("expected GET /a as the only route, got %d %v", code, body)
+	}
+
+	code, body = call(gw, "/stats/keys/denied")
+	keys, _ := body["keys"].([]any)
+	if code != http.StatusOK || len(keys) != 1 || keys[0].(map[string]any)["key"] != "10.0.0.1" {
+		t.Fatalf("expected 10.0.0.1 as the only denied key, got %d %v", code, body)
+	}
+	if code, _ := call(gw, "/stats/keys/denied?limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit=0, got %d", code)
+	}
+}
+
+func TestAdmin_RuleScopedKeyOnRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	policy := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(policy, []byte("rules:\n  - name: api\n    path: /\n    key: ip\n    rps: 0.01\n    burst: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":     "http://up",
+		"RATE_STORE":       "redis",
+		"RATE_REDIS_ADDR":  mr.Addr(),
+		"RATE_RPS":         "0.01",
+		"RATE_BURST":       "10",
+		"RATE_POLICY_FILE": policy,
+		"ADMIN_ADDR":       "127.0.0.1:0",
+		"ADMIN_TOKEN":      "adm1n",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gw := newGateway(http.NotFoundHandler(), newRedisStore(rdb, cfg), nil)
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	admin := gw.adminHandler()
+
+	proxied := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w.Code
+	}
+	call := func(method, target string) (int, map[string]any) {
+		r := httptest.NewRequest(method, target, nil)
+		r.Header.Set("Authorization", "Bearer adm1n")
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		var body map[string]any
+		_ = json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+
+	proxied()
+	proxied()
+	if code := proxied(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected client to be limited by the rule, got %d", code)
+	}
+
+	code, body := call(http.MethodGet, "/keys")
+	keys, _ := body["keys"].([]any)
+	if code != http.StatusOK || len(keys) != 1 || keys[0] != "rule:api:10.0.0.1" {
+		t.Fatalf("expected the rule-scoped key listed, got %d %v", code, body)
+	}
+	key := keys[0].(string)
+
+	// o saldo vem da política da regra (burst 2), não do padrão do store
+	code, body = call(http.MethodGet, "/keys/"+key)
+	if code != http.StatusOK || body["limit"] != float64(2) || body["tokens"].(float64) >= 1 {
+		t.Fatalf("expected the rule policy in the key state, got %d %v", code, body)
+	}
+
+	if code, body := call(http.MethodPut, "/exemptions/"+key+"?ttl=1m"); code != http.StatusOK || body["key"] != "10.0.0.1" {
+		t.Fatalf("expected exemption of the client key, got %d %v", code, body)
+	}
+	if code := proxied(); code != http.StatusNotFound {
+		t.Fatalf("expected exempt client to bypass the rule, got %d", code)
+	}
+	if _, body := call(http.MethodGet, "/keys/"+key); body["exempt_seconds"] != float64(60) {
+		t.Fatalf("expected the exemption in the key state, got %v", body)
+	}
+	call(http.MethodDelete, "/exemptions/"+key)
+	if code := proxied(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected limit back after removing the exemption, got %d", code)
+	}
+
+	// a identidade de um JWT/API key tem política que o admin não conhece
+	if !gw.store.Get(domain.Key("sub:alice")).Allow() {
+		t.Fatal("expected first identity request to pass")
+	}
+	code, body = call(http.MethodGet, "/keys/sub:alice")
+	if _, ok := body["tokens"]; code != http.StatusOK || ok {
+		t.Fatalf("expected tokens omitted for an identity key, got %d %v", code, body)
+	}
+}
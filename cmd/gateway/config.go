@@ -0,0 +1,553 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit"
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+type config struct {
+	listenAddr    string
+	upstreamURL   string
+	rateEnabled   bool
+	rateRPS       float64
+	rateBurst     int
+	rateKeyHeader string
+	trustXFF      bool
+	// trustedProxies: proxies cujos headers de encaminhamento valem (obrigatório com TRUST_XFF).
+	trustedProxies *ratelimit.TrustedProxies
+	// ipAggregation: tamanho do prefixo usado como chave (RATE_IPV4_PREFIX/RATE_IPV6_PREFIX).
+	ipAggregation ratelimit.IPAggregation
+	retryAfter    time.Duration
+	blockDuration time.Duration
+	addHeaders    bool
+	// rateHeaders: headers de cota enviados ao cliente (none, legacy, ietf ou both).
+	rateHeaders        ratelimit.HeaderMode
+	exposeKey          bool
+	concurrencyMax     int
+	concurrencyTimeout time.Duration
+
+	// globalRPS/globalBurst: limite único somando todos os clientes
+	// (GLOBAL_RATE_RPS/GLOBAL_RATE_BURST, 0 = desligado), no mesmo backend do
+	// RATE_STORE.
+	globalRPS   float64
+	globalBurst int
+
+	// Respostas de rejeição (429/503): formato text, json (problem+json) ou template.
+	rejectFormat             string
+	rejectMessage            string
+	rejectTemplatePath       string
+	rejectTemplate           *template.Template
+	rejectContentType        string
+	concurrencyRejectMessage string
+
+	// tokenPolicies: limites por token (RATE_TOKENS_FILE + RATE_TOKENS).
+	tokenPolicies  map[string]domain.Policy
+	tokensFilePath string
+
+	// jwt: identifica o cliente por uma claim de JWT (RATE_JWT_*), com tiers
+	// por plano; nil se desligado.
+	jwt          *ratelimit.JWTIdentifier
+	jwtJWKSPath  string
+	jwtRejectBad bool
+
+	// apiKeys: registro de API keys (RATE_API_KEYS=file|redis); só keys ativas
+	// ganham limite próprio e cota diária.
+	apiKeysBackend     string
+	apiKeysFilePath    string
+	apiKeysFile        *infra.StaticAPIKeys
+	apiKeysHeader      string
+	apiKeysReject      bool
+	apiKeysRedisPrefix string
+	apiKeysCacheTTL    time.Duration
+
+	// quotas: cotas por cliente em janelas de calendário UTC (RATE_QUOTAS,
+	// ex: hour=1000,day=10000), na chave do limite principal e no backend do
+	// RATE_STORE. Esgotadas, respondem com quotaRejectStatus até a virada.
+	quotas             map[domain.QuotaPeriod]int
+	quotaRejectStatus  int
+	quotaRejectMessage string
+
+	// policyFile: regras por rota (RATE_POLICY_FILE), avaliadas antes do limite global.
+	policyFilePath string
+	policyFile     ratelimit.PolicyFile
+
+	// accessFile: allowlist/denylist de IPs, CIDRs e keys (RATE_ACCESS_FILE),
+	// avaliadas antes de qualquer limite.
+	accessFilePath string
+	accessFile     ratelimit.AccessFile
+
+	// rateAlgorithm: token_bucket (padrão), gcra, fixed_window, sliding_window ou
+	// sliding_window_log. Nos algoritmos de janela o limite é RATE_RPS × RATE_WINDOW
+	// requisições por janela.
+	rateAlgorithm infra.Algorithm
+	rateWindow    time.Duration
+
+	rateStore         string
+	rateRedisAddr     string
+	rateRedisPassword string
+	rateRedisDB       int
+	rateRedisPrefix   string
+	rateRedisFailOpen bool
+
+	rateStatsEnabled       bool
+	rateStatsRedisAddr     string
+	rateStatsRedisPassword string
+	rateStatsRedisDB       int
+	rateStatsPrefix        string
+	rateStatsTTL           time.Duration
+	rateStatsBucket        string
+	rateStatsTrackKeys     bool
+	// rateStatsAsync grava as estatísticas em lotes, fora da requisição
+	// (infra.BufferedStatsStore).
+	rateStatsAsync         bool
+	rateStatsFlushInterval time.Duration
+	rateStatsBatchSize     int
+	rateStatsQueueSize     int
+
+	// adminAddr: listener da API admin (ADMIN_ADDR, vazio = desligada),
+	// protegida por adminToken (ADMIN_TOKEN, Bearer).
+	adminAddr  string
+	adminToken string
+
+	// metricsAddr: listener do /metrics no formato do Prometheus
+	// (METRICS_ADDR, vazio = desligado). metricsMaxRoutes limita as rotas
+	// distintas e metricsTrackKeys liga a série por chave (cardinalidade).
+	metricsAddr      string
+	metricsMaxRoutes int
+	metricsTrackKeys bool
+
+	// trace*: tracing OpenTelemetry (TRACE_EXPORTER=otlp|stdout|file, vazio ou
+	// none = desligado), com traceparent W3C de entrada e para o upstream.
+	traceExporter     string
+	traceOTLPEndpoint string
+	traceFile         string
+	traceSampleRatio  float64
+	traceServiceName  string
+
+	// accessLog*: log de acesso em JSON (ACCESS_LOG=stdout|file, vazio ou
+	// none = desligado), com rotação por tamanho no arquivo e amostragem das
+	// requisições atendidas.
+	accessLog              string
+	accessLogFile          string
+	accessLogMaxSizeMB     int
+	accessLogMaxBackups    int
+	accessLogSampleRatio   float64
+	accessLogLevel         slog.Level
+	accessLogRejectedLevel slog.Level
+
+	// watchInterval: intervalo de verificação dos arquivos para hot reload (0 = só SIGHUP).
+	watchInterval time.Duration
+}
+
+// loadConfig lê a configuração do gateway a partir de r.
+// Todos os valores inválidos e violações de regra são reportados juntos.
+func loadConfig(r *configReader) (config, error) {
+	cfg := config{}
+	cfg.listenAddr = r.string("LISTEN_ADDR", ":8080")
+	cfg.upstreamURL = r.string("UPSTREAM_URL", "")
+	cfg.rateEnabled = r.bool("RATE_ENABLED", true)
+	cfg.rateRPS = r.float("RATE_RPS", 10)
+	// IMPORTANTE: o "burst" permite uma rajada inicial de requisições.
+	// Com RPS muito baixo (ex: 0.02), o padrão 20 pode dar a impressão de que
+	// o limiter não está funcionando, porque as primeiras ~20 passam.
+	defBurst := 20
+	if r.isSet("RATE_RPS") && cfg.rateRPS > 0 && cfg.rateRPS < 1 {
+		defBurst = 1
+	}
+	cfg.rateBurst = r.int("RATE_BURST", defBurst)
+	cfg.rateKeyHeader = r.string("RATE_KEY_HEADER", "")
+	cfg.tokensFilePath = r.string("RATE_TOKENS_FILE", "")
+	cfg.tokenPolicies = readTokenPolicies(r, cfg.tokensFilePath)
+	// O desafio define o header "API_KEY: <TOKEN>"; se há tokens configurados
+	// e nenhum header explícito, usa esse.
+	if cfg.rateKeyHeader == "" && len(cfg.tokenPolicies) > 0 {
+		cfg.rateKeyHeader = "API_KEY"
+	}
+	cfg.jwtJWKSPath = r.string("RATE_JWT_JWKS_FILE", "")
+	cfg.jwt, cfg.jwtRejectBad = readJWT(r, cfg.jwtJWKSPath)
+	readAPIKeys(r, &cfg)
+	readQuotas(r, &cfg)
+	cfg.policyFilePath = r.string("RATE_POLICY_FILE", "")
+	if cfg.policyFilePath != "" {
+		pf, err := ratelimit.LoadPolicyFile(cfg.policyFilePath)
+		if err != nil {
+			r.failf("RATE_POLICY_FILE: %v", err)
+		}
+		cfg.policyFile = pf
+	}
+	cfg.accessFilePath = r.string("RATE_ACCESS_FILE", "")
+	if cfg.accessFilePath != "" {
+		af, err := ratelimit.LoadAccessFile(cfg.accessFilePath)
+		if err != nil {
+			r.failf("RATE_ACCESS_FILE: %v", err)
+		}
+		cfg.accessFile = af
+	}
+	cfg.trustXFF = r.bool("TRUST_XFF", false)
+	if spec := r.string("TRUSTED_PROXIES", ""); spec != "" {
+		tp, err := ratelimit.ParseTrustedProxies(spec)
+		if err != nil {
+			r.failf("TRUSTED_PROXIES: %v", err)
+		}
+		cfg.trustedProxies = tp
+	}
+	cfg.ipAggregation.IPv4Bits = r.int("RATE_IPV4_PREFIX", 32)
+	cfg.ipAggregation.IPv6Bits = r.int("RATE_IPV6_PREFIX", 64)
+	if cfg.ipAggregation.IPv4Bits < 1 || cfg.ipAggregation.IPv4Bits > 32 {
+		r.failf("RATE_IPV4_PREFIX must be between 1 and 32")
+	}
+	if cfg.ipAggregation.IPv6Bits < 1 || cfg.ipAggregation.IPv6Bits > 128 {
+		r.failf("RATE_IPV6_PREFIX must be between 1 and 128")
+	}
+	// Sem a lista, qualquer cliente forjaria o X-Forwarded-For e ganharia buckets novos.
+	if cfg.trustXFF && cfg.trustedProxies.Len() == 0 {
+		r.failf("TRUSTED_PROXIES is required when TRUST_XFF=true")
+	}
+	cfg.retryAfter = r.duration("RETRY_AFTER", 1*time.Second)
+	cfg.blockDuration = r.duration("RATE_BLOCK_DURATION", 0)
+	cfg.addHeaders = r.bool("ADD_RATELIMIT_HEADERS", false)
+	// compatibilidade: ADD_RATELIMIT_HEADERS=true sem RATE_HEADERS mantém os X-RateLimit-*
+	defHeaders := ratelimit.HeadersNone
+	if cfg.addHeaders {
+		defHeaders = ratelimit.HeadersLegacy
+	}
+	if mode, err := ratelimit.ParseHeaderMode(r.string("RATE_HEADERS", string(defHeaders))); err != nil {
+		r.failf("RATE_HEADERS: %v", err)
+	} else {
+		cfg.rateHeaders = mode
+	}
+	cfg.exposeKey = r.bool("RATE_HEADERS_EXPOSE_KEY", false)
+	cfg.globalRPS = r.float("GLOBAL_RATE_RPS", 0)
+	cfg.globalBurst = r.int("GLOBAL_RATE_BURST", max(1, int(math.Ceil(cfg.globalRPS))))
+	cfg.concurrencyMax = r.int("CONCURRENCY_MAX", 100)
+	cfg.concurrencyTimeout = r.duration("CONCURRENCY_TIMEOUT", 0)
+
+	cfg.rejectFormat = strings.ToLower(strings.TrimSpace(r.string("RATE_REJECT_FORMAT", "text")))
+	cfg.rejectMessage = r.string("RATE_REJECT_MESSAGE", challengeRejectMessage)
+	cfg.concurrencyRejectMessage = r.string("CONCURRENCY_REJECT_MESSAGE", "")
+	cfg.rejectTemplatePath = r.string("RATE_REJECT_TEMPLATE_FILE", "")
+	cfg.rejectContentType = r.string("RATE_REJECT_CONTENT_TYPE", "text/plain; charset=utf-8")
+	switch cfg.rejectFormat {
+	case "text", "json":
+	case "template":
+		if cfg.rejectTemplatePath == "" {
+			r.failf("RATE_REJECT_TEMPLATE_FILE is required when RATE_REJECT_FORMAT=template")
+			break
+		}
+		tmpl, err := ratelimit.LoadRejectTemplate(cfg.rejectTemplatePath)
+		if err != nil {
+			r.failf("RATE_REJECT_TEMPLATE_FILE: %v", err)
+		}
+		cfg.rejectTemplate = tmpl
+	default:
+		r.failf("RATE_REJECT_FORMAT: %q is not supported (use text, json or template)", cfg.rejectFormat)
+	}
+
+	if alg, err := infra.ParseAlgorithm(r.string("RATE_ALGORITHM", string(infra.AlgorithmTokenBucket))); err != nil {
+		r.failf("RATE_ALGORITHM: %v", err)
+	} else {
+		cfg.rateAlgorithm = alg
+	}
+	cfg.rateWindow = r.duration("RATE_WINDOW", 1*time.Second)
+	// RATE_WINDOW_LIMIT é um atalho para "N requisições por janela".
+	if r.isSet("RATE_WINDOW_LIMIT") {
+		if limit := r.int("RATE_WINDOW_LIMIT", 0); limit > 0 && cfg.rateWindow > 0 {
+			cfg.rateRPS = float64(limit) / cfg.rateWindow.Seconds()
+		} else {
+			r.failf("RATE_WINDOW_LIMIT must be > 0")
+		}
+	}
+
+	cfg.rateStore = strings.ToLower(strings.TrimSpace(r.string("RATE_STORE", "memory")))
+	cfg.rateRedisAddr = r.string("RATE_REDIS_ADDR", "")
+	cfg.rateRedisPassword = r.secret("RATE_REDIS_PASSWORD", "")
+	cfg.rateRedisDB = r.int("RATE_REDIS_DB", 0)
+	cfg.rateRedisPrefix = r.string("RATE_REDIS_PREFIX", "ratelimit:bucket")
+	cfg.rateRedisFailOpen = r.bool("RATE_REDIS_FAIL_OPEN", true)
+
+	cfg.rateStatsEnabled = r.bool("RATE_STATS_ENABLED", false)
+	cfg.rateStatsRedisAddr = r.string("RATE_STATS_REDIS_ADDR", "")
+	cfg.rateStatsRedisPassword = r.secret("RATE_STATS_REDIS_PASSWORD", "")
+	cfg.rateStatsRedisDB = r.int("RATE_STATS_REDIS_DB", 0)
+	cfg.rateStatsPrefix = r.string("RATE_STATS_PREFIX", "ratelimit:stats")
+	cfg.rateStatsTTL = r.duration("RATE_STATS_TTL", 24*time.Hour)
+	cfg.rateStatsBucket = r.string("RATE_STATS_BUCKET", "minute")
+	cfg.rateStatsTrackKeys = r.bool("RATE_STATS_TRACK_KEYS", false)
+	cfg.rateStatsAsync = r.bool("RATE_STATS_ASYNC", true)
+	cfg.rateStatsFlushInterval = r.duration("RATE_STATS_FLUSH_INTERVAL", time.Second)
+	cfg.rateStatsBatchSize = r.int("RATE_STATS_BATCH_SIZE", 500)
+	cfg.rateStatsQueueSize = r.int("RATE_STATS_QUEUE_SIZE", 10000)
+
+	cfg.adminAddr = r.string("ADMIN_ADDR", "")
+	cfg.adminToken = r.secret("ADMIN_TOKEN", "")
+	if cfg.adminAddr != "" && strings.TrimSpace(cfg.adminToken) == "" {
+		r.failf("ADMIN_TOKEN is required when ADMIN_ADDR is set")
+	}
+
+	cfg.metricsAddr = r.string("METRICS_ADDR", "")
+	cfg.metricsMaxRoutes = r.int("METRICS_MAX_ROUTES", 100)
+	if cfg.metricsMaxRoutes < 0 {
+		r.failf("METRICS_MAX_ROUTES must be >= 0")
+	}
+	cfg.metricsTrackKeys = r.bool("METRICS_TRACK_KEYS", false)
+
+	readTracing(r, &cfg)
+	readAccessLog(r, &cfg)
+
+	cfg.watchInterval = r.duration("CONFIG_WATCH_INTERVAL", 5*time.Second)
+
+	switch cfg.rateStore {
+	case "memory":
+	case "redis":
+		if strings.TrimSpace(cfg.rateRedisAddr) == "" {
+			r.failf("RATE_REDIS_ADDR is required when RATE_STORE=redis")
+		}
+	default:
+		r.failf("RATE_STORE: %q is not supported (use memory or redis)", cfg.rateStore)
+	}
+
+	switch cfg.rateAlgorithm {
+	case infra.AlgorithmSlidingWindow, infra.AlgorithmSlidingWindowLog:
+		if cfg.rateStore == "redis" {
+			r.failf("RATE_ALGORITHM=%s is only supported with RATE_STORE=memory", cfg.rateAlgorithm)
+		}
+	}
+	if cfg.rateAlgorithm.Windowed() && cfg.rateWindow <= 0 {
+		r.failf("RATE_WINDOW must be > 0")
+	}
+
+	if cfg.apiKeysBackend == "redis" && strings.TrimSpace(cfg.rateRedisAddr) == "" {
+		r.failf("RATE_REDIS_ADDR is required when RATE_API_KEYS=redis")
+	}
+
+	if cfg.rateStatsEnabled && strings.TrimSpace(cfg.rateStatsRedisAddr) == "" {
+		r.failf("RATE_STATS_REDIS_ADDR is required when RATE_STATS_ENABLED=true")
+	}
+	if cfg.rateStatsAsync {
+		if cfg.rateStatsFlushInterval <= 0 {
+			r.failf("RATE_STATS_FLUSH_INTERVAL must be > 0")
+		}
+		if cfg.rateStatsBatchSize <= 0 {
+			r.failf("RATE_STATS_BATCH_SIZE must be > 0")
+		}
+		if cfg.rateStatsQueueSize <= 0 {
+			r.failf("RATE_STATS_QUEUE_SIZE must be > 0")
+		}
+	}
+
+	if cfg.upstreamURL == "" {
+		r.failf("UPSTREAM_URL is required")
+	}
+	if cfg.rateRPS <= 0 {
+		r.failf("RATE_RPS must be > 0")
+	}
+	if cfg.rateBurst <= 0 {
+		r.failf("RATE_BURST must be > 0")
+	}
+	if cfg.globalRPS < 0 {
+		r.failf("GLOBAL_RATE_RPS must be >= 0")
+	}
+	if cfg.globalRPS > 0 && cfg.globalBurst <= 0 {
+		r.failf("GLOBAL_RATE_BURST must be > 0")
+	}
+	if cfg.blockDuration < 0 {
+		r.failf("RATE_BLOCK_DURATION must be >= 0")
+	}
+	if cfg.concurrencyMax < 0 {
+		r.failf("CONCURRENCY_MAX must be >= 0")
+	}
+	if cfg.watchInterval < 0 {
+		r.failf("CONFIG_WATCH_INTERVAL must be >= 0")
+	}
+
+	if err := r.err(); err != nil {
+		return config{}, err
+	}
+	return cfg, nil
+}
+
+// forwardingProxies retorna os proxies confiáveis só se TRUST_XFF estiver ligado;
+// caso contrário os headers de encaminhamento são ignorados.
+func (cfg config) forwardingProxies() *ratelimit.TrustedProxies {
+	if !cfg.trustXFF {
+		return nil
+	}
+	return cfg.trustedProxies
+}
+
+// challengeRejectMessage é a mensagem exigida pelo desafio para o 429.
+const challengeRejectMessage = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+
+// rejectHandler monta o RejectHandler do formato configurado com message.
+func (cfg config) rejectHandler(message string) ratelimit.RejectHandler {
+	switch cfg.rejectFormat {
+	case "json":
+		return ratelimit.ProblemJSONRejecter(message)
+	case "template":
+		return ratelimit.TemplateRejecter(cfg.rejectTemplate, cfg.rejectContentType, message)
+	default:
+		return ratelimit.TextRejecter(message)
+	}
+}
+
+// readJWT monta o JWTIdentifier a partir de RATE_JWT_*; retorna nil se nem
+// RATE_JWT_SECRET nem RATE_JWT_JWKS_FILE estiverem definidos.
+func readJWT(r *configReader, jwksPath string) (*ratelimit.JWTIdentifier, bool) {
+	opts := ratelimit.JWTOptions{
+		Header:    r.string("RATE_JWT_HEADER", "Authorization"),
+		Claim:     r.string("RATE_JWT_CLAIM", "sub"),
+		PlanClaim: r.string("RATE_JWT_PLAN_CLAIM", "plan"),
+		Secret:    []byte(r.secret("RATE_JWT_SECRET", "")),
+		Issuer:    r.string("RATE_JWT_ISSUER", ""),
+		Audience:  r.string("RATE_JWT_AUDIENCE", ""),
+		Leeway:    r.duration("RATE_JWT_LEEWAY", 0),
+	}
+	if spec := r.string("RATE_JWT_TIERS", ""); spec != "" {
+		tiers, err := infra.ParseTokenPolicies(spec)
+		if err != nil {
+			r.failf("RATE_JWT_TIERS: %v", err)
+		}
+		opts.Tiers = tiers
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(r.string("RATE_JWT_INVALID", "ip"))); mode {
+	case "ip":
+	case "reject":
+		opts.RejectInvalid = true
+	default:
+		r.failf("RATE_JWT_INVALID: %q is not supported (use ip or reject)", mode)
+	}
+
+	if jwksPath != "" {
+		set, err := ratelimit.LoadJWKS(jwksPath)
+		if err != nil {
+			r.failf("RATE_JWT_JWKS_FILE: %v", err)
+			return nil, false
+		}
+		opts.JWKS = set
+	}
+	if len(opts.Secret) == 0 && opts.JWKS == nil {
+		return nil, false
+	}
+	j, err := ratelimit.NewJWTIdentifier(opts)
+	if err != nil {
+		r.failf("RATE_JWT_*: %v", err)
+		return nil, false
+	}
+	return j, opts.RejectInvalid
+}
+
+// readAPIKeys lê RATE_API_KEYS_*. Com o registro ligado, RATE_KEY_HEADER não
+// pode ser o header das keys: uma key desconhecida ganharia um bucket próprio.
+func readAPIKeys(r *configReader, cfg *config) {
+	cfg.apiKeysBackend = strings.ToLower(strings.TrimSpace(r.string("RATE_API_KEYS", "")))
+	cfg.apiKeysHeader = r.string("RATE_API_KEYS_HEADER", "X-API-Key")
+	cfg.apiKeysFilePath = r.string("RATE_API_KEYS_FILE", "")
+	cfg.apiKeysRedisPrefix = r.string("RATE_API_KEYS_REDIS_PREFIX", "ratelimit:apikey")
+	cfg.apiKeysCacheTTL = r.duration("RATE_API_KEYS_CACHE_TTL", 5*time.Second)
+	switch mode := strings.ToLower(strings.TrimSpace(r.string("RATE_API_KEYS_UNKNOWN", "ip"))); mode {
+	case "ip":
+	case "reject":
+		cfg.apiKeysReject = true
+	default:
+		r.failf("RATE_API_KEYS_UNKNOWN: %q is not supported (use ip or reject)", mode)
+	}
+
+	switch cfg.apiKeysBackend {
+	case "":
+		return
+	case "file":
+		if cfg.apiKeysFilePath == "" {
+			r.failf("RATE_API_KEYS_FILE is required when RATE_API_KEYS=file")
+			break
+		}
+		keys, err := infra.LoadAPIKeysFile(cfg.apiKeysFilePath)
+		if err != nil {
+			r.failf("RATE_API_KEYS_FILE: %v", err)
+		}
+		cfg.apiKeysFile = keys
+	case "redis":
+	default:
+		r.failf("RATE_API_KEYS: %q is not supported (use file or redis)", cfg.apiKeysBackend)
+	}
+	if strings.EqualFold(cfg.rateKeyHeader, cfg.apiKeysHeader) {
+		r.failf("RATE_KEY_HEADER: %q is the API key header (RATE_API_KEYS_HEADER); unset it or use another header", cfg.rateKeyHeader)
+	}
+}
+
+// readQuotas lê RATE_QUOTAS ("periodo=limite,...") e a rejeição das cotas.
+func readQuotas(r *configReader, cfg *config) {
+	cfg.quotaRejectStatus = r.int("RATE_QUOTA_REJECT_STATUS", http.StatusForbidden)
+	cfg.quotaRejectMessage = r.string("RATE_QUOTA_REJECT_MESSAGE", "quota exceeded")
+	if cfg.quotaRejectStatus < 400 || cfg.quotaRejectStatus > 599 {
+		r.failf("RATE_QUOTA_REJECT_STATUS must be between 400 and 599")
+	}
+	if spec := r.string("RATE_QUOTAS", ""); spec != "" {
+		quotas, err := parseQuotas(spec)
+		if err != nil {
+			r.failf("RATE_QUOTAS: %v", err)
+		}
+		cfg.quotas = quotas
+	}
+}
+
+func parseQuotas(spec string) (map[domain.QuotaPeriod]int, error) {
+	out := make(map[domain.QuotaPeriod]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: expected period=limit", item)
+		}
+		p, err := domain.ParseQuotaPeriod(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := out[p]; dup {
+			return nil, fmt.Errorf("period %q repeated", p)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("%q: limit must be an integer > 0", item)
+		}
+		out[p] = limit
+	}
+	return out, nil
+}
+
+// readTokenPolicies junta as políticas por token do arquivo (RATE_TOKENS_FILE)
+// e da variável RATE_TOKENS; em caso de token repetido, a variável vence.
+func readTokenPolicies(r *configReader, path string) map[string]domain.Policy {
+	out := make(map[string]domain.Policy)
+	if path != "" {
+		fromFile, err := infra.LoadTokenPoliciesFile(path)
+		if err != nil {
+			r.failf("RATE_TOKENS_FILE: %v", err)
+		}
+		for tok, p := range fromFile {
+			out[tok] = p
+		}
+	}
+	if spec := r.secret("RATE_TOKENS", ""); spec != "" {
+		fromEnv, err := infra.ParseTokenPolicies(spec)
+		if err != nil {
+			r.failf("RATE_TOKENS: %v", err)
+		}
+		for tok, p := range fromEnv {
+			out[tok] = p
+		}
+	}
+	return out
+}
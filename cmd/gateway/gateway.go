@@ -0,0 +1,264 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"middleware-gateway/middleware/ratelimit"
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// gateway guarda as dependências de vida longa (stores, stats, pool) e a cadeia
+// de middlewares atual, que pode ser trocada atomicamente (hot reload) sem
+// perder o estado por chave dos stores.
+type gateway struct {
+	upstream http.Handler
+	store    domain.LimiterStore
+	stats    domain.StatsStore
+	// statsReader lê as estatísticas de RATE_STATS_* na API admin; nil se
+	// desligadas.
+	statsReader domain.StatsReader
+	// statsBuffer é a fila de RATE_STATS_ASYNC na frente do Redis; nil se
+	// desligada.
+	statsBuffer *infra.BufferedStatsStore
+	// apiKeys é o registro no Redis (RATE_API_KEYS=redis) e quota guarda as
+	// cotas diárias das keys; nil se não configurados.
+	apiKeys domain.APIKeyStore
+	quota   domain.LimiterStore
+	// global guarda o bucket único do limite global (GLOBAL_RATE_RPS); nil
+	// desliga o limite.
+	global domain.LimiterStore
+	// quotas guarda o consumo das cotas de calendário (RATE_QUOTAS), um store
+	// por período; o limite de cada um vem da config a cada apply.
+	quotas map[domain.QuotaPeriod]quotaStore
+	// metrics expõe o /metrics (METRICS_ADDR); nil se desligado.
+	metrics *gatewayMetrics
+	// tracer cria os spans dos middlewares (TRACE_EXPORTER); nil se desligado.
+	tracer trace.Tracer
+
+	mu      sync.Mutex // serializa apply
+	cfg     config
+	pool    domain.SlotPool
+	poolMax int
+	// rules e tokens são as políticas da config atual (nil se nenhuma), para
+	// a API admin calcular o saldo das chaves no Redis.
+	rules  *ratelimit.RuleResolver
+	tokens domain.PolicyResolver
+
+	handler atomic.Pointer[http.Handler]
+}
+
+// apiKeyStore escolhe o registro de API keys configurado (nil se desligado).
+func (g *gateway) apiKeyStore(cfg config) domain.APIKeyStore {
+	switch cfg.apiKeysBackend {
+	case "file":
+		if cfg.apiKeysFile != nil {
+			return cfg.apiKeysFile
+		}
+	case "redis":
+		return g.apiKeys
+	}
+	return nil
+}
+
+// limitSetter é implementado por stores que aceitam trocar o limite padrão
+// em tempo de execução (infra.Store, infra.RedisStore).
+type limitSetter interface {
+	SetLimits(rps float64, burst int)
+}
+
+// quotaStore é implementado por infra.QuotaStore e infra.RedisQuotaStore.
+type quotaStore interface {
+	domain.LimiterStore
+	SetLimit(limit int)
+}
+
+// quotaPeriods é a ordem de avaliação das cotas.
+var quotaPeriods = []domain.QuotaPeriod{domain.QuotaHour, domain.QuotaDay, domain.QuotaMonth}
+
+func newGateway(upstream http.Handler, store domain.LimiterStore, stats domain.StatsStore) *gateway {
+	return &gateway{upstream: upstream, store: store, stats: stats}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*g.handler.Load()).ServeHTTP(w, r)
+}
+
+func (g *gateway) config() config {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.cfg
+}
+
+// apply monta a cadeia de middlewares para cfg e a coloca no ar.
+// Se algo falhar, retorna erro e a cadeia anterior continua ativa.
+func (g *gateway) apply(cfg config) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var policies domain.PolicyResolver
+	if len(cfg.tokenPolicies) > 0 {
+		policies = infra.NewTokenPolicies(cfg.tokenPolicies)
+	}
+
+	var (
+		routes ratelimit.RouteResolver
+		rules  *ratelimit.RuleResolver
+	)
+	if len(cfg.policyFile.Rules) > 0 {
+		rr, err := ratelimit.NewRuleResolver(cfg.policyFile, ratelimit.RuleKeyOptions{
+			TokenHeader:        cfg.rateKeyHeader,
+			TrustXForwardedFor: cfg.trustXFF,
+			TrustedProxies:     cfg.forwardingProxies(),
+			IPAggregation:      cfg.ipAggregation,
+		})
+		if err != nil {
+			return err
+		}
+		routes, rules = rr, rr
+	}
+
+	// listas de acesso: a key vem do header das API keys ou dos tokens
+	access, err := ratelimit.NewAccessList(cfg.accessFile, ratelimit.AccessOptions{
+		KeyHeaders:         []string{cfg.apiKeysHeader, cfg.rateKeyHeader},
+		TrustXForwardedFor: cfg.trustXFF,
+		TrustedProxies:     cfg.forwardingProxies(),
+	})
+	if err != nil {
+		return err
+	}
+	if access.Len() == 0 {
+		access = nil
+	}
+
+	// O pool só é recriado se a capacidade mudar; requisições em curso no pool
+	// antigo terminam normalmente.
+	pool := g.pool
+	if pool == nil || cfg.concurrencyMax != g.poolMax {
+		pool = nil
+		if cfg.concurrencyMax > 0 {
+			pool = infra.NewChanPool(cfg.concurrencyMax)
+		}
+	}
+
+	h := g.upstream
+	if g.metrics != nil {
+		h = g.metrics.instrument(h)
+	}
+	h = ratelimit.ConcurrencyMiddleware(ratelimit.ConcurrencyOptions{
+		Max:            cfg.concurrencyMax,
+		RejectStatus:   http.StatusServiceUnavailable,
+		AcquireTimeout: cfg.concurrencyTimeout,
+		Pool:           pool,
+		OnReject:       cfg.rejectHandler(cfg.concurrencyRejectMessage),
+		Stats:          g.stats,
+		Tracer:         g.tracer,
+	})(h)
+
+	if ls, ok := g.store.(limitSetter); ok {
+		ls.SetLimits(cfg.rateRPS, cfg.rateBurst)
+	}
+	if ls, ok := g.global.(limitSetter); ok && cfg.globalRPS > 0 {
+		ls.SetLimits(cfg.globalRPS, cfg.globalBurst)
+	}
+	var (
+		identifiers []ratelimit.Identifier
+		limits      []ratelimit.Limit
+	)
+	if cfg.jwt != nil {
+		identifiers = append(identifiers, cfg.jwt)
+	}
+	if keys := g.apiKeyStore(cfg); keys != nil {
+		ids := ratelimit.NewAPIKeyIdentifier(ratelimit.APIKeyOptions{
+			Header:        cfg.apiKeysHeader,
+			Keys:          keys,
+			RejectUnknown: cfg.apiKeysReject,
+			OnError:       func(err error) { log.Printf("api key registry error: %v", err) },
+		})
+		identifiers = append(identifiers, ids)
+		if g.quota != nil {
+			limits = append(limits, ids.QuotaLimit("daily-quota", g.quota))
+		}
+	}
+	for _, p := range quotaPeriods {
+		qs, limit := g.quotas[p], cfg.quotas[p]
+		if qs == nil || limit <= 0 {
+			continue
+		}
+		qs.SetLimit(limit)
+		limits = append(limits, ratelimit.Limit{
+			Name:         "quota-" + string(p),
+			Store:        qs,
+			RejectStatus: cfg.quotaRejectStatus,
+			Reason:       ratelimit.ReasonQuotaExceeded,
+			OnReject:     cfg.rejectHandler(cfg.quotaRejectMessage),
+		})
+	}
+	var identifier ratelimit.Identifier
+	if len(identifiers) > 0 {
+		identifier = ratelimit.Identifiers(identifiers...)
+	}
+	// isenções da API admin: só consultadas com ela ligada
+	var exemptions domain.ExemptionStore
+	if cfg.adminAddr != "" {
+		exemptions, _ = g.store.(domain.ExemptionStore)
+	}
+
+	if g.global != nil && cfg.globalRPS > 0 {
+		limits = append(limits, ratelimit.Limit{
+			Name:  "global",
+			KeyFn: ratelimit.StaticKeyFunc("global"),
+			Store: g.global,
+		})
+	}
+
+	if cfg.rateEnabled {
+		h = ratelimit.Middleware(ratelimit.Options{
+			Store:               g.store,
+			Policies:            policies,
+			Stats:               g.stats,
+			KeyHeader:           cfg.rateKeyHeader,
+			TrustXForwardedFor:  cfg.trustXFF,
+			TrustedProxies:      cfg.forwardingProxies(),
+			IPAggregation:       cfg.ipAggregation,
+			RejectStatus:        http.StatusTooManyRequests,
+			RetryAfter:          cfg.retryAfter,
+			AddRateLimitHeaders: cfg.addHeaders,
+			Headers:             cfg.rateHeaders,
+			ExposeKey:           cfg.exposeKey,
+			BlockDuration:       cfg.blockDuration,
+			Routes:              routes,
+			OnReject:            cfg.rejectHandler(cfg.rejectMessage),
+			Limits:              limits,
+			Identifier:          identifier,
+			OnUnauthorized:      cfg.rejectHandler(""),
+			Exemptions:          exemptions,
+			Access:              access,
+			OnDeny:              cfg.rejectHandler(""),
+			Tracer:              g.tracer,
+		})(h)
+	} else if len(limits) > 0 || access != nil {
+		// só limites extras (global, cotas) e listas de acesso: sem limite por chave
+		h = ratelimit.Middleware(ratelimit.Options{
+			Stats:     g.stats,
+			KeyHeader: cfg.rateKeyHeader,
+			Headers:   cfg.rateHeaders,
+			OnReject:  cfg.rejectHandler(cfg.rejectMessage),
+			Limits:    limits,
+			Access:    access,
+			OnDeny:    cfg.rejectHandler(""),
+			Tracer:    g.tracer,
+		})(h)
+	}
+
+	g.handler.Store(&h)
+	g.cfg = cfg
+	g.pool, g.poolMax = pool, cfg.concurrencyMax
+	g.rules, g.tokens = rules, policies
+	return nil
+}
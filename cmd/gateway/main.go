@@ -3,29 +3,47 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
-	"middleware-gateway/middleware/ratelimit"
 	"middleware-gateway/middleware/ratelimit/domain"
 	"middleware-gateway/middleware/ratelimit/infra"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
-	cfg, err := readConfig()
+	envFile := flag.String("env-file", ".env", "arquivo .env (variáveis de ambiente reais têm precedência)")
+	printConfig := flag.Bool("print-config", false, "imprime a configuração efetiva (segredos mascarados) e sai")
+	flag.Parse()
+
+	dotenv, err := loadDotEnv(*envFile, isFlagSet("env-file"))
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
+	reader := newConfigReader(newEnvSource(dotenv))
+	cfg, err := loadConfig(reader)
+
+	if *printConfig {
+		_ = reader.writeEffective(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nconfig errors:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatalf("config error:\n%v", err)
+	}
 
 	target, err := url.Parse(cfg.upstreamURL)
 	if err != nil {
@@ -38,225 +56,323 @@ func main() {
 		http.Error(w, "bad gateway", http.StatusBadGateway)
 	}
 
-	store := infra.NewStore(cfg.rateRPS, cfg.rateBurst)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	var statsStore domain.StatsStore
-	if cfg.rateStatsEnabled {
-		rdb := redis.NewClient(&redis.Options{
-			Addr:     cfg.rateStatsRedisAddr,
-			Password: cfg.rateStatsRedisPassword,
-			DB:       cfg.rateStatsRedisDB,
-		})
+	var tracer trace.Tracer
+	if cfg.tracingEnabled() {
+		exp, closer, err := newSpanExporter(ctx, cfg)
+		if err != nil {
+			log.Fatalf("trace exporter error: %v", err)
+		}
+		tp := newTracerProvider(cfg, exp)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = tp.Shutdown(shutdownCtx)
+			if closer != nil {
+				_ = closer.Close()
+			}
+		}()
+		tracer = tp.Tracer(tracerName)
+		proxy.Transport = tracingTransport{base: http.DefaultTransport, tracer: tracer}
+	}
+
+	var rdb *redis.Client
+	if cfg.rateStore == "redis" || cfg.apiKeysBackend == "redis" {
+		rdb, err = newRedisClient(cfg.rateRedisAddr, cfg.rateRedisPassword, cfg.rateRedisDB)
+		if err != nil {
+			log.Fatalf("redis limiter ping error: %v", err)
+		}
 		defer func() { _ = rdb.Close() }()
+	}
 
-		pingCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		_, err := rdb.Ping(pingCtx).Result()
-		cancel()
+	var store, global, quota domain.LimiterStore
+	quotas := make(map[domain.QuotaPeriod]quotaStore, len(quotaPeriods))
+	switch cfg.rateStore {
+	case "redis":
+		store = newRedisStore(rdb, cfg)
+		// o limite global é compartilhado por todas as réplicas
+		global = newRedisGlobalStore(rdb, cfg)
+		// cotas diárias das API keys (dia UTC): a cota de cada key vira a
+		// política do contador, o padrão só vale se a key sumir no meio
+		quota = newRedisQuotaStore(rdb, cfg, domain.QuotaDay, maxDailyQuota)
+		for _, p := range quotaPeriods {
+			quotas[p] = newRedisQuotaStore(rdb, cfg, p, 0)
+		}
+	default:
+		store = newMemoryStore(ctx, cfg)
+		global = infra.NewStore(cfg.globalRPS, cfg.globalBurst)
+		qs := infra.NewQuotaStore(domain.QuotaDay, maxDailyQuota)
+		qs.StartJanitor(ctx)
+		quota = qs
+		for _, p := range quotaPeriods {
+			qs := infra.NewQuotaStore(p, 0)
+			qs.StartJanitor(ctx)
+			quotas[p] = qs
+		}
+	}
+
+	var (
+		statsStore  domain.StatsStore
+		statsReader domain.StatsReader
+		statsBuffer *infra.BufferedStatsStore
+	)
+	if cfg.rateStatsEnabled {
+		rdb, err := newRedisClient(cfg.rateStatsRedisAddr, cfg.rateStatsRedisPassword, cfg.rateStatsRedisDB)
 		if err != nil {
 			log.Fatalf("redis stats ping error: %v", err)
 		}
+		defer func() { _ = rdb.Close() }()
 
-		statsStore = infra.NewRedisStatsStore(
+		redisStats := infra.NewRedisStatsStore(
 			rdb,
 			infra.WithStatsPrefix(cfg.rateStatsPrefix),
 			infra.WithStatsTTL(cfg.rateStatsTTL),
 			infra.WithStatsBucket(cfg.rateStatsBucket),
 			infra.WithStatsTrackKeys(cfg.rateStatsTrackKeys),
 		)
+		statsStore, statsReader = redisStats, redisStats
+		if cfg.rateStatsAsync {
+			statsBuffer = infra.NewBufferedStatsStore(
+				redisStats,
+				infra.WithBufferFlushEvery(cfg.rateStatsFlushInterval),
+				infra.WithBufferBatchSize(cfg.rateStatsBatchSize),
+				infra.WithBufferQueueSize(cfg.rateStatsQueueSize),
+				infra.WithBufferOnError(func(err error) { log.Printf("redis stats error: %v", err) }),
+			)
+			// depois do shutdown do servidor: grava o que ficou na fila
+			defer func() {
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := statsBuffer.Close(flushCtx); err != nil {
+					log.Printf("stats flush on shutdown: %v (dropped=%d failed=%d)", err, statsBuffer.Dropped(), statsBuffer.Failed())
+				}
+			}()
+			statsStore = statsBuffer
+		}
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
-	store.StartJanitor(ctx)
+	var metrics *gatewayMetrics
+	if cfg.metricsAddr != "" {
+		metrics = newGatewayMetrics(cfg)
+		statsStore = metrics.statsStore(statsStore)
+	}
 
-	h := http.Handler(proxy)
-	h = ratelimit.ConcurrencyMiddleware(ratelimit.ConcurrencyOptions{
-		Max:            cfg.concurrencyMax,
-		RejectStatus:   http.StatusServiceUnavailable,
-		AcquireTimeout: cfg.concurrencyTimeout,
-	})(h)
-	if cfg.rateEnabled {
-		h = ratelimit.Middleware(ratelimit.Options{
-			Store:               store,
-			Stats:               statsStore,
-			KeyHeader:           cfg.rateKeyHeader,
-			TrustXForwardedFor:  cfg.trustXFF,
-			RejectStatus:        http.StatusTooManyRequests,
-			RetryAfter:          cfg.retryAfter,
-			AddRateLimitHeaders: cfg.addHeaders,
-		})(h)
+	gw := newGateway(proxy, store, statsStore)
+	gw.statsReader = statsReader
+	gw.statsBuffer = statsBuffer
+	gw.metrics = metrics
+	gw.tracer = tracer
+	gw.global = global
+	gw.quota = quota
+	gw.quotas = quotas
+	if cfg.apiKeysBackend == "redis" {
+		gw.apiKeys = infra.NewRedisAPIKeys(
+			rdb,
+			infra.WithAPIKeyPrefix(cfg.apiKeysRedisPrefix),
+			infra.WithAPIKeyCacheTTL(cfg.apiKeysCacheTTL),
+		)
+	}
+	if err := gw.apply(cfg); err != nil {
+		log.Fatalf("config error: %v", err)
 	}
 
+	rl := &reloader{gw: gw, envFile: *envFile, envRequired: isFlagSet("env-file")}
+	go rl.run(ctx, cfg.watchInterval)
+
+	var handler http.Handler = gw
+	if cfg.accessLogEnabled() {
+		out, err := openAccessLog(cfg)
+		if err != nil {
+			log.Fatalf("access log error: %v", err)
+		}
+		defer func() { _ = out.Close() }()
+		handler = newAccessLogger(cfg, out, target.Host).middleware(handler)
+	}
+	if tracer != nil {
+		handler = traced(tracer, handler)
+	}
 	srv := &http.Server{
 		Addr:              cfg.listenAddr,
-		Handler:           h,
+		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
 		ReadTimeout:       30 * time.Second,
 		WriteTimeout:      30 * time.Second,
 		IdleTimeout:       90 * time.Second,
 	}
 
+	// shutdown fecha quando as requisições em andamento terminam (ou o prazo
+	// acaba); main espera por ele antes dos defers (ex: flush das stats).
+	shutdown := make(chan struct{})
 	go func() {
+		defer close(shutdown)
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		_ = srv.Shutdown(shutdownCtx)
 	}()
 
-	log.Printf("gateway listening on %s -> %s", cfg.listenAddr, target)
-	log.Printf("rate: enabled=%v rps=%.3f burst=%d keyHeader=%q trustXFF=%v", cfg.rateEnabled, cfg.rateRPS, cfg.rateBurst, cfg.rateKeyHeader, cfg.trustXFF)
-	log.Printf("rate-stats: enabled=%v redisAddr=%q bucket=%q ttl=%s trackKeys=%v", cfg.rateStatsEnabled, cfg.rateStatsRedisAddr, cfg.rateStatsBucket, cfg.rateStatsTTL, cfg.rateStatsTrackKeys)
-	log.Printf("concurrency: max=%d acquireTimeout=%s", cfg.concurrencyMax, cfg.concurrencyTimeout)
-
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatalf("server error: %v", err)
+	if cfg.adminAddr != "" {
+		admin := &http.Server{
+			Addr:              cfg.adminAddr,
+			Handler:           gw.adminHandler(),
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			_ = admin.Close()
+		}()
+		go func() {
+			if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("admin server error: %v", err)
+			}
+		}()
 	}
-}
-
-type config struct {
-	listenAddr         string
-	upstreamURL        string
-	rateEnabled        bool
-	rateRPS            float64
-	rateBurst          int
-	rateKeyHeader      string
-	trustXFF           bool
-	retryAfter         time.Duration
-	addHeaders         bool
-	concurrencyMax     int
-	concurrencyTimeout time.Duration
-
-	rateStatsEnabled       bool
-	rateStatsRedisAddr     string
-	rateStatsRedisPassword string
-	rateStatsRedisDB       int
-	rateStatsPrefix        string
-	rateStatsTTL           time.Duration
-	rateStatsBucket        string
-	rateStatsTrackKeys     bool
-}
 
-func readConfig() (config, error) {
-	cfg := config{}
-	cfg.listenAddr = getenvDefault("LISTEN_ADDR", ":8080")
-	cfg.upstreamURL = stringsRequired("UPSTREAM_URL")
-	cfg.rateEnabled = getenvBoolDefault("RATE_ENABLED", true)
-	cfg.rateRPS = getenvFloatDefault("RATE_RPS", 10)
-	// IMPORTANTE: o "burst" permite uma rajada inicial de requisições.
-	// Com RPS muito baixo (ex: 0.02), o padrão 20 pode dar a impressão de que
-	// o limiter não está funcionando, porque as primeiras ~20 passam.
-	if burst, ok := getenvInt("RATE_BURST"); ok {
-		cfg.rateBurst = burst
-	} else {
-		cfg.rateBurst = 20
-		if getenvIsSet("RATE_RPS") && cfg.rateRPS > 0 && cfg.rateRPS < 1 {
-			cfg.rateBurst = 1
+	if cfg.metricsAddr != "" {
+		ms := &http.Server{
+			Addr:              cfg.metricsAddr,
+			Handler:           gw.metricsHandler(),
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      30 * time.Second,
 		}
+		go func() {
+			<-ctx.Done()
+			_ = ms.Close()
+		}()
+		go func() {
+			if err := ms.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("metrics server error: %v", err)
+			}
+		}()
 	}
-	cfg.rateKeyHeader = os.Getenv("RATE_KEY_HEADER")
-	cfg.trustXFF = getenvBoolDefault("TRUST_XFF", false)
-	cfg.retryAfter = getenvDurationDefault("RETRY_AFTER", 1*time.Second)
-	cfg.addHeaders = getenvBoolDefault("ADD_RATELIMIT_HEADERS", false)
-	cfg.concurrencyMax = getenvIntDefault("CONCURRENCY_MAX", 100)
-	cfg.concurrencyTimeout = getenvDurationDefault("CONCURRENCY_TIMEOUT", 0)
 
-	cfg.rateStatsEnabled = getenvBoolDefault("RATE_STATS_ENABLED", false)
-	cfg.rateStatsRedisAddr = getenvDefault("RATE_STATS_REDIS_ADDR", "")
-	cfg.rateStatsRedisPassword = os.Getenv("RATE_STATS_REDIS_PASSWORD")
-	cfg.rateStatsRedisDB = getenvIntDefault("RATE_STATS_REDIS_DB", 0)
-	cfg.rateStatsPrefix = getenvDefault("RATE_STATS_PREFIX", "ratelimit:stats")
-	cfg.rateStatsTTL = getenvDurationDefault("RATE_STATS_TTL", 24*time.Hour)
-	cfg.rateStatsBucket = getenvDefault("RATE_STATS_BUCKET", "minute")
-	cfg.rateStatsTrackKeys = getenvBoolDefault("RATE_STATS_TRACK_KEYS", false)
-
-	if cfg.rateStatsEnabled && strings.TrimSpace(cfg.rateStatsRedisAddr) == "" {
-		return config{}, errors.New("RATE_STATS_REDIS_ADDR is required when RATE_STATS_ENABLED=true")
-	}
+	log.Printf("gateway listening on %s -> %s", cfg.listenAddr, target)
+	log.Printf("rate: enabled=%v rps=%.3f burst=%d keyHeader=%q trustXFF=%v trustedProxies=%d", cfg.rateEnabled, cfg.rateRPS, cfg.rateBurst, cfg.rateKeyHeader, cfg.trustXFF, cfg.trustedProxies.Len())
+	log.Printf("rate-tokens: count=%d blockDuration=%s", len(cfg.tokenPolicies), cfg.blockDuration)
+	log.Printf("rate-policy: file=%q rules=%d", cfg.policyFilePath, len(cfg.policyFile.Rules))
+	log.Printf("rate-access: file=%q allow=%d deny=%d", cfg.accessFilePath, len(cfg.accessFile.Allow.IPs)+len(cfg.accessFile.Allow.Keys), len(cfg.accessFile.Deny.IPs)+len(cfg.accessFile.Deny.Keys))
+	log.Printf("rate-algorithm: %s window=%s", cfg.rateAlgorithm, cfg.rateWindow)
+	log.Printf("rate-jwt: enabled=%v jwks=%q rejectInvalid=%v", cfg.jwt != nil, cfg.jwtJWKSPath, cfg.jwtRejectBad)
+	log.Printf("rate-api-keys: backend=%q header=%q rejectUnknown=%v", cfg.apiKeysBackend, cfg.apiKeysHeader, cfg.apiKeysReject)
+	log.Printf("rate-global: rps=%.3f burst=%d", cfg.globalRPS, cfg.globalBurst)
+	log.Printf("rate-quotas: hour=%d day=%d month=%d rejectStatus=%d", cfg.quotas[domain.QuotaHour], cfg.quotas[domain.QuotaDay], cfg.quotas[domain.QuotaMonth], cfg.quotaRejectStatus)
+	log.Printf("rate-store: backend=%q redisAddr=%q prefix=%q failOpen=%v", cfg.rateStore, cfg.rateRedisAddr, cfg.rateRedisPrefix, cfg.rateRedisFailOpen)
+	log.Printf("rate-stats: enabled=%v redisAddr=%q bucket=%q ttl=%s trackKeys=%v async=%v flushInterval=%s batchSize=%d queueSize=%d", cfg.rateStatsEnabled, cfg.rateStatsRedisAddr, cfg.rateStatsBucket, cfg.rateStatsTTL, cfg.rateStatsTrackKeys, cfg.rateStatsAsync, cfg.rateStatsFlushInterval, cfg.rateStatsBatchSize, cfg.rateStatsQueueSize)
+	log.Printf("concurrency: max=%d acquireTimeout=%s", cfg.concurrencyMax, cfg.concurrencyTimeout)
+	log.Printf("admin: addr=%q", cfg.adminAddr)
+	log.Printf("tracing: exporter=%q endpoint=%q file=%q sampleRatio=%.3f", cfg.traceExporter, cfg.traceOTLPEndpoint, cfg.traceFile, cfg.traceSampleRatio)
+	log.Printf("access-log: output=%q file=%q sampleRatio=%.3f level=%s rejectedLevel=%s", cfg.accessLog, cfg.accessLogFile, cfg.accessLogSampleRatio, cfg.accessLogLevel, cfg.accessLogRejectedLevel)
+	log.Printf("metrics: addr=%q maxRoutes=%d trackKeys=%v", cfg.metricsAddr, cfg.metricsMaxRoutes, cfg.metricsTrackKeys)
+	log.Printf("reload: SIGHUP, watchInterval=%s", cfg.watchInterval)
 
-	if cfg.upstreamURL == "" {
-		return config{}, errors.New("UPSTREAM_URL is required")
-	}
-	if cfg.rateRPS <= 0 {
-		return config{}, errors.New("RATE_RPS must be > 0")
-	}
-	if cfg.rateBurst <= 0 {
-		return config{}, errors.New("RATE_BURST must be > 0")
-	}
-	if cfg.concurrencyMax < 0 {
-		return config{}, errors.New("CONCURRENCY_MAX must be >= 0")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server error: %v", err)
 	}
-	return cfg, nil
+	<-shutdown
 }
-func stringsRequired(k string) string { return os.Getenv(k) }
 
-func getenvDefault(k, def string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
-	}
-	return def
-}
+// maxDailyQuota é o limite padrão do store de cotas diárias (na prática,
+// sem limite).
+const maxDailyQuota = 1 << 30
 
-func getenvIntDefault(k string, def int) int {
-	v := os.Getenv(k)
-	if v == "" {
-		return def
-	}
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		return def
-	}
-	return i
+// newRedisStore cria o store dos buckets por cliente no Redis, em
+// <RATE_REDIS_PREFIX>.
+func newRedisStore(rdb *redis.Client, cfg config) *infra.RedisStore {
+	return infra.NewRedisStore(
+		rdb,
+		cfg.rateRPS,
+		cfg.rateBurst,
+		infra.WithRedisKeyPrefix(cfg.rateRedisPrefix),
+		infra.WithRedisFailOpen(cfg.rateRedisFailOpen),
+		infra.WithRedisAlgorithm(cfg.rateAlgorithm),
+		infra.WithRedisWindow(cfg.rateWindow),
+		infra.WithRedisOnError(func(err error) { log.Printf("redis limiter error: %v", err) }),
+	)
 }
 
-func getenvInt(k string) (int, bool) {
-	v, ok := os.LookupEnv(k)
-	if !ok || v == "" {
-		return 0, false
-	}
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		return 0, false
-	}
-	return i, true
+// newRedisGlobalStore cria o store do limite global no Redis, em
+// <RATE_REDIS_PREFIX>:global: fora do prefixo dos clientes, nenhuma chave de
+// cliente alcança o bucket global.
+func newRedisGlobalStore(rdb *redis.Client, cfg config) *infra.RedisStore {
+	return infra.NewRedisStore(
+		rdb,
+		cfg.globalRPS,
+		cfg.globalBurst,
+		infra.WithRedisKeyPrefix(cfg.rateRedisPrefix+":global"),
+		infra.WithRedisFailOpen(cfg.rateRedisFailOpen),
+		infra.WithRedisOnError(func(err error) { log.Printf("redis global limiter error: %v", err) }),
+	)
 }
 
-func getenvIsSet(k string) bool {
-	v, ok := os.LookupEnv(k)
-	return ok && v != ""
+// newRedisQuotaStore cria o contador de cotas do período no Redis, em
+// <RATE_REDIS_PREFIX>:q: (os limites ficam isolados pelo nome).
+func newRedisQuotaStore(rdb *redis.Client, cfg config, p domain.QuotaPeriod, limit int) *infra.RedisQuotaStore {
+	return infra.NewRedisQuotaStore(
+		rdb,
+		p,
+		limit,
+		infra.WithRedisKeyPrefix(cfg.rateRedisPrefix),
+		infra.WithRedisFailOpen(cfg.rateRedisFailOpen),
+		infra.WithRedisOnError(func(err error) { log.Printf("redis quota error: %v", err) }),
+	)
 }
 
-func getenvFloatDefault(k string, def float64) float64 {
-	v := os.Getenv(k)
-	if v == "" {
-		return def
-	}
-	f, err := strconv.ParseFloat(v, 64)
-	if err != nil {
-		return def
+// newMemoryStore cria o store em memória do algoritmo configurado e inicia o
+// janitor de chaves inativas.
+func newMemoryStore(ctx context.Context, cfg config) domain.LimiterStore {
+	limit := infra.WindowLimit(cfg.rateRPS, cfg.rateWindow)
+	switch cfg.rateAlgorithm {
+	case infra.AlgorithmGCRA:
+		s := infra.NewGCRAStore(cfg.rateRPS, cfg.rateBurst)
+		s.StartJanitor(ctx)
+		return s
+	case infra.AlgorithmFixedWindow:
+		s := infra.NewFixedWindowStore(limit, cfg.rateWindow)
+		s.StartJanitor(ctx)
+		return s
+	case infra.AlgorithmSlidingWindow:
+		s := infra.NewSlidingCounterStore(limit, cfg.rateWindow)
+		s.StartJanitor(ctx)
+		return s
+	case infra.AlgorithmSlidingWindowLog:
+		s := infra.NewSlidingLogStore(limit, cfg.rateWindow)
+		s.StartJanitor(ctx)
+		return s
+	default:
+		s := infra.NewStore(cfg.rateRPS, cfg.rateBurst)
+		s.StartJanitor(ctx)
+		return s
 	}
-	return f
 }
 
-func getenvBoolDefault(k string, def bool) bool {
-	v := os.Getenv(k)
-	if v == "" {
-		return def
-	}
-	b, err := strconv.ParseBool(v)
-	if err != nil {
-		return def
-	}
-	return b
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-func getenvDurationDefault(k string, def time.Duration) time.Duration {
-	v := os.Getenv(k)
-	if v == "" {
-		return def
-	}
-	d, err := time.ParseDuration(v)
-	if err != nil {
-		return def
+// newRedisClient cria o client e valida a conexão com um PING curto.
+func newRedisClient(addr, password string, db int) (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := rdb.Ping(pingCtx).Result(); err != nil {
+		_ = rdb.Close()
+		return nil, err
 	}
-	return d
+	return rdb, nil
 }
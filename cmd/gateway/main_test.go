@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestRedisGlobalStore_ClientKeyGlobalLeavesGlobalBucket(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":      "http://up",
+		"RATE_STORE":        "redis",
+		"RATE_REDIS_ADDR":   mr.Addr(),
+		"RATE_RPS":          "0.01",
+		"RATE_BURST":        "1",
+		"GLOBAL_RATE_RPS":   "0.01",
+		"GLOBAL_RATE_BURST": "1",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store, global := newRedisStore(rdb, cfg), newRedisGlobalStore(rdb, cfg)
+
+	// um cliente cuja chave é "global" esgota o próprio bucket...
+	if !store.Get(domain.Key("global")).Allow() {
+		t.Fatal("expected first client request to pass")
+	}
+	if store.Get(domain.Key("global")).Allow() {
+		t.Fatal("expected client bucket to be exhausted")
+	}
+	// ...sem gastar o bucket global
+	if !global.Get(domain.Key("global")).Allow() {
+		t.Fatal("expected global bucket to be untouched by client key \"global\"")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+// gatewayMetrics reúne as métricas do /metrics (METRICS_ADDR): os contadores
+// de decisão (um StatsStore), a latência do upstream e, lidos na hora da
+// coleta, os gauges do pool de concorrência.
+type gatewayMetrics struct {
+	stats    *infra.PrometheusStatsStore
+	upstream *infra.PromHistogram
+}
+
+func newGatewayMetrics(cfg config) *gatewayMetrics {
+	return &gatewayMetrics{
+		stats: infra.NewPrometheusStatsStore(
+			infra.WithPromMaxRoutes(cfg.metricsMaxRoutes),
+			infra.WithPromTrackKeys(cfg.metricsTrackKeys),
+		),
+		upstream: infra.NewPromHistogram(
+			"gateway_upstream_duration_seconds",
+			"Latency of requests proxied to the upstream.",
+			nil, "route", "code",
+		),
+	}
+}
+
+// statsStore soma o StatsStore das métricas ao já configurado (ex: Redis).
+func (m *gatewayMetrics) statsStore(stats domain.StatsStore) domain.StatsStore {
+	if stats == nil {
+		return m.stats
+	}
+	return infra.MultiStatsStore{stats, m.stats}
+}
+
+// instrument mede a latência de next (o upstream) por rota e classe de status.
+func (m *gatewayMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		m.upstream.Observe(time.Since(start).Seconds(), m.stats.Route(r.Method, r.URL.Path), statusClass(sw.status))
+	})
+}
+
+// metricsHandler serve o formato texto do Prometheus em GET /metrics.
+func (g *gateway) metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		g.mu.Lock()
+		pool := g.pool
+		g.mu.Unlock()
+
+		w.Header().Set("Content-Type", infra.PromContentType)
+		_ = g.metrics.stats.WritePrometheus(w)
+		_ = g.metrics.upstream.WritePrometheus(w)
+		if pi, ok := pool.(domain.SlotPoolInspector); ok {
+			u := pi.Usage()
+			_ = infra.WritePromGauge(w, "gateway_concurrency_capacity", "Slots in the concurrency pool.", float64(u.Capacity))
+			_ = infra.WritePromGauge(w, "gateway_concurrency_in_flight", "Requests holding a concurrency slot.", float64(u.InUse))
+			_ = infra.WritePromGauge(w, "gateway_concurrency_waiting", "Requests waiting for a concurrency slot.", float64(u.Waiting))
+		}
+		if b := g.statsBuffer; b != nil {
+			_ = infra.WritePromGauge(w, "gateway_stats_queue_length", "Stats events waiting to be written to Redis.", float64(b.Pending()))
+			_ = infra.WritePromCounter(w, "gateway_stats_dropped_total", "Stats events dropped because the queue was full.", float64(b.Dropped()))
+			_ = infra.WritePromCounter(w, "gateway_stats_failed_total", "Stats events lost in failed writes to Redis.", float64(b.Failed()))
+		}
+	})
+	return mux
+}
+
+// statusWriter guarda o status e o tamanho da resposta. Unwrap mantém Flush
+// e afins disponíveis via http.ResponseController (streaming do proxy).
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusClass agrupa o status em 2xx, 4xx... (cardinalidade baixa).
+func statusClass(code int) string {
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
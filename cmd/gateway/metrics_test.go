@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func TestGateway_MetricsEndpoint(t *testing.T) {
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":        "http://up",
+		"RATE_RPS":            "0.01",
+		"RATE_BURST":          "2",
+		"CONCURRENCY_MAX":     "1",
+		"CONCURRENCY_TIMEOUT": "10ms",
+		"METRICS_ADDR":        "127.0.0.1:0",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-unblock
+		}
+		w.WriteHeader(http.StatusTeapot)
+	})
+	m := newGatewayMetrics(cfg)
+	gw := newGateway(upstream, infra.NewStore(cfg.rateRPS, cfg.rateBurst), m.statsStore(nil))
+	gw.metrics = m
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(ip, path string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example"+path, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w.Code
+	}
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		gw.metricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if ct := w.Header().Get("Content-Type"); ct != infra.PromContentType {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		b, _ := io.ReadAll(w.Body)
+		return string(b)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do("10.0.0.9", "/slow")
+	}()
+	<-entered
+	if out := scrape(); !strings.Contains(out, "gateway_concurrency_in_flight 1\n") {
+		t.Fatalf("expected one request in flight:\n%s", out)
+	}
+	if code := do("10.0.0.8", "/items/1"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with the pool full, got %d", code)
+	}
+	close(unblock)
+	<-done
+
+	do("10.0.0.1", "/items/1")
+	do("10.0.0.1", "/items/2")
+	if code := do("10.0.0.1", "/items/3"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+
+	out := scrape()
+	// a recusa por falta de vaga não é uma segunda decisão
+	if strings.Contains(out, `ratelimit_requests_total{route="GET /items/:id",outcome="concurrency"}`) {
+		t.Fatalf("expected the concurrency rejection out of ratelimit_requests_total:\n%s", out)
+	}
+	for _, want := range []string{
+		`ratelimit_requests_total{route="GET /items/:id",outcome="allowed"} 3`,
+		`ratelimit_requests_total{route="GET /items/:id",outcome="denied"} 1`,
+		`ratelimit_followup_total{route="GET /items/:id",outcome="concurrency"} 1`,
+		`gateway_upstream_duration_seconds_count{route="GET /items/:id",code="4xx"} 2`,
+		`gateway_upstream_duration_seconds_count{route="GET /slow",code="4xx"} 1`,
+		"gateway_concurrency_in_flight 0",
+		"gateway_concurrency_capacity 1",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Fatalf("expected %q in metrics:\n%s", want, out)
+		}
+	}
+}
+
+func TestGateway_AsyncStats(t *testing.T) {
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":              "http://up",
+		"RATE_RPS":                  "0.01",
+		"RATE_BURST":                "1",
+		"METRICS_ADDR":              "127.0.0.1:0",
+		"RATE_STATS_FLUSH_INTERVAL": "1h",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.rateStatsAsync || cfg.rateStatsBatchSize != 500 || cfg.rateStatsQueueSize != 10000 {
+		t.Fatalf("expected async stats by default, got async=%v batch=%d queue=%d", cfg.rateStatsAsync, cfg.rateStatsBatchSize, cfg.rateStatsQueueSize)
+	}
+	if _, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":          "http://up",
+		"RATE_STATS_QUEUE_SIZE": "0",
+	}, nil)); err == nil || !strings.Contains(err.Error(), "RATE_STATS_QUEUE_SIZE") {
+		t.Fatalf("expected RATE_STATS_QUEUE_SIZE to be validated, got %v", err)
+	}
+
+	stats := infra.NewMemoryStatsStore()
+	buf := infra.NewBufferedStatsStore(stats, infra.WithBufferFlushEvery(cfg.rateStatsFlushInterval), infra.WithBufferQueueSize(1))
+	m := newGatewayMetrics(cfg)
+	gw := newGateway(http.NotFoundHandler(), infra.NewStore(cfg.rateRPS, cfg.rateBurst), m.statsStore(buf))
+	gw.metrics = m
+	gw.statsBuffer = buf
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		gw.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	w := httptest.NewRecorder()
+	gw.metricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := w.Body.String()
+	for _, want := range []string{
+		`ratelimit_requests_total{route="GET /",outcome="denied"} 2`,
+		"# TYPE gateway_stats_dropped_total counter",
+		"gateway_stats_queue_length ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, out)
+		}
+	}
+
+	if err := buf.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	// fila de 1: o que não coube foi descartado, o resto gravado no Close
+	if tot := stats.Total(); tot.Allowed+tot.Denied+int64(buf.Dropped()) != 3 || tot.Allowed+tot.Denied == 0 {
+		t.Fatalf("expected queued events flushed on close, got %+v (dropped %d)", tot, buf.Dropped())
+	}
+}
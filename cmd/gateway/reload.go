@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloader relê a configuração (.env + ambiente + arquivos de política/tokens)
+// e aplica no gateway. Falhas são logadas e a configuração anterior continua.
+type reloader struct {
+	gw          *gateway
+	envFile     string
+	envRequired bool
+}
+
+func (rl *reloader) reload(reason string) bool {
+	dotenv, err := loadDotEnv(rl.envFile, rl.envRequired)
+	if err != nil {
+		log.Printf("config reload (%s) failed, keeping previous config: %v", reason, err)
+		return false
+	}
+	cfg, err := loadConfig(newConfigReader(newEnvSource(dotenv)))
+	if err != nil {
+		log.Printf("config reload (%s) failed, keeping previous config:\n%v", reason, err)
+		return false
+	}
+
+	old := rl.gw.config()
+	if changed := restartOnlyChanges(old, cfg); len(changed) > 0 {
+		log.Printf("config reload (%s): changes to %s require a restart and were ignored", reason, strings.Join(changed, ", "))
+	}
+	keepRestartOnly(old, &cfg)
+	if err := rl.gw.apply(cfg); err != nil {
+		log.Printf("config reload (%s) failed, keeping previous config: %v", reason, err)
+		return false
+	}
+
+	log.Printf("config reloaded (%s): rps=%.3f burst=%d tokens=%d rules=%d blockDuration=%s concurrency=%d",
+		reason, cfg.rateRPS, cfg.rateBurst, len(cfg.tokenPolicies), len(cfg.policyFile.Rules), cfg.blockDuration, cfg.concurrencyMax)
+	return true
+}
+
+// watchedFiles são os arquivos cuja alteração dispara reload.
+func (rl *reloader) watchedFiles() []string {
+	cfg := rl.gw.config()
+	files := []string{rl.envFile}
+	for _, f := range []string{cfg.policyFilePath, cfg.tokensFilePath, cfg.rejectTemplatePath, cfg.jwtJWKSPath, cfg.apiKeysFilePath, cfg.accessFilePath} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// run recarrega a configuração ao receber SIGHUP e, se interval > 0,
+// quando algum arquivo observado mudar (polling de mtime/tamanho).
+func (rl *reloader) run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	seen := statFiles(rl.watchedFiles())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			rl.reload("SIGHUP")
+			seen = statFiles(rl.watchedFiles())
+		case <-tick:
+			now := statFiles(rl.watchedFiles())
+			if !sameFiles(seen, now) {
+				rl.reload("file change")
+			}
+			// mesmo se o reload falhar, só tenta de novo na próxima alteração
+			seen = now
+		}
+	}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFiles(paths []string) map[string]fileStamp {
+	out := make(map[string]fileStamp, len(paths))
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			out[p] = fileStamp{}
+			continue
+		}
+		out[p] = fileStamp{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+	}
+	return out
+}
+
+func sameFiles(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, st := range a {
+		if other, ok := b[p]; !ok || other != st {
+			return false
+		}
+	}
+	return true
+}
+
+// restartOnlyChanges lista as variáveis alteradas que só têm efeito ao
+// reiniciar o processo (listener, upstream e conexões com backends).
+func restartOnlyChanges(old, cur config) []string {
+	var out []string
+	check := func(name string, changed bool) {
+		if changed {
+			out = append(out, name)
+		}
+	}
+	check("LISTEN_ADDR", old.listenAddr != cur.listenAddr)
+	check("UPSTREAM_URL", old.upstreamURL != cur.upstreamURL)
+	check("ADMIN_ADDR", old.adminAddr != cur.adminAddr)
+	check("METRICS_*", old.metricsAddr != cur.metricsAddr || old.metricsMaxRoutes != cur.metricsMaxRoutes ||
+		old.metricsTrackKeys != cur.metricsTrackKeys)
+	check("RATE_STORE", old.rateStore != cur.rateStore)
+	check("RATE_ALGORITHM", old.rateAlgorithm != cur.rateAlgorithm)
+	check("RATE_WINDOW", old.rateWindow != cur.rateWindow)
+	check("RATE_REDIS_*", old.rateRedisAddr != cur.rateRedisAddr || old.rateRedisPassword != cur.rateRedisPassword ||
+		old.rateRedisDB != cur.rateRedisDB || old.rateRedisPrefix != cur.rateRedisPrefix || old.rateRedisFailOpen != cur.rateRedisFailOpen)
+	check("RATE_API_KEYS", old.apiKeysBackend != cur.apiKeysBackend && (old.apiKeysBackend == "redis" || cur.apiKeysBackend == "redis"))
+	check("RATE_API_KEYS_REDIS_PREFIX/CACHE_TTL", old.apiKeysRedisPrefix != cur.apiKeysRedisPrefix || old.apiKeysCacheTTL != cur.apiKeysCacheTTL)
+	check("RATE_STATS_*", old.rateStatsEnabled != cur.rateStatsEnabled || old.rateStatsRedisAddr != cur.rateStatsRedisAddr ||
+		old.rateStatsRedisPassword != cur.rateStatsRedisPassword || old.rateStatsRedisDB != cur.rateStatsRedisDB ||
+		old.rateStatsPrefix != cur.rateStatsPrefix || old.rateStatsTTL != cur.rateStatsTTL ||
+		old.rateStatsBucket != cur.rateStatsBucket || old.rateStatsTrackKeys != cur.rateStatsTrackKeys ||
+		old.rateStatsAsync != cur.rateStatsAsync || old.rateStatsFlushInterval != cur.rateStatsFlushInterval ||
+		old.rateStatsBatchSize != cur.rateStatsBatchSize || old.rateStatsQueueSize != cur.rateStatsQueueSize)
+	check("TRACE_*", old.traceExporter != cur.traceExporter || old.traceOTLPEndpoint != cur.traceOTLPEndpoint ||
+		old.traceFile != cur.traceFile || old.traceSampleRatio != cur.traceSampleRatio || old.traceServiceName != cur.traceServiceName)
+	check("ACCESS_LOG_*", old.accessLog != cur.accessLog || old.accessLogFile != cur.accessLogFile ||
+		old.accessLogMaxSizeMB != cur.accessLogMaxSizeMB || old.accessLogMaxBackups != cur.accessLogMaxBackups ||
+		old.accessLogSampleRatio != cur.accessLogSampleRatio || old.accessLogLevel != cur.accessLogLevel ||
+		old.accessLogRejectedLevel != cur.accessLogRejectedLevel)
+	check("CONFIG_WATCH_INTERVAL", old.watchInterval != cur.watchInterval)
+	return out
+}
+
+// keepRestartOnly copia para cur os valores atuais das variáveis de
+// restartOnlyChanges, para que o reload aplique só o que é recarregável
+// (ex: trocar RATE_API_KEYS de file para redis não pode desligar as keys).
+func keepRestartOnly(old config, cur *config) {
+	cur.listenAddr = old.listenAddr
+	cur.upstreamURL = old.upstreamURL
+	cur.adminAddr = old.adminAddr
+	cur.metricsAddr, cur.metricsMaxRoutes, cur.metricsTrackKeys = old.metricsAddr, old.metricsMaxRoutes, old.metricsTrackKeys
+	cur.rateStore = old.rateStore
+	cur.rateAlgorithm = old.rateAlgorithm
+	cur.rateWindow = old.rateWindow
+	cur.rateRedisAddr, cur.rateRedisPassword, cur.rateRedisDB = old.rateRedisAddr, old.rateRedisPassword, old.rateRedisDB
+	cur.rateRedisPrefix, cur.rateRedisFailOpen = old.rateRedisPrefix, old.rateRedisFailOpen
+	if old.apiKeysBackend != cur.apiKeysBackend && (old.apiKeysBackend == "redis" || cur.apiKeysBackend == "redis") {
+		cur.apiKeysBackend, cur.apiKeysFilePath, cur.apiKeysFile = old.apiKeysBackend, old.apiKeysFilePath, old.apiKeysFile
+	}
+	cur.apiKeysRedisPrefix, cur.apiKeysCacheTTL = old.apiKeysRedisPrefix, old.apiKeysCacheTTL
+	cur.rateStatsEnabled, cur.rateStatsRedisAddr, cur.rateStatsRedisPassword = old.rateStatsEnabled, old.rateStatsRedisAddr, old.rateStatsRedisPassword
+	cur.rateStatsRedisDB, cur.rateStatsPrefix, cur.rateStatsTTL = old.rateStatsRedisDB, old.rateStatsPrefix, old.rateStatsTTL
+	cur.rateStatsBucket, cur.rateStatsTrackKeys = old.rateStatsBucket, old.rateStatsTrackKeys
+	cur.rateStatsAsync, cur.rateStatsFlushInterval = old.rateStatsAsync, old.rateStatsFlushInterval
+	cur.rateStatsBatchSize, cur.rateStatsQueueSize = old.rateStatsBatchSize, old.rateStatsQueueSize
+	cur.traceExporter, cur.traceOTLPEndpoint, cur.traceFile = old.traceExporter, old.traceOTLPEndpoint, old.traceFile
+	cur.traceSampleRatio, cur.traceServiceName = old.traceSampleRatio, old.traceServiceName
+	cur.accessLog, cur.accessLogFile = old.accessLog, old.accessLogFile
+	cur.accessLogMaxSizeMB, cur.accessLogMaxBackups = old.accessLogMaxSizeMB, old.accessLogMaxBackups
+	cur.accessLogSampleRatio, cur.accessLogLevel, cur.accessLogRejectedLevel = old.accessLogSampleRatio, old.accessLogLevel, old.accessLogRejectedLevel
+	cur.watchInterval = old.watchInterval
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func TestReloader_AppliesNewLimitsAndKeepsPreviousOnError(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	writeEnv := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(envFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeEnv("UPSTREAM_URL=http://up\nRATE_RPS=0.01\nRATE_BURST=1\n")
+	dotenv, err := loadDotEnv(envFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(newConfigReader(envSource{dotenv: dotenv}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	store := infra.NewStore(cfg.rateRPS, cfg.rateBurst)
+	gw := newGateway(upstream, store, nil)
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(ip string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if do("10.0.0.1") != http.StatusOK || do("10.0.0.1") != http.StatusTooManyRequests {
+		t.Fatalf("expected burst=1 to allow one request")
+	}
+
+	rl := &reloader{gw: gw, envFile: envFile, envRequired: true}
+
+	// config inválida: mantém a anterior
+	writeEnv("UPSTREAM_URL=http://up\nRATE_RPS=abc\nRATE_BURST=1\n")
+	if rl.reload("test") {
+		t.Fatalf("expected reload with invalid config to fail")
+	}
+	if gw.config().rateRPS != 0.01 {
+		t.Fatalf("expected previous config to stay active, got rps=%v", gw.config().rateRPS)
+	}
+
+	writeEnv("UPSTREAM_URL=http://up\nRATE_RPS=0.01\nRATE_BURST=3\n")
+	if !rl.reload("test") {
+		t.Fatalf("expected reload to succeed")
+	}
+	if store.Burst() != 3 {
+		t.Fatalf("expected store defaults to be updated, got burst=%d", store.Burst())
+	}
+	for i := 0; i < 3; i++ {
+		if code := do("10.0.0.2"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 with burst=3, got %d", i+1, code)
+		}
+	}
+	if code := do("10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after burst=3, got %d", code)
+	}
+}
+
+func TestRestartOnlyChanges(t *testing.T) {
+	old := config{listenAddr: ":8080", rateRPS: 10, rateRedisDB: 0}
+	cur := config{listenAddr: ":9090", rateRPS: 20, rateRedisDB: 1}
+
+	got := restartOnlyChanges(old, cur)
+	if len(got) != 2 || got[0] != "LISTEN_ADDR" || got[1] != "RATE_REDIS_*" {
+		t.Fatalf("unexpected restart-only changes: %v", got)
+	}
+}
+
+func TestKeepRestartOnly(t *testing.T) {
+	old := config{listenAddr: ":8080", rateRPS: 10, apiKeysBackend: "file", adminAddr: ""}
+	cur := config{
+		listenAddr:       ":9090",
+		rateRPS:          20,
+		apiKeysBackend:   "redis",
+		adminAddr:        ":9091",
+		metricsAddr:      ":9100",
+		rateStore:        "redis",
+		rateRedisPrefix:  "x",
+		rateStatsEnabled: true,
+		rateStatsAsync:   true,
+		traceExporter:    "stdout",
+		accessLog:        "stdout",
+		watchInterval:    time.Second,
+	}
+	keepRestartOnly(old, &cur)
+	if changed := restartOnlyChanges(old, cur); len(changed) != 0 {
+		t.Fatalf("expected restart-only settings kept, still changed: %v", changed)
+	}
+	if cur.rateRPS != 20 || cur.apiKeysBackend != "file" {
+		t.Fatalf("expected reloadable settings applied and the API key backend kept, got rps=%v backend=%q", cur.rateRPS, cur.apiKeysBackend)
+	}
+}
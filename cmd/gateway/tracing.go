@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracePropagator lê e escreve o traceparent/tracestate (W3C Trace Context).
+var tracePropagator = propagation.TraceContext{}
+
+// tracerName identifica os spans do gateway e dos middlewares.
+const tracerName = "middleware-gateway"
+
+// readTracing lê TRACE_*.
+func readTracing(r *configReader, cfg *config) {
+	cfg.traceExporter = strings.ToLower(strings.TrimSpace(r.string("TRACE_EXPORTER", "none")))
+	cfg.traceOTLPEndpoint = r.string("TRACE_OTLP_ENDPOINT", "http://localhost:4318")
+	cfg.traceFile = r.string("TRACE_FILE", "")
+	cfg.traceSampleRatio = r.float("TRACE_SAMPLE_RATIO", 1)
+	cfg.traceServiceName = r.string("TRACE_SERVICE_NAME", "middleware-gateway")
+
+	switch cfg.traceExporter {
+	case "", "none", "otlp", "stdout":
+	case "file":
+		if strings.TrimSpace(cfg.traceFile) == "" {
+			r.failf("TRACE_FILE is required when TRACE_EXPORTER=file")
+		}
+	default:
+		r.failf("TRACE_EXPORTER: %q is not supported (use none, otlp, stdout or file)", cfg.traceExporter)
+	}
+	if cfg.traceSampleRatio < 0 || cfg.traceSampleRatio > 1 {
+		r.failf("TRACE_SAMPLE_RATIO must be between 0 and 1")
+	}
+}
+
+func (cfg config) tracingEnabled() bool {
+	return cfg.traceExporter != "" && cfg.traceExporter != "none"
+}
+
+// newSpanExporter escolhe o exporter de TRACE_EXPORTER. O closer (nil se não
+// houver) fecha o arquivo de TRACE_FILE depois do shutdown do provider.
+func newSpanExporter(ctx context.Context, cfg config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.traceExporter {
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.traceOTLPEndpoint, "/")+"/v1/traces"))
+		return exp, nil, err
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exp, nil, err
+	case "file":
+		f, err := os.OpenFile(cfg.traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		return exp, f, nil
+	}
+	return nil, nil, fmt.Errorf("tracing is disabled")
+}
+
+// newTracerProvider monta o provider com exp: envio em lote, amostragem por
+// TRACE_SAMPLE_RATIO respeitando a decisão do traceparent de entrada.
+func newTracerProvider(cfg config, exp sdktrace.SpanExporter, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.traceSampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.traceServiceName))),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// traced abre o span de servidor da requisição, continuando o traceparent
+// recebido, e o coloca no contexto para os spans dos middlewares.
+func traced(tracer trace.Tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// tracingTransport cria o span de cliente da chamada ao upstream (até os
+// headers da resposta) e propaga o traceparent nele.
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "upstream "+req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	tracePropagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit"
+	"middleware-gateway/middleware/ratelimit/infra"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGateway_TracingPropagatesTraceparent(t *testing.T) {
+	var upstreamTraceparent string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+	}))
+	defer up.Close()
+
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":   up.URL,
+		"RATE_RPS":       "0.01",
+		"RATE_BURST":     "1",
+		"TRACE_EXPORTER": "stdout",
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(cfg, exp)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	tracer := tp.Tracer(tracerName)
+
+	target, _ := url.Parse(up.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = tracingTransport{base: http.DefaultTransport, tracer: tracer}
+	gw := newGateway(proxy, infra.NewStore(cfg.rateRPS, cfg.rateBurst), nil)
+	gw.tracer = tracer
+	if err := gw.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	h := traced(tracer, gw)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	do := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/items", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := do(); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+	_ = tp.ForceFlush(context.Background())
+
+	if !strings.Contains(upstreamTraceparent, traceID) {
+		t.Fatalf("expected the trace to continue upstream, got traceparent %q", upstreamTraceparent)
+	}
+	names := map[string]int{}
+	for _, s := range exp.GetSpans().Snapshots() {
+		if s.SpanContext().TraceID().String() != traceID {
+			t.Fatalf("span %q is not part of the incoming trace", s.Name())
+		}
+		names[s.Name()]++
+	}
+	if names["GET"] != 2 || names[ratelimit.SpanDecision] != 2 || names[ratelimit.SpanAcquire] != 1 || names["upstream GET"] != 1 {
+		t.Fatalf("unexpected spans: %v", names)
+	}
+	if upstreamTraceparent == "00-"+traceID+"-00f067aa0ba902b7-01" {
+		t.Fatalf("expected the upstream span to be the new parent")
+	}
+}
+
+func TestNewSpanExporter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	cfg, err := loadConfig(testReader(map[string]string{
+		"UPSTREAM_URL":   "http://up",
+		"TRACE_EXPORTER": "file",
+		"TRACE_FILE":     path,
+	}, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp, closer, err := newSpanExporter(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := newTracerProvider(cfg, exp)
+	_, span := tp.Tracer(tracerName).Start(context.Background(), "offline")
+	span.End()
+	_ = tp.Shutdown(context.Background())
+	_ = closer.Close()
+
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), `"Name":"offline"`) {
+		t.Fatalf("expected span written to the file, got %q", b)
+	}
+
+	for env, want := range map[string]string{"file": "TRACE_FILE", "zipkin": "TRACE_EXPORTER"} {
+		_, err := loadConfig(testReader(map[string]string{"UPSTREAM_URL": "http://up", "TRACE_EXPORTER": env}, nil))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("TRACE_EXPORTER=%s: expected error about %s, got %v", env, want, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Access é o resultado da lista de acesso para uma requisição.
+type Access string
+
+const (
+	// AccessNone: o cliente não está em nenhuma lista; segue o rate limit.
+	AccessNone Access = ""
+	// AccessAllow: o cliente passa sem nenhum limite (ex: health checks).
+	AccessAllow Access = "allow"
+	// AccessDeny: o cliente é recusado na hora com 403.
+	AccessDeny Access = "deny"
+)
+
+// AccessFile é o formato do arquivo de listas de acesso (YAML ou JSON):
+//
+//	allow:
+//	  ips: [10.0.0.0/8, 127.0.0.1]   # IPs ou CIDRs
+//	  keys: [healthcheck-token]      # valores do header de chave
+//	deny:
+//	  ips: [203.0.113.7, 2001:db8::/32]
+//	  keys: [revoked-key]
+//
+// A denylist tem precedência: um IP negado dentro de um CIDR liberado
+// continua negado.
+type AccessFile struct {
+	Allow AccessRules `yaml:"allow" json:"allow"`
+	Deny  AccessRules `yaml:"deny" json:"deny"`
+}
+
+type AccessRules struct {
+	IPs  []string `yaml:"ips" json:"ips"`
+	Keys []string `yaml:"keys" json:"keys"`
+}
+
+// LoadAccessFile lê e valida um arquivo de listas de acesso (YAML; JSON
+// também é aceito).
+func LoadAccessFile(file string) (AccessFile, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return AccessFile{}, err
+	}
+	return ParseAccessFile(b)
+}
+
+func ParseAccessFile(b []byte) (AccessFile, error) {
+	var af AccessFile
+	if err := yaml.Unmarshal(b, &af); err != nil {
+		return AccessFile{}, err
+	}
+	if _, err := compileAccessRules("allow", af.Allow); err != nil {
+		return AccessFile{}, err
+	}
+	if _, err := compileAccessRules("deny", af.Deny); err != nil {
+		return AccessFile{}, err
+	}
+	return af, nil
+}
+
+// AccessOptions configura como a AccessList identifica o cliente.
+type AccessOptions struct {
+	// KeyHeaders são os headers comparados com as keys das listas (ex:
+	// X-API-Key); o primeiro que vier na requisição vale.
+	KeyHeaders         []string
+	TrustXForwardedFor bool
+	// TrustedProxies (opcional): se definido, o IP vem de ClientIP e
+	// TrustXForwardedFor é ignorado. Sem ele, um cliente pode forjar o
+	// X-Forwarded-For para cair na allowlist.
+	TrustedProxies *TrustedProxies
+}
+
+// AccessList decide allow/deny por IP, CIDR ou key antes do rate limit.
+type AccessList struct {
+	allow, deny accessRules
+	keyHeaders  []string
+	ipFn        KeyFunc
+}
+
+// accessRules reaproveita TrustedProxies para casar IPs e CIDRs.
+type accessRules struct {
+	ips  *TrustedProxies
+	keys map[string]bool
+}
+
+// NewAccessList compila as listas do arquivo.
+func NewAccessList(af AccessFile, opts AccessOptions) (*AccessList, error) {
+	allow, err := compileAccessRules("allow", af.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compileAccessRules("deny", af.Deny)
+	if err != nil {
+		return nil, err
+	}
+	al := &AccessList{allow: allow, deny: deny, ipFn: DefaultKeyFunc("", opts.TrustXForwardedFor)}
+	if opts.TrustedProxies != nil {
+		al.ipFn = ProxyKeyFunc("", opts.TrustedProxies)
+	}
+	for _, h := range opts.KeyHeaders {
+		if h = strings.TrimSpace(h); h != "" {
+			al.keyHeaders = append(al.keyHeaders, h)
+		}
+	}
+	return al, nil
+}
+
+// Len é o número de entradas das duas listas.
+func (al *AccessList) Len() int {
+	if al == nil {
+		return 0
+	}
+	return al.allow.len() + al.deny.len()
+}
+
+// Check classifica a requisição e retorna também quem casou com a lista (o
+// IP ou "apikey:<fingerprint>" da key do cliente, nunca a key em si), usado
+// como chave nas estatísticas, no access log e no span.
+func (al *AccessList) Check(r *http.Request) (Access, string) {
+	if al.Len() == 0 {
+		return AccessNone, ""
+	}
+
+	ipStr := al.ipFn(r)
+	ip, hasIP := parseHop(ipStr)
+	key := ""
+	for _, h := range al.keyHeaders {
+		if v := strings.TrimSpace(r.Header.Get(h)); v != "" {
+			key = v
+			break
+		}
+	}
+
+	for _, rules := range []struct {
+		access Access
+		rules  accessRules
+	}{{AccessDeny, al.deny}, {AccessAllow, al.allow}} {
+		if key != "" && rules.rules.keys[key] {
+			return rules.access, apiKeyPrefix + APIKeyFingerprint(key)
+		}
+		if hasIP && rules.rules.ips.Contains(ip) {
+			return rules.access, ipStr
+		}
+	}
+	return AccessNone, ""
+}
+
+func compileAccessRules(list string, rules AccessRules) (accessRules, error) {
+	ips, err := ParseTrustedProxies(strings.Join(rules.IPs, ","))
+	if err != nil {
+		return accessRules{}, fmt.Errorf("%s: %w", list, err)
+	}
+	out := accessRules{ips: ips}
+	for _, k := range rules.Keys {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return accessRules{}, fmt.Errorf("%s: empty key", list)
+		}
+		if out.keys == nil {
+			out.keys = make(map[string]bool, len(rules.Keys))
+		}
+		out.keys[k] = true
+	}
+	return out, nil
+}
+
+func (ar accessRules) len() int {
+	return ar.ips.Len() + len(ar.keys)
+}
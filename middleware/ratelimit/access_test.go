@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+const testAccessYAML = `
+allow:
+  ips: [10.0.0.0/8, "::1"]
+  keys: [healthcheck]
+deny:
+  ips: [10.6.6.6, 203.0.113.0/24]
+  keys: [revoked]
+`
+
+func TestAccessList_DenyWinsOverAllow(t *testing.T) {
+	af, err := ParseAccessFile([]byte(testAccessYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	al, err := NewAccessList(af, AccessOptions{KeyHeaders: []string{"X-API-Key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remote, key string
+		want        Access
+		who         string
+	}{
+		{"10.1.2.3:80", "", AccessAllow, "10.1.2.3"},
+		{"[::1]:80", "", AccessAllow, "::1"},
+		{"10.6.6.6:80", "", AccessDeny, "10.6.6.6"},
+		{"203.0.113.9:80", "healthcheck", AccessDeny, "203.0.113.9"},
+		{"192.0.2.1:80", "healthcheck", AccessAllow, "apikey:" + APIKeyFingerprint("healthcheck")},
+		{"10.1.2.3:80", "revoked", AccessDeny, "apikey:" + APIKeyFingerprint("revoked")},
+		{"192.0.2.1:80", "other", AccessNone, ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.key != "" {
+			r.Header.Set("X-API-Key", tc.key)
+		}
+		if got, who := al.Check(r); got != tc.want || who != tc.who {
+			t.Fatalf("%s %q: expected %q (%q), got %q (%q)", tc.remote, tc.key, tc.want, tc.who, got, who)
+		}
+	}
+}
+
+func TestAccessList_UsesTrustedProxiesForClientIP(t *testing.T) {
+	tp, _ := ParseTrustedProxies("192.168.0.0/16")
+	al, err := NewAccessList(AccessFile{Deny: AccessRules{IPs: []string{"198.51.100.7"}}}, AccessOptions{TrustedProxies: tp})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r.RemoteAddr = "192.168.1.1:80"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got, _ := al.Check(r); got != AccessDeny {
+		t.Fatalf("expected the forwarded client to be denied, got %q", got)
+	}
+
+	r.RemoteAddr = "172.16.0.1:80"
+	if got, _ := al.Check(r); got != AccessNone {
+		t.Fatalf("expected X-Forwarded-For from an untrusted peer to be ignored, got %q", got)
+	}
+}
+
+func TestParseAccessFile_RejectsInvalidEntries(t *testing.T) {
+	for _, in := range []string{
+		"allow:\n  ips: [10.0.0.0/33]\n",
+		"deny:\n  ips: [not-an-ip]\n",
+		"deny:\n  keys: [\"\"]\n",
+	} {
+		if _, err := ParseAccessFile([]byte(in)); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+type rejectAllIdentifier struct{}
+
+func (rejectAllIdentifier) Identify(*http.Request) (Identity, error) {
+	return Identity{}, errors.New("no credentials")
+}
+
+func TestMiddleware_AccessListBypassesAndDenies(t *testing.T) {
+	af, _ := ParseAccessFile([]byte(testAccessYAML))
+	al, _ := NewAccessList(af, AccessOptions{})
+	stats := infra.NewMemoryStatsStore()
+	h := Middleware(Options{
+		Store:  infra.NewStore(0.01, 1),
+		Stats:  stats,
+		Access: al,
+		// as listas vêm antes do Identifier
+		Identifier: rejectAllIdentifier{},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	call := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://example/health", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := call("10.0.0.1:80"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected allowlisted client to bypass the limit, got %d", i+1, w.Code)
+		}
+	}
+	w := call("10.6.6.6:80")
+	if w.Code != http.StatusForbidden || w.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected 403 without Retry-After for denylisted client, got %d %v", w.Code, w.Header())
+	}
+	if w := call("192.0.2.1:80"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected other clients to go through the identifier, got %d", w.Code)
+	}
+
+	got := stats.ByOutcome()
+	if got[domain.OutcomeAllowlisted] != 3 || got[domain.OutcomeDenylisted] != 1 {
+		t.Fatalf("unexpected outcome counters: %v", got)
+	}
+	if total := stats.Total(); total.Allowed != 3 || total.Denied != 1 {
+		t.Fatalf("unexpected totals: %+v", total)
+	}
+	if !strings.Contains(w.Body.String(), http.StatusText(http.StatusForbidden)) {
+		t.Fatalf("expected default deny body, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// APIKeyOptions configura o APIKeyIdentifier.
+type APIKeyOptions struct {
+	// Header de onde vem a key (padrão X-API-Key).
+	Header string
+	Keys   domain.APIKeyStore
+	// RejectUnknown recusa com 401 keys desconhecidas, desabilitadas ou
+	// expiradas; senão elas caem no limite por IP.
+	RejectUnknown bool
+	// OnError (opcional) recebe erros do registro. Nesse caso a requisição
+	// cai no limite por IP (nunca é recusada por falha do registro).
+	OnError func(error)
+}
+
+// APIKeyIdentifier é um Identifier que só aceita keys ativas do registro
+// (domain.APIKeyStore), com o limite de cada uma. A chave do limite é
+// "apikey:<impressão>" (APIKeyFingerprint): o segredo não vai para o Redis, a
+// API admin, as estatísticas, os traces nem o log de acesso.
+type APIKeyIdentifier struct {
+	header  string
+	keys    domain.APIKeyStore
+	reject  bool
+	onError func(error)
+	now     func() time.Time
+}
+
+const apiKeyPrefix = "apikey:"
+
+// APIKeyFingerprint é a impressão da key usada na chave do limite: os 16
+// primeiros dígitos hex do SHA-256 (ex: para achar a key na API admin).
+func APIKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func NewAPIKeyIdentifier(opts APIKeyOptions) *APIKeyIdentifier {
+	a := &APIKeyIdentifier{
+		header:  opts.Header,
+		keys:    opts.Keys,
+		reject:  opts.RejectUnknown,
+		onError: opts.OnError,
+		now:     time.Now,
+	}
+	if a.header == "" {
+		a.header = "X-API-Key"
+	}
+	return a
+}
+
+// Identify implementa Identifier.
+func (a *APIKeyIdentifier) Identify(r *http.Request) (Identity, error) {
+	raw := strings.TrimSpace(r.Header.Get(a.header))
+	if raw == "" {
+		return Identity{}, nil
+	}
+	k, ok, err := a.lookup(r, raw)
+	switch {
+	case err != nil:
+		return Identity{}, nil
+	case !ok:
+		if a.reject {
+			return Identity{}, &AuthError{Err: errors.New("unknown or inactive API key")}
+		}
+		return Identity{}, nil
+	}
+
+	id := Identity{Key: apiKeyPrefix + APIKeyFingerprint(k.Key)}
+	if k.RPS > 0 {
+		id.Policy = &domain.Policy{Name: "apikey", RPS: k.RPS, Burst: k.Burst}
+	}
+	return id, nil
+}
+
+// lookup busca a key e informa se ela está ativa agora. O resultado fica no
+// contexto da requisição (withAPIKeyMemo): Identify, a chave e a política da
+// cota consultam o registro uma vez só.
+func (a *APIKeyIdentifier) lookup(r *http.Request, key string) (domain.APIKey, bool, error) {
+	if a.keys == nil {
+		return domain.APIKey{}, false, nil
+	}
+	memo, _ := r.Context().Value(apiKeyMemoKey{}).(apiKeyMemo)
+	res, hit := memo[key]
+	if !hit {
+		res.key, res.ok, res.err = a.keys.LookupAPIKey(r.Context(), key)
+		if res.err != nil && a.onError != nil {
+			a.onError(res.err)
+		}
+		if memo != nil {
+			memo[key] = res
+		}
+	}
+	if res.err != nil {
+		return domain.APIKey{}, false, res.err
+	}
+	return res.key, res.ok && res.key.Active(a.now()), nil
+}
+
+// apiKeyMemo guarda as buscas no registro feitas durante uma requisição.
+type apiKeyMemo map[string]apiKeyLookup
+
+type apiKeyLookup struct {
+	key domain.APIKey
+	ok  bool
+	err error
+}
+
+type apiKeyMemoKey struct{}
+
+// withAPIKeyMemo devolve ctx com um apiKeyMemo vazio (o Middleware cria um
+// por requisição).
+func withAPIKeyMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiKeyMemoKey{}, apiKeyMemo{})
+}
+
+// QuotaLimit é o Limit da cota diária das keys (domain.APIKey.DailyQuota),
+// para Options.Limits. store deve contar por dia UTC (ex:
+// infra.NewQuotaStore(domain.QuotaDay, n) ou RedisQuotaStore; também serve uma
+// janela fixa de 24h): a cota de cada key vira a política do contador dela.
+// Keys sem cota não passam por este limite, e a rejeição sai com
+// ReasonQuotaExceeded.
+func (a *APIKeyIdentifier) QuotaLimit(name string, store domain.LimiterStore) Limit {
+	return Limit{
+		Name: name,
+		KeyFn: func(r *http.Request) string {
+			k, ok := a.quotaKey(r)
+			if !ok {
+				return ""
+			}
+			return apiKeyPrefix + APIKeyFingerprint(k.Key)
+		},
+		PolicyFn: func(r *http.Request) (domain.Policy, bool) {
+			// a cota diária vira uma política de req/s médio em 24h
+			k, ok := a.quotaKey(r)
+			if !ok {
+				return domain.Policy{}, false
+			}
+			return domain.Policy{
+				Name:  "daily",
+				RPS:   float64(k.DailyQuota) / (24 * time.Hour).Seconds(),
+				Burst: k.DailyQuota,
+			}, true
+		},
+		Store:  store,
+		Reason: ReasonQuotaExceeded,
+	}
+}
+
+// quotaKey é a key ativa da requisição, se ela tiver cota diária.
+func (a *APIKeyIdentifier) quotaKey(r *http.Request) (domain.APIKey, bool) {
+	raw := strings.TrimSpace(r.Header.Get(a.header))
+	if raw == "" {
+		return domain.APIKey{}, false
+	}
+	k, ok, _ := a.lookup(r, raw)
+	return k, ok && k.DailyQuota > 0
+}
+
+// Identifiers combina vários Identifiers: vale a primeira identidade com
+// chave, e qualquer erro recusa a requisição.
+func Identifiers(ids ...Identifier) Identifier {
+	return identifierChain(ids)
+}
+
+type identifierChain []Identifier
+
+func (c identifierChain) Identify(r *http.Request) (Identity, error) {
+	for _, id := range c {
+		got, err := id.Identify(r)
+		if err != nil || got.Key != "" {
+			return got, err
+		}
+	}
+	return Identity{}, nil
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func TestMiddleware_APIKeyRegistry(t *testing.T) {
+	keys := infra.NewStaticAPIKeys([]domain.APIKey{
+		{Key: "k1", Owner: "acme", RPS: 0.02, Burst: 3, DailyQuota: 2, Enabled: true},
+		{Key: "k2", Owner: "old", RPS: 100, Burst: 100},
+		{Key: "k3", Owner: "late", RPS: 100, Burst: 100, Enabled: true, ExpiresAt: time.Now().Add(-time.Hour)},
+	})
+	stats := &fakeStatsStore{}
+
+	build := func(reject bool) http.Handler {
+		ids := NewAPIKeyIdentifier(APIKeyOptions{Keys: keys, RejectUnknown: reject})
+		return Middleware(Options{
+			Store:      infra.NewStore(0.02, 1),
+			Stats:      stats,
+			Identifier: ids,
+			Headers:    HeadersLegacy,
+			Limits:     []Limit{ids.QuotaLimit("daily-quota", infra.NewFixedWindowStore(1<<30, 24*time.Hour))},
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	do := func(h http.Handler, key, ip string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = ip + ":1234"
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	h := build(false)
+	for i := 0; i < 2; i++ {
+		if code := do(h, "k1", "10.0.0.1"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 within the daily quota, got %d", i+1, code)
+		}
+	}
+	if code := do(h, "k1", "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the daily quota, got %d", code)
+	}
+	if evs := stats.Events(); evs[len(evs)-1].Limit != "daily-quota" {
+		t.Fatalf("expected denial tripped by daily-quota, got %+v", evs[len(evs)-1])
+	}
+
+	// desabilitada, expirada ou desconhecida: limite por IP (burst 1)
+	for i, key := range []string{"k2", "k3", "nope"} {
+		ip := fmt.Sprintf("10.0.1.%d", i+1)
+		if code := do(h, key, ip); code != http.StatusOK {
+			t.Fatalf("%s: expected first request allowed by IP limit, got %d", key, code)
+		}
+		if code := do(h, key, ip); code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected IP limit to apply, got %d", key, code)
+		}
+	}
+
+	strict := build(true)
+	if code := do(strict, "nope", "10.0.2.1"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown key, got %d", code)
+	}
+	if code := do(strict, "k2", "10.0.2.2"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for disabled key, got %d", code)
+	}
+	if code := do(strict, "", "10.0.2.3"); code != http.StatusOK {
+		t.Fatalf("expected request without key to use the IP limit, got %d", code)
+	}
+}
+
+type countingAPIKeys struct {
+	domain.APIKeyStore
+	calls int
+}
+
+func (c *countingAPIKeys) LookupAPIKey(ctx context.Context, key string) (domain.APIKey, bool, error) {
+	c.calls++
+	return c.APIKeyStore.LookupAPIKey(ctx, key)
+}
+
+func TestMiddleware_APIKeyLookedUpOncePerRequest(t *testing.T) {
+	keys := &countingAPIKeys{APIKeyStore: infra.NewStaticAPIKeys([]domain.APIKey{
+		{Key: "k1", Owner: "acme", DailyQuota: 5, Enabled: true},
+	})}
+	ids := NewAPIKeyIdentifier(APIKeyOptions{Keys: keys})
+	h := Middleware(Options{
+		Store:      infra.NewStore(100, 100),
+		Identifier: ids,
+		Limits:     []Limit{ids.QuotaLimit("daily-quota", infra.NewFixedWindowStore(1<<30, 24*time.Hour))},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r.Header.Set("X-API-Key", "k1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if keys.calls != 1 {
+		t.Fatalf("expected a single registry lookup per request, got %d", keys.calls)
+	}
+}
+
+func TestMiddleware_APIKeySecretStaysOutOfTheLimitKey(t *testing.T) {
+	keys := infra.NewStaticAPIKeys([]domain.APIKey{{Key: "s3cr3t-key", Owner: "acme", DailyQuota: 5, Enabled: true}})
+	ids := NewAPIKeyIdentifier(APIKeyOptions{Keys: keys})
+	stats := &fakeStatsStore{}
+	h := Middleware(Options{
+		Store:      infra.NewStore(100, 100),
+		Stats:      stats,
+		Identifier: ids,
+		ExposeKey:  true,
+		Limits:     []Limit{ids.QuotaLimit("daily-quota", infra.NewFixedWindowStore(1<<30, 24*time.Hour))},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r.Header.Set("X-API-Key", "s3cr3t-key")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	want := "apikey:" + APIKeyFingerprint("s3cr3t-key")
+	if got := w.Header().Get("X-RateLimit-Key"); got != want {
+		t.Fatalf("expected key %q, got %q", want, got)
+	}
+	for _, ev := range stats.Events() {
+		if strings.Contains(string(ev.Key), "s3cr3t") {
+			t.Fatalf("expected the secret to stay out of the stats, got %+v", ev)
+		}
+	}
+}
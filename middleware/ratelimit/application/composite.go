@@ -0,0 +1,49 @@
+package application
+
+import (
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// LimitCheck é um dos limites avaliados por DecideAll.
+type LimitCheck struct {
+	// Name identifica o limite em Decision.Tripped (e nas estatísticas).
+	Name    string
+	Service Service
+	Key     domain.Key
+	// Scope isola os buckets deste limite (ver DecideWithPolicy). Limites que
+	// compartilham o mesmo Store devem usar scopes diferentes.
+	Scope string
+	// Default (opcional) substitui a política padrão do Service.
+	Default *domain.Policy
+}
+
+// DecideAll avalia os limites em ordem e só permite se todos permitirem
+// (ex: por IP E por API key E global). No primeiro que negar, a avaliação
+// para e a cota já consumida nos anteriores é devolvida, desde que os
+// limiters implementem domain.ReservingLimiter.
+//
+// A decisão negada traz o nome do limite em Tripped; a permitida traz o
+// estado do limite com menos cota restante.
+func DecideAll(checks []LimitCheck) domain.Decision {
+	var (
+		cancels []func()
+		out     = domain.Decision{Allowed: true}
+	)
+	for _, c := range checks {
+		dec, cancel := c.Service.reserve(scopedKey(c.Scope, c.Key), c.Key, c.Default)
+		if !dec.Allowed {
+			for _, undo := range cancels {
+				undo()
+			}
+			dec.Tripped = c.Name
+			return dec
+		}
+		if cancel != nil {
+			cancels = append(cancels, cancel)
+		}
+		if out.Limit == 0 || (dec.Limit > 0 && dec.Remaining < out.Remaining) {
+			out = dec
+		}
+	}
+	return out
+}
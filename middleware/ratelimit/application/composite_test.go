@@ -0,0 +1,75 @@
+package application
+
+import (
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// countingLimiter permite até limit consumos e conta as devoluções.
+type countingLimiter struct {
+	limit, used, refunds int
+}
+
+func (l *countingLimiter) Allow() bool              { return l.Take().Allowed }
+func (l *countingLimiter) Take() domain.LimitResult { res, _ := l.Reserve(); return res }
+
+func (l *countingLimiter) Reserve() (domain.LimitResult, func()) {
+	res := domain.LimitResult{Limit: l.limit}
+	if l.used >= l.limit {
+		return res, nil
+	}
+	l.used++
+	res.Allowed, res.Remaining = true, l.limit-l.used
+	return res, func() { l.used--; l.refunds++ }
+}
+
+func TestDecideAll_DenialRefundsEarlierLimits(t *testing.T) {
+	ip := &countingLimiter{limit: 10}
+	apiKey := &countingLimiter{limit: 1}
+	checks := []LimitCheck{
+		{Name: "ip", Service: Service{Store: fakeStore{lim: ip}}, Key: "1.2.3.4"},
+		{Name: "api-key", Service: Service{Store: fakeStore{lim: apiKey}}, Key: "k1", Scope: "api-key"},
+	}
+
+	if dec := DecideAll(checks); !dec.Allowed || dec.Tripped != "" {
+		t.Fatalf("expected first request allowed, got %+v", dec)
+	}
+	if dec := DecideAll(checks); dec.Allowed || dec.Tripped != "api-key" {
+		t.Fatalf("expected denial tripped by api-key, got %+v", dec)
+	}
+	if ip.used != 1 || ip.refunds != 1 {
+		t.Fatalf("expected ip quota refunded on denial, used=%d refunds=%d", ip.used, ip.refunds)
+	}
+}
+
+func TestDecideAll_StopsAtFirstDenial(t *testing.T) {
+	later := &countingLimiter{limit: 10}
+	checks := []LimitCheck{
+		{Name: "ip", Service: Service{Store: fakeStore{lim: fakeLimiter{allow: false}}}, Key: "1.2.3.4"},
+		{Name: "global", Service: Service{Store: fakeStore{lim: later}}, Key: "global", Scope: "global"},
+	}
+
+	dec := DecideAll(checks)
+	if dec.Allowed || dec.Tripped != "ip" {
+		t.Fatalf("expected denial tripped by ip, got %+v", dec)
+	}
+	if dec.RetryAfter <= 0 {
+		t.Fatalf("expected RetryAfter fallback, got %s", dec.RetryAfter)
+	}
+	if later.used != 0 {
+		t.Fatalf("expected later limits not evaluated, used=%d", later.used)
+	}
+}
+
+func TestDecideAll_ReportsTightestLimit(t *testing.T) {
+	checks := []LimitCheck{
+		{Name: "ip", Service: Service{Store: fakeStore{lim: &countingLimiter{limit: 10}}}, Key: "1.2.3.4"},
+		{Name: "global", Service: Service{Store: fakeStore{lim: &countingLimiter{limit: 3}}}, Key: "global", Scope: "global"},
+	}
+
+	dec := DecideAll(checks)
+	if !dec.Allowed || dec.Limit != 3 || dec.Remaining != 2 {
+		t.Fatalf("expected state of the global limit (3/2), got %+v", dec)
+	}
+}
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"strings"
 	"time"
 
 	"middleware-gateway/middleware/ratelimit/domain"
@@ -9,25 +10,152 @@ import (
 // Service concentra a regra de aplicação do rate limit.
 //
 // Ele não sabe nada sobre HTTP (headers/status), apenas retorna uma decisão.
+//
+// Se Policies estiver definido, a política resolvida para a chave (ex: token)
+// tem precedência sobre DefaultPolicy (ex: limite por IP).
+//
+// Se o limiter implementar domain.ReportingLimiter, a decisão traz limite,
+// restante e reset reais, e o RetryAfter de uma negação é o tempo até a
+// próxima vaga (RetryAfter do Service fica só como fallback).
+//
+// Lockout: com Blocks definido e um tempo de bloqueio (BlockDuration global ou
+// Policy.BlockDuration), a primeira negação bloqueia a chave por esse tempo,
+// mesmo que o bucket volte a ter tokens antes disso.
 type Service struct {
 	Store      domain.LimiterStore
 	RetryAfter time.Duration
+
+	Policies      domain.PolicyResolver
+	DefaultPolicy domain.Policy
+
+	Blocks        domain.BlockStore
+	BlockDuration time.Duration
 }
 
 func (s Service) Decide(key domain.Key) domain.Decision {
+	dec, _ := s.reserve(key, key, nil)
+	return dec
+}
+
+// DecideWithPolicy decide usando def como política padrão da chave (ex: regra
+// de rota) no lugar de DefaultPolicy. O estado fica isolado por scope (ex: nome
+// da regra): a mesma chave em regras diferentes usa buckets diferentes.
+// Políticas por token (Policies) continuam tendo precedência.
+func (s Service) DecideWithPolicy(scope string, key domain.Key, def domain.Policy) domain.Decision {
+	dec, _ := s.reserve(scopedKey(scope, key), key, &def)
+	return dec
+}
+
+func scopedKey(scope string, key domain.Key) domain.Key {
+	if scope == "" {
+		return key
+	}
+	// "rule:" separa os buckets das regras das chaves cruas e das identidades
+	return domain.Key(scopePrefix + scope + ":" + string(key))
+}
+
+const scopePrefix = "rule:"
+
+// SplitScopedKey separa o bucket de uma regra ou limite extra
+// ("rule:<scope>:<chave>", ver DecideWithPolicy) no scope e na chave do
+// cliente; ok=false se k não tem scope.
+func SplitScopedKey(k domain.Key) (scope string, key domain.Key, ok bool) {
+	rest, ok := strings.CutPrefix(string(k), scopePrefix)
+	if !ok {
+		return "", k, false
+	}
+	scope, raw, ok := strings.Cut(rest, ":")
+	if !ok || scope == "" {
+		return "", k, false
+	}
+	return scope, domain.Key(raw), true
+}
+
+// reserve avalia o bucket; key é a chave "crua" usada para resolver políticas.
+// Se permitido e o limiter suportar (domain.ReservingLimiter), cancel desfaz
+// o consumo.
+func (s Service) reserve(bucket, key domain.Key, def *domain.Policy) (dec domain.Decision, cancel func()) {
 	if s.Store == nil {
-		return domain.Decision{Allowed: true}
+		return domain.Decision{Allowed: true}, nil
 	}
 	if s.RetryAfter <= 0 {
 		s.RetryAfter = 1 * time.Second
 	}
 
-	lim := s.Store.Get(key)
+	lim, policy := s.limiterFor(bucket, key, def)
+
+	if s.Blocks != nil {
+		if left := s.Blocks.BlockedFor(bucket); left > 0 {
+			return domain.Decision{Allowed: false, Policy: policy, RetryAfter: left, Reset: left}, nil
+		}
+	}
+
 	if lim == nil {
-		return domain.Decision{Allowed: true}
+		return domain.Decision{Allowed: true, Policy: policy}, nil
+	}
+	dec, cancel = take(lim)
+	dec.Policy = policy
+	if dec.Allowed {
+		return dec, cancel
+	}
+
+	if d := s.blockDuration(policy); d > 0 && s.Blocks != nil {
+		s.Blocks.Block(bucket, d)
+		dec.RetryAfter, dec.Reset = d, max(dec.Reset, d)
+		return dec, nil
+	}
+	if dec.RetryAfter <= 0 {
+		dec.RetryAfter = s.RetryAfter
+	}
+	return dec, nil
+}
+
+// take consome do limiter, aproveitando o estado detalhado (e a devolução)
+// se ele informar.
+func take(lim domain.Limiter) (domain.Decision, func()) {
+	var (
+		res    domain.LimitResult
+		cancel func()
+	)
+	switch l := lim.(type) {
+	case domain.ReservingLimiter:
+		res, cancel = l.Reserve()
+	case domain.ReportingLimiter:
+		res = l.Take()
+	default:
+		return domain.Decision{Allowed: lim.Allow()}, nil
+	}
+	return domain.Decision{
+		Allowed:    res.Allowed,
+		RetryAfter: res.RetryAfter,
+		Limit:      res.Limit,
+		Remaining:  res.Remaining,
+		Reset:      res.Reset,
+		Window:     res.Window,
+	}, cancel
+}
+
+func (s Service) blockDuration(p domain.Policy) time.Duration {
+	if p.BlockDuration > 0 {
+		return p.BlockDuration
+	}
+	return s.BlockDuration
+}
+
+// limiterFor escolhe o limiter do bucket de acordo com a política aplicável:
+// política do token > def (se informada) > limite padrão do Store.
+func (s Service) limiterFor(bucket, key domain.Key, def *domain.Policy) (domain.Limiter, domain.Policy) {
+	ps, canPolicy := s.Store.(domain.PolicyLimiterStore)
+	if s.Policies != nil && canPolicy {
+		if p, ok := s.Policies.Resolve(key); ok {
+			return ps.GetWithPolicy(bucket, p), p
+		}
 	}
-	if lim.Allow() {
-		return domain.Decision{Allowed: true}
+	if def != nil {
+		if canPolicy {
+			return ps.GetWithPolicy(bucket, *def), *def
+		}
+		return s.Store.Get(bucket), *def
 	}
-	return domain.Decision{Allowed: false, RetryAfter: s.RetryAfter}
+	return s.Store.Get(bucket), s.DefaultPolicy
 }
@@ -59,3 +59,195 @@ func TestService_Decide_BlocksWithConfiguredRetryAfter(t *testing.T) {
 		t.Fatalf("expected RetryAfter=2.5s, got %s", dec.RetryAfter)
 	}
 }
+
+type policyStore struct {
+	def      domain.Limiter
+	byPolicy map[string]domain.Limiter
+}
+
+func (s policyStore) Get(domain.Key) domain.Limiter { return s.def }
+
+func (s policyStore) GetWithPolicy(_ domain.Key, p domain.Policy) domain.Limiter {
+	return s.byPolicy[p.Name]
+}
+
+type staticPolicies map[domain.Key]domain.Policy
+
+func (m staticPolicies) Resolve(k domain.Key) (domain.Policy, bool) {
+	p, ok := m[k]
+	return p, ok
+}
+
+func TestService_Decide_TokenPolicyOverridesDefault(t *testing.T) {
+	store := policyStore{
+		def:      fakeLimiter{allow: false},
+		byPolicy: map[string]domain.Limiter{"gold": fakeLimiter{allow: true}},
+	}
+	svc := Service{
+		Store:         store,
+		Policies:      staticPolicies{"abc123": {Name: "gold", RPS: 100, Burst: 100}},
+		DefaultPolicy: domain.Policy{Name: "default", RPS: 10, Burst: 10},
+	}
+
+	dec := svc.Decide("abc123")
+	if !dec.Allowed {
+		t.Fatalf("expected token policy limiter to allow")
+	}
+	if dec.Policy.Name != "gold" || dec.Policy.RPS != 100 {
+		t.Fatalf("expected gold policy, got %+v", dec.Policy)
+	}
+
+	dec = svc.Decide("10.0.0.1")
+	if dec.Allowed {
+		t.Fatalf("expected default limiter to block")
+	}
+	if dec.Policy.Name != "default" {
+		t.Fatalf("expected default policy, got %+v", dec.Policy)
+	}
+}
+
+type fakeBlocks struct {
+	blocked map[domain.Key]time.Duration
+}
+
+func (b *fakeBlocks) BlockedFor(k domain.Key) time.Duration { return b.blocked[k] }
+
+func (b *fakeBlocks) Block(k domain.Key, d time.Duration) {
+	if b.blocked[k] == 0 {
+		b.blocked[k] = d
+	}
+}
+
+func TestService_Decide_FirstDenialBlocksKey(t *testing.T) {
+	blocks := &fakeBlocks{blocked: map[domain.Key]time.Duration{}}
+	svc := Service{
+		Store:         fakeStore{lim: fakeLimiter{allow: false}},
+		Blocks:        blocks,
+		BlockDuration: 5 * time.Minute,
+	}
+
+	dec := svc.Decide("k")
+	if dec.Allowed {
+		t.Fatalf("expected blocked")
+	}
+	if dec.RetryAfter != 5*time.Minute {
+		t.Fatalf("expected RetryAfter=5m, got %s", dec.RetryAfter)
+	}
+	if blocks.blocked["k"] != 5*time.Minute {
+		t.Fatalf("expected key to be blocked for 5m, got %s", blocks.blocked["k"])
+	}
+}
+
+func TestService_Decide_BlockedKeyDeniedEvenIfLimiterAllows(t *testing.T) {
+	blocks := &fakeBlocks{blocked: map[domain.Key]time.Duration{"k": 90 * time.Second}}
+	svc := Service{
+		Store:         fakeStore{lim: fakeLimiter{allow: true}},
+		Blocks:        blocks,
+		BlockDuration: 5 * time.Minute,
+	}
+
+	dec := svc.Decide("k")
+	if dec.Allowed {
+		t.Fatalf("expected blocked key to be denied")
+	}
+	if dec.RetryAfter != 90*time.Second {
+		t.Fatalf("expected RetryAfter to be remaining block time (90s), got %s", dec.RetryAfter)
+	}
+}
+
+func TestService_Decide_PolicyBlockDurationOverridesGlobal(t *testing.T) {
+	blocks := &fakeBlocks{blocked: map[domain.Key]time.Duration{}}
+	svc := Service{
+		Store: policyStore{
+			byPolicy: map[string]domain.Limiter{"gold": fakeLimiter{allow: false}},
+		},
+		Policies:      staticPolicies{"abc123": {Name: "gold", RPS: 100, Burst: 100, BlockDuration: 30 * time.Second}},
+		Blocks:        blocks,
+		BlockDuration: 5 * time.Minute,
+	}
+
+	dec := svc.Decide("abc123")
+	if dec.Allowed || dec.RetryAfter != 30*time.Second {
+		t.Fatalf("expected token block of 30s, got allowed=%v retryAfter=%s", dec.Allowed, dec.RetryAfter)
+	}
+}
+
+type recordingStore struct {
+	keys     []domain.Key
+	policies []domain.Policy
+}
+
+func (s *recordingStore) Get(k domain.Key) domain.Limiter {
+	s.keys = append(s.keys, k)
+	return fakeLimiter{allow: true}
+}
+
+func (s *recordingStore) GetWithPolicy(k domain.Key, p domain.Policy) domain.Limiter {
+	s.keys = append(s.keys, k)
+	s.policies = append(s.policies, p)
+	return fakeLimiter{allow: true}
+}
+
+func TestService_DecideWithPolicy_ScopesBucketAndUsesPolicy(t *testing.T) {
+	store := &recordingStore{}
+	svc := Service{Store: store, DefaultPolicy: domain.Policy{Name: "default", RPS: 10, Burst: 10}}
+
+	login := domain.Policy{Name: "login", RPS: 1, Burst: 5}
+	dec := svc.DecideWithPolicy("login", "10.0.0.1", login)
+	if !dec.Allowed || dec.Policy != login {
+		t.Fatalf("expected allowed with login policy, got %+v", dec)
+	}
+	if len(store.keys) != 1 || store.keys[0] != "rule:login:10.0.0.1" {
+		t.Fatalf("expected scoped bucket key, got %v", store.keys)
+	}
+	if store.policies[0] != login {
+		t.Fatalf("expected store to receive login policy, got %+v", store.policies[0])
+	}
+}
+
+func TestSplitScopedKey(t *testing.T) {
+	cases := []struct {
+		in     domain.Key
+		scope  string
+		key    domain.Key
+		scoped bool
+	}{
+		{"rule:login:10.0.0.1", "login", "10.0.0.1", true},
+		{"rule:api:2001:db8::1", "api", "2001:db8::1", true},
+		{"rule:api:hdr:abc", "api", "hdr:abc", true},
+		{"10.0.0.1", "", "10.0.0.1", false},
+		{"rule::x", "", "rule::x", false},
+		{"rule:x", "", "rule:x", false},
+	}
+	for _, c := range cases {
+		scope, key, ok := SplitScopedKey(c.in)
+		if scope != c.scope || key != c.key || ok != c.scoped {
+			t.Fatalf("SplitScopedKey(%q) = %q, %q, %v; want %q, %q, %v", c.in, scope, key, ok, c.scope, c.key, c.scoped)
+		}
+	}
+}
+
+type reportingLimiter struct {
+	res domain.LimitResult
+}
+
+func (f reportingLimiter) Allow() bool              { return f.res.Allowed }
+func (f reportingLimiter) Take() domain.LimitResult { return f.res }
+
+func TestService_Decide_UsesReportedLimiterState(t *testing.T) {
+	svc := Service{Store: fakeStore{lim: reportingLimiter{res: domain.LimitResult{
+		Allowed: true, Limit: 10, Remaining: 7, Reset: 3 * time.Second,
+	}}}}
+	dec := svc.Decide("k")
+	if !dec.Allowed || dec.Limit != 10 || dec.Remaining != 7 || dec.Reset != 3*time.Second {
+		t.Fatalf("expected decision to carry limiter state, got %+v", dec)
+	}
+
+	svc = Service{RetryAfter: time.Minute, Store: fakeStore{lim: reportingLimiter{res: domain.LimitResult{
+		Limit: 10, RetryAfter: 1500 * time.Millisecond, Reset: 15 * time.Second,
+	}}}}
+	dec = svc.Decide("k")
+	if dec.Allowed || dec.RetryAfter != 1500*time.Millisecond || dec.Remaining != 0 {
+		t.Fatalf("expected RetryAfter from limiter instead of the fallback, got %+v", dec)
+	}
+}
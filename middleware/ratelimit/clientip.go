@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// TrustedProxies é a lista de proxies (CIDRs/IPs) cujos headers de
+// encaminhamento (Forwarded, X-Forwarded-For, X-Real-IP) são confiáveis.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// ParseTrustedProxies lê uma lista separada por vírgula de CIDRs ou IPs,
+// ex: "10.0.0.0/8, 192.168.1.10, ::1".
+func ParseTrustedProxies(spec string) (*TrustedProxies, error) {
+	tp := &TrustedProxies{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			p, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", item)
+			}
+			tp.prefixes = append(tp.prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q", item)
+		}
+		addr = addr.Unmap()
+		tp.prefixes = append(tp.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return tp, nil
+}
+
+// Len é o número de entradas da lista.
+func (tp *TrustedProxies) Len() int {
+	if tp == nil {
+		return 0
+	}
+	return len(tp.prefixes)
+}
+
+// Contains informa se addr é um proxy confiável.
+func (tp *TrustedProxies) Contains(addr netip.Addr) bool {
+	if tp == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range tp.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP descobre o IP do cliente. Os headers de encaminhamento só são
+// considerados se a conexão vier de um proxy confiável; nesse caso a cadeia
+// (Forwarded, ou X-Forwarded-For se não houver) é percorrida da direita para a
+// esquerda, pulando proxies confiáveis: o primeiro endereço não confiável é o
+// cliente. Sem cadeia, usa X-Real-IP. Entradas que não são IP (ex: "unknown",
+// identificadores ofuscados) encerram a busca no último endereço conhecido,
+// para que o cliente não consiga inventar chaves novas.
+func ClientIP(r *http.Request, proxies *TrustedProxies) string {
+	remote, ok := remoteAddr(r)
+	if !ok {
+		if r.RemoteAddr != "" {
+			return r.RemoteAddr
+		}
+		return "unknown"
+	}
+	if !proxies.Contains(remote) {
+		return remote.String()
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		if ip, ok := parseHop(r.Header.Get("X-Real-IP")); ok {
+			return ip.String()
+		}
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !proxies.Contains(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// ProxyKeyFunc é como DefaultKeyFunc, mas identifica o IP com ClientIP (só
+// confia nos headers de encaminhamento vindos de proxies).
+func ProxyKeyFunc(keyHeader string, proxies *TrustedProxies) KeyFunc {
+	return func(r *http.Request) string {
+		if keyHeader != "" {
+			if v := strings.TrimSpace(r.Header.Get(keyHeader)); v != "" {
+				return domain.HeaderKeyPrefix + v
+			}
+		}
+		return ClientIP(r, proxies)
+	}
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	return parseHop(host)
+}
+
+// forwardedFor retorna os saltos da cadeia, do cliente para o último proxy.
+// Forwarded (RFC 7239) tem precedência sobre X-Forwarded-For.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, v := range h.Values("Forwarded") {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(name), "for") {
+					hops = append(hops, strings.TrimSpace(value))
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop aceita IP, IP:porta, [IPv6]:porta e as formas entre aspas do Forwarded.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// IPAggregation agrupa IPs em sub-redes antes de virarem chave, para que quem
+// controla um bloco inteiro (ex: um /64 IPv6) não ganhe um bucket por endereço.
+// Zero (ou o tamanho total) mantém o IP completo.
+type IPAggregation struct {
+	IPv4Bits int // ex: 24
+	IPv6Bits int // ex: 64 ou 56
+}
+
+// Key normaliza s (IPv4 mapeado em IPv6 vira IPv4, zona é descartada) e o
+// agrega conforme a configuração, ex: "2001:db8::1" => "2001:db8::/64".
+// Valores que não são IP (ex: tokens) voltam sem alteração.
+func (a IPAggregation) Key(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := a.IPv6Bits
+	if addr.Is4() {
+		bits = a.IPv4Bits
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return p.String()
+}
+
+// AggregateIPs aplica agg à chave produzida por fn (ver IPAggregation.Key).
+func AggregateIPs(fn KeyFunc, agg IPAggregation) KeyFunc {
+	return func(r *http.Request) string {
+		return agg.Key(fn(r))
+	}
+}
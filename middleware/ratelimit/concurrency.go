@@ -5,13 +5,35 @@ import (
 	"time"
 
 	"middleware-gateway/middleware/ratelimit/application"
+	"middleware-gateway/middleware/ratelimit/domain"
 	"middleware-gateway/middleware/ratelimit/infra"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ConcurrencyOptions configura o ConcurrencyMiddleware.
+//
+// Pool (opcional) permite reaproveitar um pool existente (ex: ao remontar o
+// middleware num hot reload, sem perder a contagem das requisições em curso).
+// Se nil, cria um infra.NewChanPool(Max).
+//
+// OnReject (opcional) escreve a resposta quando não há vaga; o padrão é texto
+// puro com StatusText.
+//
+// Tracer (opcional) cria o span SpanAcquire, com o tempo de espera pela vaga.
+//
+// Stats (opcional) registra as recusas com StatsEvent.Outcome
+// domain.OutcomeConcurrency e StatsEvent.FollowUp: a requisição já foi contada
+// como permitida pelo Middleware, então a recusa só entra na contagem por
+// outcome. As requisições atendidas não são registradas aqui.
 type ConcurrencyOptions struct {
-	Max           int
-	RejectStatus  int
+	Max            int
+	RejectStatus   int
 	AcquireTimeout time.Duration
+	Pool           domain.SlotPool
+	OnReject       RejectHandler
+	Stats          domain.StatsStore
+	Tracer         trace.Tracer
 }
 
 func ConcurrencyMiddleware(opts ConcurrencyOptions) func(next http.Handler) http.Handler {
@@ -22,16 +44,47 @@ func ConcurrencyMiddleware(opts ConcurrencyOptions) func(next http.Handler) http
 		opts.RejectStatus = http.StatusServiceUnavailable
 	}
 
+	if opts.OnReject == nil {
+		opts.OnReject = TextRejecter("")
+	}
+
+	if opts.Pool == nil {
+		opts.Pool = infra.NewChanPool(opts.Max)
+	}
+
 	svc := application.ConcurrencyService{
-		Pool:           infra.NewChanPool(opts.Max),
+		Pool:           opts.Pool,
 		AcquireTimeout: opts.AcquireTimeout,
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := startSpan(opts.Tracer, r, SpanAcquire)
+			start := time.Now()
 			release, ok := svc.Acquire(r.Context())
+			outcome := "acquired"
+			if !ok {
+				outcome = OutcomeRejected
+			}
+			span.SetAttributes(AttrOutcome.String(outcome))
+			span.End()
+			if info := RequestInfoFrom(r.Context()); info != nil {
+				info.ConcurrencyWait = time.Since(start)
+				if !ok {
+					info.Outcome, info.Reason = domain.OutcomeConcurrency, ReasonConcurrency
+				}
+			}
 			if !ok {
-				http.Error(w, http.StatusText(opts.RejectStatus), opts.RejectStatus)
+				if opts.Stats != nil {
+					_ = opts.Stats.Record(r.Context(), domain.StatsEvent{
+						Outcome:  domain.OutcomeConcurrency,
+						FollowUp: true,
+						Method:   r.Method,
+						Path:     r.URL.Path,
+						At:       time.Now(),
+					})
+				}
+				opts.OnReject(w, r, Rejection{Status: opts.RejectStatus, Reason: ReasonConcurrency})
 				return
 			}
 			defer release()
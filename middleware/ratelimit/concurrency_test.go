@@ -1,11 +1,15 @@
 package ratelimit
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
 )
 
 func TestConcurrencyMiddleware_TimesOutWhenNoSlot(t *testing.T) {
@@ -75,3 +79,28 @@ func TestConcurrencyMiddleware_TimesOutWhenNoSlot(t *testing.T) {
 	close(release)
 	wg.Wait()
 }
+
+func TestConcurrencyMiddleware_RejectionIsNotASecondDecision(t *testing.T) {
+	stats := infra.NewMemoryStatsStore()
+	pool := infra.NewChanPool(1)
+	release, _ := pool.Acquire(context.Background()) // pool cheio
+	defer release()
+
+	h := Middleware(Options{Store: infra.NewStore(100, 100), Stats: stats})(
+		ConcurrencyMiddleware(ConcurrencyOptions{Max: 1, Pool: pool, AcquireTimeout: time.Millisecond, Stats: stats})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with the pool full, got %d", w.Code)
+	}
+	if total := stats.Total(); total.Allowed != 1 || total.Denied != 0 {
+		t.Fatalf("expected a single allowed decision for the request, got %+v", total)
+	}
+	if got := stats.ByOutcome()[domain.OutcomeConcurrency]; got != 1 {
+		t.Fatalf("expected the rejection counted by outcome, got %d", got)
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey é o registro de uma API key emitida para um cliente.
+type APIKey struct {
+	Key   string
+	Owner string
+	// RPS e Burst são o limite da key (0 = limite padrão).
+	RPS   float64
+	Burst int
+	// DailyQuota é o máximo de requisições por dia UTC (0 = sem cota).
+	DailyQuota int
+	// ExpiresAt (opcional) é quando a key deixa de valer.
+	ExpiresAt time.Time
+	Enabled   bool
+}
+
+// Active informa se a key está habilitada e não expirou em now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.Enabled && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// APIKeyStore é o registro de API keys (ex: arquivo, Redis).
+type APIKeyStore interface {
+	// LookupAPIKey busca a key; ok=false se ela não existir.
+	LookupAPIKey(ctx context.Context, key string) (k APIKey, ok bool, err error)
+}
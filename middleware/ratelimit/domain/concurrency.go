@@ -9,3 +9,16 @@ import "context"
 type SlotPool interface {
 	Acquire(ctx context.Context) (release func(), ok bool)
 }
+
+// SlotPoolUsage é a ocupação de um SlotPool num instante.
+type SlotPoolUsage struct {
+	Capacity int
+	InUse    int
+	// Waiting é quantos Acquire estão esperando vaga.
+	Waiting int
+}
+
+// SlotPoolInspector é implementado por SlotPools que informam a ocupação.
+type SlotPoolInspector interface {
+	Usage() SlotPoolUsage
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// KeyState é o retrato do estado de uma chave num LimiterStore, para
+// inspeção (ex: API admin).
+type KeyState struct {
+	Key Key
+	// Tokens é quanto a chave ainda pode consumir agora (tokens do bucket ou
+	// vagas da janela), com o limite do bucket da chave; stores que não o
+	// guardam (ex: Redis) usam o limite padrão (ver PolicyInspector).
+	Tokens float64
+	Limit  int
+	// LastSeen é o último acesso; zero se o store não registra.
+	LastSeen time.Time
+	// BlockedFor é o lockout restante (0 = sem bloqueio).
+	BlockedFor time.Duration
+}
+
+// InspectableStore é implementado por stores que permitem listar, inspecionar
+// e zerar o estado das chaves.
+type InspectableStore interface {
+	// Keys lista até max chaves com estado (max <= 0 = todas) que contêm
+	// contains (vazio = qualquer uma).
+	Keys(ctx context.Context, contains string, max int) ([]Key, error)
+	// Inspect retorna o estado da chave; ok=false se ela não tem estado nem
+	// bloqueio (está com o limite cheio).
+	Inspect(ctx context.Context, key Key) (st KeyState, ok bool, err error)
+	// Reset apaga o estado e o bloqueio da chave: ela volta ao limite cheio.
+	Reset(ctx context.Context, key Key) error
+}
+
+// PolicyInspector é implementado por InspectableStores que não guardam a
+// política de cada chave (ex: Redis): o saldo é calculado com p, a política
+// com que a chave é limitada.
+type PolicyInspector interface {
+	InspectWithPolicy(ctx context.Context, k Key, p Policy) (KeyState, bool, error)
+}
+
+// ExemptionStore guarda isenções temporárias: enquanto durar, a chave não
+// passa pelo rate limit. Como no BlockStore, implementações compartilhadas
+// fazem a isenção valer em todas as réplicas.
+type ExemptionStore interface {
+	// ExemptFor retorna quanto tempo de isenção ainda resta (0 se nenhuma).
+	ExemptFor(Key) time.Duration
+	// Exempt isenta a chave por d, substituindo uma isenção anterior; d <= 0
+	// remove a isenção.
+	Exempt(k Key, d time.Duration)
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuotaPeriod é a janela de calendário (UTC) de uma cota de longo prazo,
+// ex: "10 mil requisições por dia". Ao contrário das janelas fixas por
+// duração, o mês tem tamanho variável.
+type QuotaPeriod string
+
+const (
+	QuotaHour  QuotaPeriod = "hour"
+	QuotaDay   QuotaPeriod = "day"
+	QuotaMonth QuotaPeriod = "month"
+)
+
+func ParseQuotaPeriod(s string) (QuotaPeriod, error) {
+	switch p := QuotaPeriod(strings.ToLower(strings.TrimSpace(s))); p {
+	case QuotaHour, QuotaDay, QuotaMonth:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown quota period %q (use hour, day or month)", s)
+}
+
+// Window retorna o início e o fim (exclusivo) da janela UTC que contém now.
+func (p QuotaPeriod) Window(now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	switch p {
+	case QuotaHour:
+		start = now.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case QuotaMonth:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// ID identifica a janela que contém now (ex: "2024010215" por hora,
+// "20240102" por dia, "202401" por mês).
+func (p QuotaPeriod) ID(now time.Time) string {
+	start, _ := p.Window(now)
+	switch p {
+	case QuotaHour:
+		return start.Format("2006010215")
+	case QuotaMonth:
+		return start.Format("200601")
+	default:
+		return start.Format("20060102")
+	}
+}
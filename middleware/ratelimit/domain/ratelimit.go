@@ -8,6 +8,12 @@ import "time"
 
 type Key string
 
+// HeaderKeyPrefix marca as chaves tiradas de um header da requisição
+// (RATE_KEY_HEADER, token ou header:<Nome> das regras). Sem ela, um cliente
+// poderia mandar no header o mesmo texto de um IP, de uma identidade
+// ("sub:42") ou do bucket de uma regra e gastar a cota de outro.
+const HeaderKeyPrefix = "hdr:"
+
 // Limiter representa algo que pode decidir se uma ação é permitida agora.
 //
 // Observação: a implementação pode ser token-bucket, leaky-bucket, etc.
@@ -16,15 +22,98 @@ type Limiter interface {
 	Allow() bool
 }
 
+// LimitResult é o estado de um limiter logo após uma tentativa de consumo.
+type LimitResult struct {
+	Allowed bool
+	// Limit é o máximo de requisições que podem passar de uma vez
+	// (burst no token bucket/GCRA, limite da janela nos algoritmos de janela).
+	Limit int
+	// Remaining é quantas requisições ainda passariam agora, após esta.
+	Remaining int
+	// Reset é o tempo até o limite estar totalmente disponível de novo
+	// (fim da janela atual ou bucket cheio).
+	Reset time.Duration
+	// RetryAfter é o tempo até a próxima requisição ser aceita (0 se Allowed).
+	RetryAfter time.Duration
+	// Window é o período da cota: a janela, ou o tempo para encher o bucket
+	// vazio (burst / rps) no token bucket/GCRA.
+	Window time.Duration
+}
+
+// ReportingLimiter é um Limiter que, além da decisão, informa quanto resta e
+// quando o limite é restaurado (para headers e Retry-After precisos).
+type ReportingLimiter interface {
+	Limiter
+	Take() LimitResult
+}
+
+// ReservingLimiter é um ReportingLimiter cujo consumo pode ser desfeito.
+// Permite combinar vários limites numa requisição sem gastar cota dos outros
+// quando um deles nega.
+type ReservingLimiter interface {
+	ReportingLimiter
+	// Reserve consome como Take e, se permitido, devolve cancel para
+	// devolver a cota (nil se negado).
+	Reserve() (res LimitResult, cancel func())
+}
+
 // LimiterStore obtém um limiter por chave (ex: IP, API key, usuário).
 // A implementação pode manter cache, TTL, etc.
 type LimiterStore interface {
 	Get(Key) Limiter
 }
 
+// Policy descreve os limites aplicados a uma chave (ex: padrão por IP ou um token).
+type Policy struct {
+	Name  string
+	RPS   float64
+	Burst int
+	// BlockDuration (opcional) é o tempo de bloqueio após estourar o limite.
+	// Se 0, vale o bloqueio global configurado no serviço (se houver).
+	BlockDuration time.Duration
+}
+
+// PolicyResolver resolve a política específica de uma chave.
+// Se ok=false, a chave não tem política própria e vale a política padrão (IP).
+type PolicyResolver interface {
+	Resolve(Key) (p Policy, ok bool)
+}
+
+// PolicyLimiterStore é um LimiterStore que sabe criar limiters com uma política
+// diferente da padrão para cada chave.
+type PolicyLimiterStore interface {
+	LimiterStore
+	GetWithPolicy(Key, Policy) Limiter
+}
+
+// BlockStore guarda chaves temporariamente bloqueadas (lockout) depois de
+// excederem o limite. Implementações compartilhadas (ex: Redis) fazem o
+// bloqueio valer em todas as réplicas.
+type BlockStore interface {
+	// BlockedFor retorna quanto tempo de bloqueio ainda resta (0 se livre).
+	BlockedFor(Key) time.Duration
+	// Block bloqueia a chave por d. Se ela já estiver bloqueada, o bloqueio
+	// existente é mantido.
+	Block(k Key, d time.Duration)
+}
+
 type Decision struct {
 	Allowed bool
+	// Policy é a política efetivamente aplicada (zero se desconhecida).
+	Policy Policy
 	// RetryAfter é o valor a ser retornado em Retry-After quando bloquear.
 	// Se 0, não há recomendação.
 	RetryAfter time.Duration
+
+	// Limit, Remaining e Reset vêm do estado real do limiter
+	// (domain.ReportingLimiter). Limit == 0 significa que o limiter não
+	// informa esses dados.
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	Window    time.Duration
+
+	// Tripped é o nome do limite que negou, quando vários limites são
+	// avaliados juntos (vazio se permitido ou se há um limite só).
+	Tripped string
 }
@@ -15,13 +15,49 @@ import (
 type StatsEvent struct {
 	Key     Key
 	Allowed bool
+	// Limit é o nome do limite que negou (Decision.Tripped), se houver.
+	Limit string
+	// Outcome distingue decisões tomadas fora do rate limit (listas de
+	// acesso, isenções); vazio quando a decisão veio dos limites.
+	Outcome string
+	// FollowUp marca um evento que complementa a decisão já registrada para
+	// a mesma requisição (ex: recusa do pool de concorrência depois do
+	// Middleware): os stores o contam só por Outcome, fora dos totais
+	// allowed/denied, das rotas, das chaves e da série por minuto.
+	FollowUp bool
 
 	Method string
 	Path   string
 
 	At time.Time
+
+	// Count (opcional) é quantas decisões iguais o evento representa, para
+	// quem agrega eventos antes de gravar (ex: infra.BufferedStatsStore);
+	// 0 vale 1.
+	Count int64
+}
+
+// N é o número de decisões do evento (Count, ou 1 se não informado).
+func (ev StatsEvent) N() int64 {
+	if ev.Count > 0 {
+		return ev.Count
+	}
+	return 1
 }
 
+// Valores de StatsEvent.Outcome.
+const (
+	// OutcomeAllowlisted: o cliente está na allowlist e não passou pelos limites.
+	OutcomeAllowlisted = "allowlisted"
+	// OutcomeDenylisted: o cliente está na denylist e foi recusado com 403.
+	OutcomeDenylisted = "denylisted"
+	// OutcomeExempt: a chave tinha uma isenção temporária (API admin).
+	OutcomeExempt = "exempt"
+	// OutcomeConcurrency: passou pelo rate limit, mas não conseguiu vaga no
+	// pool de concorrência.
+	OutcomeConcurrency = "concurrency"
+)
+
 // StatsStore é a estratégia de persistência para estatísticas do rate limit.
 //
 // Implementações podem armazenar em Redis, Postgres, memória, etc.
@@ -29,3 +65,45 @@ type StatsEvent struct {
 type StatsStore interface {
 	Record(ctx context.Context, ev StatsEvent) error
 }
+
+// StatsBatchRecorder é opcional: grava vários eventos de uma vez (ex: um
+// único pipeline no Redis), usado por quem agrupa eventos antes de gravar.
+type StatsBatchRecorder interface {
+	RecordBatch(ctx context.Context, evs []StatsEvent) error
+}
+
+// StatsCounts são contadores de decisões.
+type StatsCounts struct {
+	Allowed int64
+	Denied  int64
+}
+
+// StatsPoint é o total de um intervalo da série temporal, começando em At.
+type StatsPoint struct {
+	At time.Time
+	StatsCounts
+}
+
+// RouteStats são os contadores de uma rota ("MÉTODO /caminho").
+type RouteStats struct {
+	Route string
+	StatsCounts
+}
+
+// KeyStats são os contadores de uma chave de cliente.
+type KeyStats struct {
+	Key Key
+	StatsCounts
+}
+
+// StatsReader lê de volta o que um StatsStore gravou.
+//
+// Series devolve um ponto por minuto em [from, to), com zeros nos minutos sem
+// eventos. TopRoutes ordena por total de requisições e TopDeniedKeys por
+// negações; as chaves só existem se o store registrar por chave.
+type StatsReader interface {
+	Totals(ctx context.Context) (StatsCounts, error)
+	Series(ctx context.Context, from, to time.Time) ([]StatsPoint, error)
+	TopRoutes(ctx context.Context, n int) ([]RouteStats, error)
+	TopDeniedKeys(ctx context.Context, n int) ([]KeyStats, error)
+}
@@ -5,7 +5,11 @@
 
 package ratelimit
 
-import "strconv"
+import (
+	"math"
+	"strconv"
+	"time"
+)
 
 func formatInt(v int) string { return strconv.Itoa(v) }
 
@@ -13,3 +17,9 @@ func formatFloat(v float64) string {
 	// sem depender de fmt, e sem notação científica para valores comuns
 	return strconv.FormatFloat(v, 'f', -1, 64)
 }
+
+// formatSeconds arredonda para cima (Retry-After: 1.2s => "2"), para o cliente
+// não tentar de novo antes da hora.
+func formatSeconds(d time.Duration) string {
+	return formatInt(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// HeaderMode define quais headers de cota o Middleware envia ao cliente.
+type HeaderMode string
+
+const (
+	// HeadersNone não envia headers de cota (só Retry-After ao rejeitar).
+	HeadersNone HeaderMode = "none"
+	// HeadersLegacy envia X-RateLimit-Limit, X-RateLimit-Remaining e
+	// X-RateLimit-Reset (segundos até o reset).
+	HeadersLegacy HeaderMode = "legacy"
+	// HeadersIETF envia RateLimit e RateLimit-Policy (draft-ietf-httpapi-ratelimit-headers):
+	//
+	//	RateLimit-Policy: "default";q=100;w=60
+	//	RateLimit: "default";r=42;t=17
+	HeadersIETF HeaderMode = "ietf"
+	// HeadersBoth envia os dois formatos.
+	HeadersBoth HeaderMode = "both"
+)
+
+// ParseHeaderMode valida o nome de um modo (vazio = none).
+func ParseHeaderMode(s string) (HeaderMode, error) {
+	m := HeaderMode(strings.ToLower(strings.TrimSpace(s)))
+	switch m {
+	case "":
+		return HeadersNone, nil
+	case HeadersNone, HeadersLegacy, HeadersIETF, HeadersBoth:
+		return m, nil
+	}
+	return "", fmt.Errorf("%q is not supported (use none, legacy, ietf or both)", s)
+}
+
+func (m HeaderMode) legacy() bool { return m == HeadersLegacy || m == HeadersBoth }
+func (m HeaderMode) ietf() bool   { return m == HeadersIETF || m == HeadersBoth }
+
+// writeQuotaHeaders escreve os headers de cota a partir do estado real do
+// limiter. Sem esse estado (dec.Limit == 0), não escreve nada.
+func writeQuotaHeaders(h http.Header, mode HeaderMode, dec domain.Decision) {
+	if dec.Limit <= 0 {
+		return
+	}
+	remaining := max(0, dec.Remaining)
+	if !dec.Allowed {
+		remaining = 0
+	}
+	reset := formatSeconds(dec.Reset)
+
+	if mode.legacy() {
+		h.Set("X-RateLimit-Limit", formatInt(dec.Limit))
+		h.Set("X-RateLimit-Remaining", formatInt(remaining))
+		h.Set("X-RateLimit-Reset", reset)
+	}
+	if mode.ietf() {
+		name := strconv.Quote(policyName(dec.Policy))
+		policy := name + ";q=" + formatInt(dec.Limit)
+		if dec.Window > 0 {
+			policy += ";w=" + formatSeconds(dec.Window)
+		}
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit", name+";r="+formatInt(remaining)+";t="+reset)
+	}
+}
+
+func policyName(p domain.Policy) string {
+	if p.Name == "" {
+		return "default"
+	}
+	return p.Name
+}
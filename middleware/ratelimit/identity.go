@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// Identity é o cliente identificado por um Identifier.
+type Identity struct {
+	// Key é a chave do limite; vazia cai no KeyFn padrão (IP).
+	Key string
+	// Policy (opcional) é a política própria da chave (ex: tier do plano),
+	// com precedência sobre a política padrão e a da rota.
+	Policy *domain.Policy
+}
+
+// Identifier identifica o cliente a partir de credenciais (ex: JWT, API key)
+// no lugar do KeyFn padrão. Um erro recusa a requisição com 401.
+type Identifier interface {
+	Identify(r *http.Request) (Identity, error)
+}
+
+// AuthError é o erro de um Identifier que recusa a credencial; Challenge
+// (opcional) vai no header WWW-Authenticate.
+type AuthError struct {
+	Challenge string
+	Err       error
+}
+
+func (e *AuthError) Error() string { return e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+func authChallenge(err error) string {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return ae.Challenge
+	}
+	return ""
+}
+
+// identityPolicies resolve a política da identidade antes das demais.
+type identityPolicies struct {
+	key    domain.Key
+	policy domain.Policy
+	next   domain.PolicyResolver
+}
+
+func (p identityPolicies) Resolve(k domain.Key) (domain.Policy, bool) {
+	if k == p.key {
+		return p.policy, true
+	}
+	if p.next == nil {
+		return domain.Policy{}, false
+	}
+	return p.next.Resolve(k)
+}
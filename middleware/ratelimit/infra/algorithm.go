@@ -0,0 +1,39 @@
+package infra
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Algorithm identifica o algoritmo de rate limit de um store.
+type Algorithm string
+
+const (
+	// AlgorithmTokenBucket permite rajadas de até burst e reabastece a rps.
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmGCRA (generic cell rate algorithm) tem o mesmo comportamento do
+	// token bucket, mas guarda só um horário por chave.
+	AlgorithmGCRA Algorithm = "gcra"
+	// AlgorithmFixedWindow conta requisições em janelas fixas alinhadas ao
+	// relógio (UTC), ex: "1000 por dia".
+	AlgorithmFixedWindow Algorithm = "fixed_window"
+	// AlgorithmSlidingWindow é o sliding-window counter (aproximado, O(1)).
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmSlidingWindowLog é o sliding-window log (exato, O(limite)).
+	AlgorithmSlidingWindowLog Algorithm = "sliding_window_log"
+)
+
+// ParseAlgorithm valida o nome de um algoritmo (sem diferenciar maiúsculas).
+func ParseAlgorithm(s string) (Algorithm, error) {
+	a := Algorithm(strings.ToLower(strings.TrimSpace(s)))
+	switch a {
+	case AlgorithmTokenBucket, AlgorithmGCRA, AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmSlidingWindowLog:
+		return a, nil
+	}
+	return "", fmt.Errorf("%q is not supported (use token_bucket, gcra, fixed_window, sliding_window or sliding_window_log)", s)
+}
+
+// Windowed informa se o algoritmo limita por janela (usa RATE_WINDOW e ignora burst).
+func (a Algorithm) Windowed() bool {
+	return a == AlgorithmFixedWindow || a == AlgorithmSlidingWindow || a == AlgorithmSlidingWindowLog
+}
@@ -0,0 +1,277 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StaticAPIKeys é um registro de API keys em memória (ex: lido de arquivo).
+// Implementa domain.APIKeyStore.
+type StaticAPIKeys struct {
+	keys map[string]domain.APIKey
+}
+
+func NewStaticAPIKeys(keys []domain.APIKey) *StaticAPIKeys {
+	s := &StaticAPIKeys{keys: make(map[string]domain.APIKey, len(keys))}
+	for _, k := range keys {
+		s.keys[k.Key] = k
+	}
+	return s
+}
+
+// LookupAPIKey implementa domain.APIKeyStore.
+func (s *StaticAPIKeys) LookupAPIKey(_ context.Context, key string) (domain.APIKey, bool, error) {
+	if s == nil {
+		return domain.APIKey{}, false, nil
+	}
+	k, ok := s.keys[key]
+	return k, ok, nil
+}
+
+func (s *StaticAPIKeys) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.keys)
+}
+
+type apiKeyFileEntry struct {
+	Owner      string  `json:"owner"`
+	RPS        float64 `json:"rps"`
+	Burst      int     `json:"burst"`
+	DailyQuota int     `json:"daily_quota"`
+	ExpiresAt  string  `json:"expires_at"`
+	Enabled    *bool   `json:"enabled"`
+}
+
+// LoadAPIKeysFile lê o registro de um arquivo JSON:
+//
+//	{"abc123": {"owner": "acme", "rps": 10, "burst": 20, "daily_quota": 10000,
+//	            "expires_at": "2027-01-01T00:00:00Z", "enabled": true}}
+//
+// enabled é true se omitido; burst omitido usa max(1, rps).
+func LoadAPIKeysFile(path string) (*StaticAPIKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]apiKeyFileEntry
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := make([]domain.APIKey, 0, len(raw))
+	for key, e := range raw {
+		k := domain.APIKey{
+			Key:        key,
+			Owner:      e.Owner,
+			RPS:        e.RPS,
+			Burst:      e.Burst,
+			DailyQuota: e.DailyQuota,
+			Enabled:    e.Enabled == nil || *e.Enabled,
+		}
+		if e.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, e.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("%s: key %q: invalid expires_at: %w", path, redact(key), err)
+			}
+			k.ExpiresAt = t
+		}
+		if err := validateAPIKey(&k); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, redact(key), err)
+		}
+		keys = append(keys, k)
+	}
+	return NewStaticAPIKeys(keys), nil
+}
+
+func validateAPIKey(k *domain.APIKey) error {
+	if strings.TrimSpace(k.Key) == "" {
+		return fmt.Errorf("empty key")
+	}
+	if k.RPS < 0 || k.Burst < 0 || k.DailyQuota < 0 {
+		return fmt.Errorf("rps, burst and daily_quota must be >= 0")
+	}
+	if k.RPS > 0 && k.Burst == 0 {
+		k.Burst = defaultBurst(k.RPS)
+	}
+	return nil
+}
+
+// redact mostra só o começo de uma key em mensagens de erro.
+func redact(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
+
+// RedisAPIKeys é um registro de API keys no Redis, compartilhado entre réplicas.
+// Cada key é um hash <prefix>:<key> com os campos owner, rps, burst,
+// daily_quota, expires_at (RFC 3339) e enabled. Implementa domain.APIKeyStore.
+//
+// Com WithAPIKeyCacheTTL, as consultas ficam em cache local por esse tempo
+// (revogações levam até o TTL para valer).
+type RedisAPIKeys struct {
+	rdb    *redis.Client
+	prefix string
+
+	cacheTTL time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+	cache    map[string]cachedAPIKey
+	swept    time.Time
+}
+
+type cachedAPIKey struct {
+	key domain.APIKey
+	ok  bool
+	at  time.Time
+}
+
+type RedisAPIKeysOption func(*RedisAPIKeys)
+
+func WithAPIKeyPrefix(prefix string) RedisAPIKeysOption {
+	return func(s *RedisAPIKeys) { s.prefix = strings.Trim(prefix, ":") }
+}
+
+func WithAPIKeyCacheTTL(d time.Duration) RedisAPIKeysOption {
+	return func(s *RedisAPIKeys) { s.cacheTTL = d }
+}
+
+func NewRedisAPIKeys(rdb *redis.Client, opts ...RedisAPIKeysOption) *RedisAPIKeys {
+	s := &RedisAPIKeys{
+		rdb:    rdb,
+		prefix: "ratelimit:apikey",
+		now:    time.Now,
+		cache:  make(map[string]cachedAPIKey),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// LookupAPIKey implementa domain.APIKeyStore.
+func (s *RedisAPIKeys) LookupAPIKey(ctx context.Context, key string) (domain.APIKey, bool, error) {
+	if s.cacheTTL > 0 {
+		s.mu.Lock()
+		c, hit := s.cache[key]
+		s.mu.Unlock()
+		if hit && s.now().Sub(c.at) < s.cacheTTL {
+			return c.key, c.ok, nil
+		}
+	}
+
+	fields, err := s.rdb.HGetAll(ctx, s.prefix+":"+key).Result()
+	if err != nil {
+		return domain.APIKey{}, false, err
+	}
+	k, ok := domain.APIKey{}, len(fields) > 0
+	if ok {
+		if k, err = apiKeyFromHash(key, fields); err != nil {
+			return domain.APIKey{}, false, err
+		}
+	}
+
+	if s.cacheTTL > 0 {
+		s.mu.Lock()
+		now := s.now()
+		// descarta entradas vencidas (no máximo uma vez por TTL) para o cache
+		// não crescer com keys inválidas
+		if now.Sub(s.swept) >= s.cacheTTL {
+			for kk, c := range s.cache {
+				if now.Sub(c.at) >= s.cacheTTL {
+					delete(s.cache, kk)
+				}
+			}
+			s.swept = now
+		}
+		s.cache[key] = cachedAPIKey{key: k, ok: ok, at: now}
+		s.mu.Unlock()
+	}
+	return k, ok, nil
+}
+
+// Put grava (ou substitui) uma key no registro.
+func (s *RedisAPIKeys) Put(ctx context.Context, k domain.APIKey) error {
+	if err := validateAPIKey(&k); err != nil {
+		return err
+	}
+	fields := map[string]any{
+		"owner":       k.Owner,
+		"rps":         strconv.FormatFloat(k.RPS, 'f', -1, 64),
+		"burst":       k.Burst,
+		"daily_quota": k.DailyQuota,
+		"enabled":     strconv.FormatBool(k.Enabled),
+		"expires_at":  "",
+	}
+	if !k.ExpiresAt.IsZero() {
+		fields["expires_at"] = k.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	hkey := s.prefix + ":" + k.Key
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, hkey)
+	pipe.HSet(ctx, hkey, fields)
+	_, err := pipe.Exec(ctx)
+	s.forget(k.Key)
+	return err
+}
+
+// Delete remove uma key do registro.
+func (s *RedisAPIKeys) Delete(ctx context.Context, key string) error {
+	err := s.rdb.Del(ctx, s.prefix+":"+key).Err()
+	s.forget(key)
+	return err
+}
+
+func (s *RedisAPIKeys) forget(key string) {
+	s.mu.Lock()
+	delete(s.cache, key)
+	s.mu.Unlock()
+}
+
+func apiKeyFromHash(key string, f map[string]string) (domain.APIKey, error) {
+	k := domain.APIKey{Key: key, Owner: f["owner"], Enabled: true}
+	var err error
+	if v := f["rps"]; v != "" {
+		if k.RPS, err = strconv.ParseFloat(v, 64); err != nil {
+			return k, fmt.Errorf("api key %q: invalid rps: %w", redact(key), err)
+		}
+	}
+	if v := f["burst"]; v != "" {
+		if k.Burst, err = strconv.Atoi(v); err != nil {
+			return k, fmt.Errorf("api key %q: invalid burst: %w", redact(key), err)
+		}
+	}
+	if v := f["daily_quota"]; v != "" {
+		if k.DailyQuota, err = strconv.Atoi(v); err != nil {
+			return k, fmt.Errorf("api key %q: invalid daily_quota: %w", redact(key), err)
+		}
+	}
+	if v := f["expires_at"]; v != "" {
+		if k.ExpiresAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return k, fmt.Errorf("api key %q: invalid expires_at: %w", redact(key), err)
+		}
+	}
+	if v := f["enabled"]; v != "" {
+		if k.Enabled, err = strconv.ParseBool(v); err != nil {
+			return k, fmt.Errorf("api key %q: invalid enabled: %w", redact(key), err)
+		}
+	}
+	if err := validateAPIKey(&k); err != nil {
+		return k, fmt.Errorf("api key %q: %w", redact(key), err)
+	}
+	return k, nil
+}
@@ -0,0 +1,85 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestLoadAPIKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{
+		"k1": {"owner": "acme", "rps": 10, "daily_quota": 1000, "expires_at": "2030-01-01T00:00:00Z"},
+		"k2": {"owner": "old", "enabled": false}
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadAPIKeysFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k, ok, _ := keys.LookupAPIKey(context.Background(), "k1")
+	if !ok || k.Owner != "acme" || k.Burst != 10 || k.DailyQuota != 1000 || !k.Enabled {
+		t.Fatalf("unexpected k1: %+v", k)
+	}
+	if !k.Active(time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)) || k.Active(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected k1 active only before expires_at")
+	}
+	if k, _, _ := keys.LookupAPIKey(context.Background(), "k2"); k.Active(time.Now()) {
+		t.Fatalf("expected disabled k2 to be inactive")
+	}
+	if _, ok, _ := keys.LookupAPIKey(context.Background(), "nope"); ok {
+		t.Fatalf("expected unknown key not found")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"secretkey": {"rps": -1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAPIKeysFile(path); err == nil {
+		t.Fatalf("expected invalid rps to fail")
+	}
+}
+
+func TestRedisAPIKeys_PutLookupAndCache(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	ctx := context.Background()
+	clk := newFakeClock()
+
+	keys := NewRedisAPIKeys(rdb, WithAPIKeyCacheTTL(time.Minute))
+	keys.now = clk.now
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := keys.Put(ctx, domain.APIKey{Key: "k1", Owner: "acme", RPS: 5, DailyQuota: 100, ExpiresAt: exp, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	k, ok, err := keys.LookupAPIKey(ctx, "k1")
+	if err != nil || !ok || k.Owner != "acme" || k.RPS != 5 || k.Burst != 5 || k.DailyQuota != 100 || !k.ExpiresAt.Equal(exp) {
+		t.Fatalf("unexpected lookup: %+v %v %v", k, ok, err)
+	}
+
+	// outra réplica revoga a key; o cache local segura até o TTL
+	other := NewRedisAPIKeys(rdb)
+	if err := other.Put(ctx, domain.APIKey{Key: "k1", Owner: "acme", Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+	if k, _, _ := keys.LookupAPIKey(ctx, "k1"); !k.Enabled {
+		t.Fatalf("expected cached entry within TTL")
+	}
+	clk.advance(time.Minute)
+	if k, _, _ := keys.LookupAPIKey(ctx, "k1"); k.Enabled {
+		t.Fatalf("expected revocation visible after TTL")
+	}
+
+	if err := keys.Delete(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := keys.LookupAPIKey(ctx, "k1"); ok {
+		t.Fatalf("expected deleted key not found")
+	}
+}
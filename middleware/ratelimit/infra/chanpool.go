@@ -2,20 +2,31 @@ package infra
 
 import (
 	"context"
+	"sync/atomic"
 
 	"middleware-gateway/middleware/ratelimit/domain"
 )
 
 type chanPool struct {
-	sem chan struct{}
+	sem     chan struct{}
+	waiting atomic.Int64
 }
 
 // NewChanPool cria um pool simples baseado em channel com capacidade `max`.
+// Implementa também domain.SlotPoolInspector.
 func NewChanPool(max int) domain.SlotPool {
 	return &chanPool{sem: make(chan struct{}, max)}
 }
 
 func (p *chanPool) Acquire(ctx context.Context) (func(), bool) {
+	select {
+	case p.sem <- struct{}{}:
+		return func() { <-p.sem }, true
+	default:
+	}
+
+	p.waiting.Add(1)
+	defer p.waiting.Add(-1)
 	select {
 	case p.sem <- struct{}{}:
 		return func() { <-p.sem }, true
@@ -23,3 +34,12 @@ func (p *chanPool) Acquire(ctx context.Context) (func(), bool) {
 		return nil, false
 	}
 }
+
+// Usage implementa domain.SlotPoolInspector.
+func (p *chanPool) Usage() domain.SlotPoolUsage {
+	return domain.SlotPoolUsage{
+		Capacity: cap(p.sem),
+		InUse:    len(p.sem),
+		Waiting:  int(p.waiting.Load()),
+	}
+}
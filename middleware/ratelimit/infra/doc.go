@@ -3,5 +3,11 @@
 //
 // Exemplos:
 //   - Store: token bucket por chave usando golang.org/x/time/rate
+//   - RedisStore: token bucket, GCRA ou janela fixa por chave no Redis (compartilhado entre réplicas)
+//   - GCRAStore / FixedWindowStore: GCRA e janela fixa em memória, com restante/reset (domain.ReportingLimiter)
+//   - SlidingLogStore / SlidingCounterStore: sliding window (log exato e contador aproximado) em memória
+//   - StaticAPIKeys / RedisAPIKeys: registro de API keys (arquivo ou Redis)
+//   - QuotaStore / RedisQuotaStore: cotas por hora, dia ou mês (UTC) em memória ou no Redis
+//   - MemoryStatsStore / RedisStatsStore / PrometheusStatsStore: estatísticas (MultiStatsStore combina); Memory e Redis também as leem de volta (domain.StatsReader); BufferedStatsStore grava em lotes, fora da requisição
 //   - ChanPool: semáforo simples para limite de concorrência
 package infra
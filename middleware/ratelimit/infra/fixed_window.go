@@ -0,0 +1,98 @@
+package infra
+
+import (
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// FixedWindowStore implementa domain.LimiterStore com janelas fixas alinhadas
+// ao relógio (UTC): no máximo `limit` requisições por janela, e o contador zera
+// na virada. Indicado para cotas do tipo "1000 por dia" (window=24h).
+type FixedWindowStore struct {
+	memKeyed[*fixedWindow]
+	windowParams
+}
+
+// NewFixedWindowStore cria o store; limit < 1 vira 1, como em WindowLimit.
+func NewFixedWindowStore(limit int, window time.Duration, opts ...MemoryOption) *FixedWindowStore {
+	return &FixedWindowStore{
+		memKeyed:     newMemKeyed[*fixedWindow](opts),
+		windowParams: windowParams{limit: max(1, limit), window: window},
+	}
+}
+
+// Get implementa domain.LimiterStore.
+func (s *FixedWindowStore) Get(key domain.Key) domain.Limiter {
+	limit, window := s.params()
+	return s.getWindow(string(key), limit, window)
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *FixedWindowStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	limit, window := s.limitFor(p)
+	return s.getWindow(string(key), limit, window)
+}
+
+func (s *FixedWindowStore) getWindow(key string, limit int, window time.Duration) *fixedWindow {
+	return s.memKeyed.get(key,
+		func(w *fixedWindow) bool { return w.limit == limit && w.window == window },
+		func() *fixedWindow { return &fixedWindow{limit: limit, window: window, now: s.now} },
+	)
+}
+
+type fixedWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	start time.Time // início da janela atual
+	count int
+}
+
+func (w *fixedWindow) Allow() bool { return w.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (w *fixedWindow) Take() domain.LimitResult {
+	res, _ := w.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter.
+func (w *fixedWindow) Reserve() (domain.LimitResult, func()) {
+	now := w.now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ws := now.Truncate(w.window); !ws.Equal(w.start) {
+		w.start, w.count = ws, 0
+	}
+	reset := w.start.Add(w.window).Sub(now)
+
+	res := domain.LimitResult{Limit: w.limit, Reset: reset, Window: w.window}
+	if w.count >= w.limit {
+		res.RetryAfter = reset
+		return res, nil
+	}
+	w.count++
+	res.Allowed = true
+	res.Remaining = w.limit - w.count
+	start := w.start
+	return res, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// se a janela já virou, não há o que devolver
+		if w.start.Equal(start) && w.count > 0 {
+			w.count--
+		}
+	}
+}
+
+func (w *fixedWindow) expired(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !now.Before(w.start.Add(w.window))
+}
@@ -0,0 +1,61 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestFixedWindowStore_ReportsRemainingAndReset(t *testing.T) {
+	clk := newFakeClock()
+	clk.advance(15 * time.Hour) // 15:00 UTC
+	s := NewFixedWindowStore(3, 24*time.Hour, WithClock(clk.now))
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	for want := 2; want >= 0; want-- {
+		res := lim.Take()
+		if !res.Allowed || res.Remaining != want || res.Limit != 3 {
+			t.Fatalf("expected allowed with remaining=%d, got %+v", want, res)
+		}
+		if res.Reset != 9*time.Hour {
+			t.Fatalf("expected reset at midnight UTC (9h), got %s", res.Reset)
+		}
+	}
+
+	clk.advance(time.Hour)
+	res := lim.Take()
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != 8*time.Hour {
+		t.Fatalf("expected deny until midnight, got %+v", res)
+	}
+
+	clk.advance(8 * time.Hour)
+	if res := lim.Take(); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("expected new window after midnight, got %+v", res)
+	}
+}
+
+func TestFixedWindowStore_CleanupKeepsActiveWindow(t *testing.T) {
+	clk := newFakeClock()
+	s := NewFixedWindowStore(1, 24*time.Hour, WithClock(clk.now), WithMemoryIdleTTL(time.Minute))
+
+	if !s.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected first Allow to be true")
+	}
+	clk.advance(time.Hour)
+	s.Cleanup()
+	if s.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected daily quota to survive idle cleanup")
+	}
+}
+
+func TestFixedWindowStore_ReserveCancelRefunds(t *testing.T) {
+	s := NewFixedWindowStore(1, time.Minute, WithClock(newFakeClock().now))
+	lim := s.Get(domain.Key("k")).(domain.ReservingLimiter)
+
+	_, cancel := lim.Reserve()
+	cancel()
+	if res := lim.Take(); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected refunded slot, got %+v", res)
+	}
+}
@@ -0,0 +1,129 @@
+package infra
+
+import (
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// GCRAStore implementa domain.LimiterStore com GCRA (generic cell rate
+// algorithm): equivale a um token bucket de taxa rps e capacidade burst, mas
+// guarda apenas o "theoretical arrival time" (TAT) de cada chave.
+type GCRAStore struct {
+	memKeyed[*gcra]
+
+	mu    sync.RWMutex
+	rps   float64
+	burst int
+}
+
+func NewGCRAStore(rps float64, burst int, opts ...MemoryOption) *GCRAStore {
+	return &GCRAStore{
+		memKeyed: newMemKeyed[*gcra](opts),
+		rps:      rps,
+		burst:    burst,
+	}
+}
+
+func (s *GCRAStore) RPS() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rps
+}
+
+func (s *GCRAStore) Burst() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.burst
+}
+
+// SetLimits troca o limite padrão (ex: hot reload). Chaves existentes são
+// recriadas no próximo acesso.
+func (s *GCRAStore) SetLimits(rps float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rps = rps
+	s.burst = burst
+}
+
+// Get implementa domain.LimiterStore.
+func (s *GCRAStore) Get(key domain.Key) domain.Limiter {
+	return s.getGCRA(string(key), s.RPS(), s.Burst())
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *GCRAStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	return s.getGCRA(string(key), p.RPS, p.Burst)
+}
+
+func (s *GCRAStore) getGCRA(key string, rps float64, burst int) *gcra {
+	interval := gcraInterval(rps)
+	return s.memKeyed.get(key,
+		func(g *gcra) bool { return g.interval == interval && g.burst == burst },
+		func() *gcra { return &gcra{interval: interval, burst: burst, now: s.now} },
+	)
+}
+
+// gcraInterval é o intervalo de emissão (tempo para repor uma requisição).
+func gcraInterval(rps float64) time.Duration {
+	return time.Duration(float64(time.Second) / rps)
+}
+
+type gcra struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+
+	tat time.Time // theoretical arrival time
+}
+
+func (g *gcra) Allow() bool { return g.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (g *gcra) Take() domain.LimitResult {
+	res, _ := g.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter.
+func (g *gcra) Reserve() (domain.LimitResult, func()) {
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	tolerance := time.Duration(g.burst) * g.interval
+	newTat := tat.Add(g.interval)
+	allowAt := newTat.Add(-tolerance)
+
+	res := domain.LimitResult{Limit: g.burst, Window: tolerance}
+	if now.Before(allowAt) {
+		res.RetryAfter = allowAt.Sub(now)
+		res.Reset = tat.Sub(now)
+		return res, nil
+	}
+	g.tat = newTat
+	res.Allowed = true
+	res.Remaining = int(now.Sub(allowAt) / g.interval)
+	res.Reset = newTat.Sub(now)
+	return res, g.cancel
+}
+
+// cancel devolve uma requisição: o TAT recua um intervalo.
+func (g *gcra) cancel() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tat = g.tat.Add(-g.interval)
+}
+
+func (g *gcra) expired(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.tat.After(now)
+}
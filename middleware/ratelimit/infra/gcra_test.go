@@ -0,0 +1,49 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestGCRAStore_BurstThenSmoothRate(t *testing.T) {
+	clk := newFakeClock()
+	s := NewGCRAStore(1, 3, WithClock(clk.now))
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	for want := 2; want >= 0; want-- {
+		res := lim.Take()
+		if !res.Allowed || res.Remaining != want || res.Limit != 3 {
+			t.Fatalf("expected allowed with remaining=%d, got %+v", want, res)
+		}
+	}
+	res := lim.Take()
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("expected deny with retry=1s reset=3s, got %+v", res)
+	}
+
+	clk.advance(500 * time.Millisecond)
+	if res := lim.Take(); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected deny with retry=500ms, got %+v", res)
+	}
+	clk.advance(500 * time.Millisecond)
+	if res := lim.Take(); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected one request after 1s, got %+v", res)
+	}
+}
+
+func TestGCRAStore_GetWithPolicyAndSetLimits(t *testing.T) {
+	clk := newFakeClock()
+	s := NewGCRAStore(0.02, 1, WithClock(clk.now))
+
+	lim := s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 0.02, Burst: 3})
+	if got := allowN(lim, 4); got != 3 {
+		t.Fatalf("expected policy burst of 3, got %d", got)
+	}
+
+	s.SetLimits(0.02, 2)
+	if got := allowN(s.Get(domain.Key("ip")), 3); got != 2 {
+		t.Fatalf("expected new default burst of 2, got %d", got)
+	}
+}
@@ -0,0 +1,219 @@
+package infra
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// Inspeção (domain.InspectableStore) e isenções (domain.ExemptionStore) dos
+// stores em memória. Valem só para a réplica local.
+
+// Keys implementa domain.InspectableStore.
+func (s *Store) Keys(_ context.Context, contains string, max int) ([]domain.Key, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	return listKeys(keys, s.blocked, now, contains, max), nil
+}
+
+// Inspect implementa domain.InspectableStore. Tokens e Limit são os do bucket
+// da chave (que pode ter a política de um token/regra).
+func (s *Store) Inspect(_ context.Context, key domain.Key) (domain.KeyState, bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := domain.KeyState{
+		Key:        key,
+		Tokens:     float64(s.burst),
+		Limit:      s.burst,
+		BlockedFor: deadlineLeft(s.blocked, string(key), now),
+	}
+	ent, ok := s.entries[string(key)]
+	if ok {
+		st.Tokens, st.Limit, st.LastSeen = ent.lim.TokensAt(now), ent.lim.Burst(), ent.lastSeen
+	}
+	return st, ok || st.BlockedFor > 0, nil
+}
+
+// Reset implementa domain.InspectableStore.
+func (s *Store) Reset(_ context.Context, key domain.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, string(key))
+	delete(s.blocked, string(key))
+	return nil
+}
+
+// ExemptFor implementa domain.ExemptionStore.
+func (s *Store) ExemptFor(key domain.Key) time.Duration {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return deadlineLeft(s.exempt, string(key), now)
+}
+
+// Exempt implementa domain.ExemptionStore.
+func (s *Store) Exempt(key domain.Key, d time.Duration) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setDeadline(s.exempt, string(key), now, d)
+}
+
+// peeker é implementado pelos limiters em memória que informam o saldo atual
+// sem consumir.
+type peeker interface {
+	peek(now time.Time) (tokens float64, limit int)
+}
+
+// Keys implementa domain.InspectableStore.
+func (m *memKeyed[L]) Keys(_ context.Context, contains string, max int) ([]domain.Key, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.entries))
+	for k := range m.entries {
+		keys = append(keys, k)
+	}
+	return listKeys(keys, m.blocked, now, contains, max), nil
+}
+
+// Inspect implementa domain.InspectableStore. Tokens e Limit são os do
+// limiter da chave.
+func (m *memKeyed[L]) Inspect(_ context.Context, key domain.Key) (domain.KeyState, bool, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := domain.KeyState{Key: key, BlockedFor: deadlineLeft(m.blocked, string(key), now)}
+	ent, ok := m.entries[string(key)]
+	if ok {
+		st.LastSeen = ent.lastSeen
+		if p, isPeeker := any(ent.lim).(peeker); isPeeker {
+			st.Tokens, st.Limit = p.peek(now)
+		}
+	}
+	return st, ok || st.BlockedFor > 0, nil
+}
+
+// Reset implementa domain.InspectableStore.
+func (m *memKeyed[L]) Reset(_ context.Context, key domain.Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, string(key))
+	delete(m.blocked, string(key))
+	return nil
+}
+
+// ExemptFor implementa domain.ExemptionStore.
+func (m *memKeyed[L]) ExemptFor(key domain.Key) time.Duration {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return deadlineLeft(m.exempt, string(key), now)
+}
+
+// Exempt implementa domain.ExemptionStore.
+func (m *memKeyed[L]) Exempt(key domain.Key, d time.Duration) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	setDeadline(m.exempt, string(key), now, d)
+}
+
+func (g *gcra) peek(now time.Time) (float64, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	return max(0, float64(g.burst)-float64(tat.Sub(now))/float64(g.interval)), g.burst
+}
+
+func (w *fixedWindow) peek(now time.Time) (float64, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !now.Truncate(w.window).Equal(w.start) {
+		return float64(w.limit), w.limit
+	}
+	return float64(max(0, w.limit-w.count)), w.limit
+}
+
+func (l *slidingLog) peek(now time.Time) (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evict(now)
+	return float64(max(0, l.limit-len(l.times))), l.limit
+}
+
+func (c *slidingCounter) peek(now time.Time) (float64, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(now)
+	return max(0, float64(c.limit)-c.estimate(now)), c.limit
+}
+
+// listKeys junta as chaves com estado e as bloqueadas, filtra por contains e
+// devolve até max (max <= 0 = sem limite), em ordem.
+func listKeys(keys []string, blocked map[string]time.Time, now time.Time, contains string, max int) []domain.Key {
+	for k, until := range blocked {
+		if until.After(now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make([]domain.Key, 0, len(keys))
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			continue
+		}
+		if !strings.Contains(k, contains) {
+			continue
+		}
+		if max > 0 && len(out) >= max {
+			break
+		}
+		out = append(out, domain.Key(k))
+	}
+	return out
+}
+
+// deadlineLeft é o tempo até o prazo da chave em m (0 se não houver ou já
+// venceu, caso em que ele é descartado). Chame com o lock do dono de m.
+func deadlineLeft(m map[string]time.Time, key string, now time.Time) time.Duration {
+	until, ok := m[key]
+	if !ok || !until.After(now) {
+		delete(m, key)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+func setDeadline(m map[string]time.Time, key string, now time.Time, d time.Duration) {
+	if d <= 0 {
+		delete(m, key)
+		return
+	}
+	m[key] = now.Add(d)
+}
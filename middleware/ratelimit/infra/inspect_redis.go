@@ -0,0 +1,183 @@
+package infra
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// inspectScript lê o saldo atual de uma chave sem consumir, com o relógio do
+// Redis.
+//
+// KEYS[1] = chave
+// ARGV[1] = algoritmo, ARGV[2] = rps|limite, ARGV[3] = burst|janela em ms
+//
+// Retorna {existe (0/1), saldo × 1000, último acesso em ms (0 se desconhecido)}.
+var inspectScript = redis.NewScript(`
+local key = KEYS[1]
+local alg = ARGV[1]
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+if alg == 'gcra' then
+  local tat = tonumber(redis.call('GET', key))
+  if tat == nil then
+    return {0, 0, 0}
+  end
+  local interval = 1000000 / tonumber(ARGV[2])
+  local left = tonumber(ARGV[3]) - math.max(0, tat - now) / interval
+  return {1, math.floor(math.max(0, left) * 1000), 0}
+elseif alg == 'fixed_window' then
+  local st = redis.call('HMGET', key, 'start', 'count')
+  if not st[1] then
+    return {0, 0, 0}
+  end
+  local limit = tonumber(ARGV[2])
+  local window = tonumber(ARGV[3])
+  local now_ms = math.floor(now / 1000)
+  local count = 0
+  if tonumber(st[1]) == now_ms - (now_ms % window) then
+    count = tonumber(st[2]) or 0
+  end
+  return {1, math.max(0, limit - count) * 1000, 0}
+end
+
+local st = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(st[1])
+local ts = tonumber(st[2])
+if tokens == nil or ts == nil then
+  return {0, 0, 0}
+end
+tokens = math.min(tonumber(ARGV[3]), tokens + math.max(0, now - ts) / 1000000 * tonumber(ARGV[2]))
+return {1, math.floor(tokens * 1000), math.floor(ts / 1000)}
+`)
+
+// Keys implementa domain.InspectableStore com SCAN (não bloqueia o Redis, mas
+// percorre todo o keyspace do prefixo).
+func (s *RedisStore) Keys(ctx context.Context, contains string, max int) ([]domain.Key, error) {
+	base := s.bucketKey("")
+	pattern := globEscape(base) + "*" + globEscape(contains) + "*"
+
+	var (
+		out    []domain.Key
+		cursor uint64
+	)
+	for {
+		batch, next, err := s.rdb.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return out, err
+		}
+		cursor = next
+		for _, k := range batch {
+			out = append(out, domain.Key(strings.TrimPrefix(k, base)))
+			if max > 0 && len(out) >= max {
+				return out, nil
+			}
+		}
+		if cursor == 0 {
+			return out, nil
+		}
+	}
+}
+
+// Inspect implementa domain.InspectableStore. Como o Redis não guarda a
+// política de cada chave, o saldo é calculado com o limite padrão do store;
+// para chaves com outra política, use InspectWithPolicy.
+func (s *RedisStore) Inspect(ctx context.Context, key domain.Key) (domain.KeyState, bool, error) {
+	return s.InspectWithPolicy(ctx, key, domain.Policy{RPS: s.RPS(), Burst: s.Burst()})
+}
+
+// InspectWithPolicy implementa domain.PolicyInspector.
+func (s *RedisStore) InspectWithPolicy(ctx context.Context, key domain.Key, p domain.Policy) (domain.KeyState, bool, error) {
+	rps, burst := p.RPS, p.Burst
+	args := []any{string(s.algorithm), rps, burst}
+	limit := burst
+	if s.algorithm == AlgorithmFixedWindow {
+		limit = WindowLimit(rps, s.window)
+		args = []any{string(s.algorithm), limit, s.window.Milliseconds()}
+	}
+
+	res, err := inspectScript.Run(ctx, s.rdb, []string{s.bucketKey(key)}, args...).Int64Slice()
+	if err == nil && len(res) < 3 {
+		err = redis.Nil
+	}
+	if err != nil {
+		return domain.KeyState{}, false, err
+	}
+	ttl, err := s.rdb.PTTL(ctx, s.blockKey(key)).Result()
+	if err != nil {
+		return domain.KeyState{}, false, err
+	}
+
+	st := domain.KeyState{Key: key, Tokens: float64(limit), Limit: limit, BlockedFor: max(0, ttl)}
+	if res[0] == 1 {
+		st.Tokens = float64(res[1]) / 1000
+		if res[2] > 0 {
+			st.LastSeen = time.UnixMilli(res[2])
+		}
+	}
+	return st, res[0] == 1 || st.BlockedFor > 0, nil
+}
+
+// Reset implementa domain.InspectableStore.
+func (s *RedisStore) Reset(ctx context.Context, key domain.Key) error {
+	return s.rdb.Del(ctx, s.bucketKey(key), s.blockKey(key)).Err()
+}
+
+// ExemptFor implementa domain.ExemptionStore.
+func (s *RedisStore) ExemptFor(key domain.Key) time.Duration {
+	if s == nil || s.rdb == nil {
+		return 0
+	}
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	ttl, err := s.rdb.PTTL(ctx, s.exemptKey(key)).Result()
+	if err != nil {
+		// na dúvida a chave passa pelo rate limit normalmente
+		s.reportError(err)
+		return 0
+	}
+	return max(0, ttl)
+}
+
+// Exempt implementa domain.ExemptionStore.
+func (s *RedisStore) Exempt(key domain.Key, d time.Duration) {
+	if s == nil || s.rdb == nil {
+		return
+	}
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	var err error
+	if d <= 0 {
+		err = s.rdb.Del(ctx, s.exemptKey(key)).Err()
+	} else {
+		err = s.rdb.Set(ctx, s.exemptKey(key), 1, d).Err()
+	}
+	if err != nil {
+		s.reportError(err)
+	}
+}
+
+func (s *RedisStore) exemptKey(key domain.Key) string {
+	return s.prefix + ":ex:" + string(key)
+}
+
+// globEscape escapa os metacaracteres do glob do Redis (MATCH).
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package infra
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestStore_InspectResetAndExempt(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(0.01, 2)
+	lim := s.Get(domain.Key("10.0.0.1"))
+	lim.Allow()
+	lim.Allow()
+	s.Block(domain.Key("10.0.0.2"), time.Minute)
+	s.Get(domain.Key("tok"))
+
+	keys, _ := s.Keys(ctx, "10.0.", 0)
+	if len(keys) != 2 || keys[0] != "10.0.0.1" || keys[1] != "10.0.0.2" {
+		t.Fatalf("expected active and blocked keys matching the filter, got %v", keys)
+	}
+
+	st, ok, _ := s.Inspect(ctx, domain.Key("10.0.0.1"))
+	if !ok || st.Tokens >= 1 || st.Limit != 2 || st.LastSeen.IsZero() {
+		t.Fatalf("expected empty bucket with last seen, got %+v %v", st, ok)
+	}
+	if st, ok, _ := s.Inspect(ctx, domain.Key("10.0.0.2")); !ok || st.BlockedFor <= 0 {
+		t.Fatalf("expected block status, got %+v %v", st, ok)
+	}
+
+	_ = s.Reset(ctx, domain.Key("10.0.0.1"))
+	_ = s.Reset(ctx, domain.Key("10.0.0.2"))
+	if _, ok, _ := s.Inspect(ctx, domain.Key("10.0.0.1")); ok {
+		t.Fatalf("expected no state after reset")
+	}
+	if s.BlockedFor(domain.Key("10.0.0.2")) != 0 || !s.Get(domain.Key("10.0.0.1")).Allow() {
+		t.Fatalf("expected reset to clear the block and refill the bucket")
+	}
+
+	s.Exempt(domain.Key("k"), time.Minute)
+	if s.ExemptFor(domain.Key("k")) <= 0 {
+		t.Fatalf("expected exemption")
+	}
+	s.Exempt(domain.Key("k"), 0)
+	if s.ExemptFor(domain.Key("k")) != 0 {
+		t.Fatalf("expected exemption removed")
+	}
+}
+
+func TestMemKeyed_InspectPeeksWithoutConsuming(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock()
+	s := NewGCRAStore(1, 4, WithClock(clk.now))
+	allowN(s.Get(domain.Key("k")), 3)
+
+	for i := 0; i < 2; i++ {
+		st, ok, _ := s.Inspect(ctx, domain.Key("k"))
+		if !ok || st.Tokens != 1 || st.Limit != 4 {
+			t.Fatalf("expected 1 token left of 4, got %+v %v", st, ok)
+		}
+	}
+	clk.advance(1500 * time.Millisecond)
+	if st, _, _ := s.Inspect(ctx, domain.Key("k")); st.Tokens != 2.5 {
+		t.Fatalf("expected refill to 2.5 tokens, got %v", st.Tokens)
+	}
+}
+
+func TestRedisStore_InspectResetAndExempt(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	s := NewRedisStore(rdb, 1, 3)
+	s.Get(domain.Key("a")).Allow()
+	s.Get(domain.Key("b*")).Allow()
+	s.Block(domain.Key("a"), time.Minute)
+	s.Exempt(domain.Key("c"), time.Minute)
+	// outro algoritmo no mesmo prefixo não aparece na listagem
+	NewRedisStore(rdb, 1, 3, WithRedisAlgorithm(AlgorithmGCRA)).Get(domain.Key("g")).Allow()
+
+	keys, err := s.Keys(ctx, "", 0)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("expected the two token bucket keys, got %v %v", keys, err)
+	}
+	if keys, _ := s.Keys(ctx, "b*", 0); len(keys) != 1 || keys[0] != "b*" {
+		t.Fatalf("expected glob characters to match literally, got %v", keys)
+	}
+
+	st, ok, err := s.Inspect(ctx, domain.Key("a"))
+	if err != nil || !ok || st.Tokens != 2 || st.Limit != 3 || st.BlockedFor != time.Minute || st.LastSeen.IsZero() {
+		t.Fatalf("unexpected state: %+v %v %v", st, ok, err)
+	}
+	if s.ExemptFor(domain.Key("c")) != time.Minute {
+		t.Fatalf("expected exemption shared through redis")
+	}
+
+	if err := s.Reset(ctx, domain.Key("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Inspect(ctx, domain.Key("a")); ok {
+		t.Fatalf("expected no state after reset")
+	}
+}
+
+func TestRedisStore_InspectFixedWindow(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	s := NewRedisStore(rdb, 10.0/60, 0, WithRedisAlgorithm(AlgorithmFixedWindow), WithRedisWindow(time.Minute))
+	allowN(s.Get(domain.Key("k")), 4)
+	st, ok, err := s.Inspect(context.Background(), domain.Key("k"))
+	if err != nil || !ok || st.Tokens != 6 || st.Limit != 10 {
+		t.Fatalf("expected 6 of 10 left in the window, got %+v %v %v", st, ok, err)
+	}
+}
+
+func TestChanPool_Usage(t *testing.T) {
+	p := NewChanPool(1)
+	release, _ := p.Acquire(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if r, ok := p.Acquire(ctx); ok {
+			r()
+		}
+	}()
+	for p.(domain.SlotPoolInspector).Usage().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if u := p.(domain.SlotPoolInspector).Usage(); u.Capacity != 1 || u.InUse != 1 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	release()
+	<-done
+	if u := p.(domain.SlotPoolInspector).Usage(); u.InUse != 0 || u.Waiting != 0 {
+		t.Fatalf("expected empty pool, got %+v", u)
+	}
+}
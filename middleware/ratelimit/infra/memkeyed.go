@@ -0,0 +1,169 @@
+package infra
+
+import (
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// memKeyed é a base dos stores em memória por chave dos algoritmos de janela
+// (e afins): cache de limiters com TTL de inatividade, lockout (domain.BlockStore)
+// e relógio injetável para testes.
+type memKeyed[L memLimiter] struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry[L]
+	blocked map[string]time.Time
+	exempt  map[string]time.Time
+
+	now          func() time.Time
+	idleTTL      time.Duration
+	cleanupEvery time.Duration
+}
+
+// memLimiter é o estado por chave guardado em memKeyed.
+type memLimiter interface {
+	// expired informa se o estado já voltou ao inicial (pode ser descartado
+	// sem mudar nenhuma decisão futura).
+	expired(now time.Time) bool
+}
+
+type memEntry[L memLimiter] struct {
+	lim      L
+	lastSeen time.Time
+}
+
+// MemoryOption configura os stores em memória dos algoritmos de janela/GCRA.
+type MemoryOption func(*memoryConfig)
+
+type memoryConfig struct {
+	now          func() time.Time
+	idleTTL      time.Duration
+	cleanupEvery time.Duration
+}
+
+// WithClock injeta o relógio (útil para testes determinísticos).
+func WithClock(now func() time.Time) MemoryOption {
+	return func(c *memoryConfig) { c.now = now }
+}
+
+// WithMemoryIdleTTL define após quanto tempo sem uso uma chave é descartada.
+func WithMemoryIdleTTL(d time.Duration) MemoryOption {
+	return func(c *memoryConfig) { c.idleTTL = d }
+}
+
+// WithMemoryCleanupEvery define o intervalo do janitor (0 desliga).
+func WithMemoryCleanupEvery(d time.Duration) MemoryOption {
+	return func(c *memoryConfig) { c.cleanupEvery = d }
+}
+
+func newMemKeyed[L memLimiter](opts []MemoryOption) memKeyed[L] {
+	c := memoryConfig{
+		now:          time.Now,
+		idleTTL:      15 * time.Minute,
+		cleanupEvery: 2 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return memKeyed[L]{
+		entries:      make(map[string]*memEntry[L]),
+		blocked:      make(map[string]time.Time),
+		exempt:       make(map[string]time.Time),
+		now:          c.now,
+		idleTTL:      c.idleTTL,
+		cleanupEvery: c.cleanupEvery,
+	}
+}
+
+// get retorna o limiter da chave, criando (ou recriando, se !matches) com create.
+func (m *memKeyed[L]) get(key string, matches func(L) bool, create func() L) L {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ent, ok := m.entries[key]; ok && matches(ent.lim) {
+		ent.lastSeen = now
+		return ent.lim
+	}
+	lim := create()
+	m.entries[key] = &memEntry[L]{lim: lim, lastSeen: now}
+	return lim
+}
+
+// BlockedFor implementa domain.BlockStore.
+func (m *memKeyed[L]) BlockedFor(key domain.Key) time.Duration {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.blocked[string(key)]
+	if !ok || !until.After(now) {
+		delete(m.blocked, string(key))
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// Block implementa domain.BlockStore. Um bloqueio ainda ativo não é estendido.
+func (m *memKeyed[L]) Block(key domain.Key, d time.Duration) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if until, ok := m.blocked[string(key)]; ok && until.After(now) {
+		return
+	}
+	m.blocked[string(key)] = now.Add(d)
+}
+
+// Cleanup remove chaves inativas há mais de idleTTL cujo estado já expirou,
+// e bloqueios vencidos.
+func (m *memKeyed[L]) Cleanup() {
+	now := m.now()
+	cutoff := now.Add(-m.idleTTL)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, ent := range m.entries {
+		// uma janela longa (ex: 24h) não pode ser zerada só por inatividade
+		if ent.lastSeen.Before(cutoff) && ent.lim.expired(now) {
+			delete(m.entries, k)
+		}
+	}
+	for k, until := range m.blocked {
+		if !until.After(now) {
+			delete(m.blocked, k)
+		}
+	}
+	for k, until := range m.exempt {
+		if !until.After(now) {
+			delete(m.exempt, k)
+		}
+	}
+}
+
+// StartJanitor inicia uma goroutine que limpa chaves inativas periodicamente.
+// Pare cancelando o contexto.
+func (m *memKeyed[L]) StartJanitor(ctx DoneContext) {
+	if m.cleanupEvery <= 0 {
+		return
+	}
+
+	t := time.NewTicker(m.cleanupEvery)
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				m.Cleanup()
+			}
+		}
+	}()
+}
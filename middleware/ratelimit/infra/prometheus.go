@@ -0,0 +1,216 @@
+package infra
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Primitivas mínimas do formato texto do Prometheus (exposition format
+// 0.0.4), para expor métricas sem depender do client_golang.
+
+// PromContentType é o Content-Type do formato texto.
+const PromContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PromDefaultBuckets são os buckets padrão de latência, em segundos.
+var PromDefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// promOther substitui valores de label acima do limite de cardinalidade.
+const promOther = "other"
+
+// labelCap limita quantos valores distintos um label pode ter; os excedentes
+// viram promOther. Chame com o lock do dono.
+type labelCap struct {
+	max  int
+	seen map[string]bool
+}
+
+func newLabelCap(max int) labelCap {
+	return labelCap{max: max, seen: make(map[string]bool)}
+}
+
+func (c *labelCap) value(v string) string {
+	if c.seen[v] {
+		return v
+	}
+	if c.max > 0 && len(c.seen) >= c.max {
+		return promOther
+	}
+	c.seen[v] = true
+	return v
+}
+
+// promSeriesKey junta os valores dos labels numa chave de mapa.
+func promSeriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// promCounterVec é um contador com labels. Chame com o lock do dono.
+type promCounterVec struct {
+	name, help string
+	labels     []string
+	series     map[string]*promCounter
+}
+
+type promCounter struct {
+	values []string
+	n      float64
+}
+
+func newPromCounterVec(name, help string, labels ...string) *promCounterVec {
+	return &promCounterVec{name: name, help: help, labels: labels, series: make(map[string]*promCounter)}
+}
+
+func (c *promCounterVec) add(n float64, values ...string) {
+	k := promSeriesKey(values)
+	s, ok := c.series[k]
+	if !ok {
+		s = &promCounter{values: values}
+		c.series[k] = s
+	}
+	s.n += n
+}
+
+func (c *promCounterVec) write(w *bufio.Writer) {
+	writePromHeader(w, c.name, c.help, "counter")
+	for _, k := range sortedKeys(c.series) {
+		s := c.series[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, promLabels(c.labels, s.values), promFloat(s.n))
+	}
+}
+
+// PromHistogram é um histograma com labels, seguro para uso concorrente.
+type PromHistogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*promHistSeries
+}
+
+type promHistSeries struct {
+	values []string
+	counts []uint64 // por bucket (não cumulativo)
+	sum    float64
+	count  uint64
+}
+
+// NewPromHistogram cria um histograma; buckets vazios usam PromDefaultBuckets.
+func NewPromHistogram(name, help string, buckets []float64, labels ...string) *PromHistogram {
+	if len(buckets) == 0 {
+		buckets = PromDefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &PromHistogram{name: name, help: help, labels: labels, buckets: b, series: make(map[string]*promHistSeries)}
+}
+
+// Observe registra v com os valores de label na ordem de NewPromHistogram.
+func (h *PromHistogram) Observe(v float64, values ...string) {
+	k := promSeriesKey(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &promHistSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// WritePrometheus escreve o histograma no formato texto.
+func (h *PromHistogram) WritePrometheus(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	h.mu.Lock()
+	writePromHeader(w, h.name, h.help, "histogram")
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, promLabels(labels, append(append([]string(nil), s.values...), promFloat(b))), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, promLabels(labels, append(append([]string(nil), s.values...), "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, promLabels(h.labels, s.values), promFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, promLabels(h.labels, s.values), s.count)
+	}
+	h.mu.Unlock()
+
+	return w.Flush()
+}
+
+// WritePromGauge escreve um gauge sem labels.
+func WritePromGauge(out io.Writer, name, help string, v float64) error {
+	w := bufio.NewWriter(out)
+	writePromHeader(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", name, promFloat(v))
+	return w.Flush()
+}
+
+// WritePromCounter escreve um counter sem labels.
+func WritePromCounter(out io.Writer, name, help string, v float64) error {
+	w := bufio.NewWriter(out)
+	writePromHeader(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %s\n", name, promFloat(v))
+	return w.Flush()
+}
+
+func writePromHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, typ)
+}
+
+// promLabels formata {a="x",b="y"} (vazio sem labels).
+func promLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func promFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package infra
+
+import (
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// QuotaStore implementa domain.LimiterStore com cotas por janela de calendário
+// UTC (hora, dia ou mês, ver domain.QuotaPeriod): no máximo `limit`
+// requisições por janela, zerando na virada. Com política, o limite da chave é
+// Policy.Burst.
+//
+// O contador não depende do limite: trocá-lo (SetLimit, política nova) não zera
+// o consumo da janela. Em memória o consumo se perde num restart; para cotas
+// que sobrevivem a ele, use RedisQuotaStore.
+type QuotaStore struct {
+	memKeyed[*quotaCounter]
+	period domain.QuotaPeriod
+
+	mu    sync.RWMutex
+	limit int
+}
+
+func NewQuotaStore(period domain.QuotaPeriod, limit int, opts ...MemoryOption) *QuotaStore {
+	return &QuotaStore{
+		memKeyed: newMemKeyed[*quotaCounter](opts),
+		period:   period,
+		limit:    limit,
+	}
+}
+
+func (s *QuotaStore) Period() domain.QuotaPeriod { return s.period }
+
+func (s *QuotaStore) Limit() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limit
+}
+
+// SetLimit troca o limite padrão por janela (vale já na janela atual).
+func (s *QuotaStore) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+}
+
+// Get implementa domain.LimiterStore.
+func (s *QuotaStore) Get(key domain.Key) domain.Limiter {
+	return s.limiter(string(key), s.Limit())
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *QuotaStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	return s.limiter(string(key), p.Burst)
+}
+
+func (s *QuotaStore) limiter(key string, limit int) *quotaLimiter {
+	c := s.memKeyed.get(key,
+		func(*quotaCounter) bool { return true },
+		func() *quotaCounter { return &quotaCounter{period: s.period} },
+	)
+	return &quotaLimiter{c: c, limit: limit, now: s.now}
+}
+
+// quotaCounter é o consumo de uma chave na janela atual.
+type quotaCounter struct {
+	mu     sync.Mutex
+	period domain.QuotaPeriod
+
+	start, end time.Time
+	count      int
+}
+
+func (c *quotaCounter) expired(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !now.Before(c.end)
+}
+
+type quotaLimiter struct {
+	c     *quotaCounter
+	limit int
+	now   func() time.Time
+}
+
+func (l *quotaLimiter) Allow() bool { return l.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (l *quotaLimiter) Take() domain.LimitResult {
+	res, _ := l.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter.
+func (l *quotaLimiter) Reserve() (domain.LimitResult, func()) {
+	now := l.now()
+	c := l.c
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !now.Before(c.end) {
+		c.start, c.end = c.period.Window(now)
+		c.count = 0
+	}
+	reset := c.end.Sub(now)
+
+	res := domain.LimitResult{Limit: l.limit, Reset: reset, Window: c.end.Sub(c.start)}
+	if c.count >= l.limit {
+		res.RetryAfter = reset
+		return res, nil
+	}
+	c.count++
+	res.Allowed = true
+	res.Remaining = l.limit - c.count
+	start := c.start
+	return res, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// se a janela já virou, não há o que devolver
+		if c.start.Equal(start) && c.count > 0 {
+			c.count--
+		}
+	}
+}
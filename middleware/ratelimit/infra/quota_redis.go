@@ -0,0 +1,154 @@
+package infra
+
+import (
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// quotaScript consome uma unidade da cota se ainda houver saldo.
+//
+// KEYS[1] = contador da janela
+// ARGV[1] = limite, ARGV[2] = fim da janela (ms desde a época)
+//
+// Retorna {allowed (0/1), usado}.
+var quotaScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+local limit = tonumber(ARGV[1])
+if used >= limit then
+  return {0, used}
+end
+used = redis.call('INCR', KEYS[1])
+if used == 1 then
+  redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+end
+return {1, used}
+`)
+
+// quotaRefundScript devolve uma unidade (reserva cancelada).
+var quotaRefundScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used > 0 then
+  redis.call('DECR', KEYS[1])
+end
+return 1
+`)
+
+// quotaExpirySlack mantém o contador um pouco depois do fim da janela (para
+// inspeção e para réplicas com relógio levemente atrasado).
+const quotaExpirySlack = time.Hour
+
+// RedisQuotaStore é o QuotaStore com o consumo no Redis: sobrevive a restarts
+// e é compartilhado entre réplicas. Cada janela é um contador
+// <prefix>:q:<period>:<id>:<chave> (ex: ratelimit:bucket:q:day:20240102:1.2.3.4)
+// que expira sozinho depois do fim dela. O segmento q: separa os contadores
+// dos buckets e bloqueios do RedisStore no mesmo prefixo.
+//
+// A janela é calculada com o relógio do gateway (UTC), então as réplicas devem
+// estar sincronizadas (NTP). Aceita as opções de RedisStore (prefixo, timeout,
+// fail-open, callback de erro); algoritmo e janela são ignorados.
+type RedisQuotaStore struct {
+	base   *RedisStore
+	period domain.QuotaPeriod
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	limit int
+}
+
+func NewRedisQuotaStore(rdb *redis.Client, period domain.QuotaPeriod, limit int, opts ...RedisStoreOption) *RedisQuotaStore {
+	return &RedisQuotaStore{
+		base:   NewRedisStore(rdb, 0, 0, opts...),
+		period: period,
+		now:    time.Now,
+		limit:  limit,
+	}
+}
+
+func (s *RedisQuotaStore) Period() domain.QuotaPeriod { return s.period }
+
+func (s *RedisQuotaStore) Limit() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limit
+}
+
+// SetLimit troca o limite padrão por janela (vale já na janela atual).
+func (s *RedisQuotaStore) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+}
+
+// Get implementa domain.LimiterStore.
+func (s *RedisQuotaStore) Get(key domain.Key) domain.Limiter {
+	return &redisQuotaLimiter{store: s, key: key, limit: s.Limit()}
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore (o limite é Policy.Burst).
+func (s *RedisQuotaStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	return &redisQuotaLimiter{store: s, key: key, limit: p.Burst}
+}
+
+func (s *RedisQuotaStore) counterKey(key domain.Key, now time.Time) string {
+	return s.base.prefix + ":q:" + string(s.period) + ":" + s.period.ID(now) + ":" + string(key)
+}
+
+type redisQuotaLimiter struct {
+	store *RedisQuotaStore
+	key   domain.Key
+	limit int
+}
+
+func (l *redisQuotaLimiter) Allow() bool { return l.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (l *redisQuotaLimiter) Take() domain.LimitResult {
+	res, _ := l.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter. Como no RedisStore, a devolução
+// é best-effort.
+func (l *redisQuotaLimiter) Reserve() (domain.LimitResult, func()) {
+	s := l.store
+	now := s.now()
+	start, end := s.period.Window(now)
+	reset := end.Sub(now)
+	res := domain.LimitResult{Limit: l.limit, Reset: reset, Window: end.Sub(start)}
+	if s.base.rdb == nil {
+		res.Allowed, res.Remaining = true, l.limit
+		return res, nil
+	}
+
+	key := s.counterKey(l.key, now)
+	ctx, cancel := s.base.callContext()
+	defer cancel()
+
+	out, err := quotaScript.Run(ctx, s.base.rdb, []string{key}, l.limit, end.Add(quotaExpirySlack).UnixMilli()).Int64Slice()
+	if err == nil && len(out) < 2 {
+		err = redis.Nil
+	}
+	if err != nil {
+		s.base.reportError(err)
+		res.Allowed = s.base.failOpen
+		return res, nil
+	}
+
+	res.Allowed = out[0] == 1
+	res.Remaining = max(0, l.limit-int(out[1]))
+	if !res.Allowed {
+		res.RetryAfter = reset
+		return res, nil
+	}
+	return res, func() {
+		ctx, cancel := s.base.callContext()
+		defer cancel()
+		if err := quotaRefundScript.Run(ctx, s.base.rdb, []string{key}).Err(); err != nil {
+			s.base.reportError(err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestQuotaPeriod_WindowAlignsToCalendar(t *testing.T) {
+	now := time.Date(2024, 2, 29, 13, 45, 0, 0, time.FixedZone("BRT", -3*3600)) // 16:45 UTC
+	cases := []struct {
+		p          domain.QuotaPeriod
+		start, end time.Time
+		id         string
+	}{
+		{domain.QuotaHour, time.Date(2024, 2, 29, 16, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 17, 0, 0, 0, time.UTC), "2024022916"},
+		{domain.QuotaDay, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "20240229"},
+		{domain.QuotaMonth, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "202402"},
+	}
+	for _, c := range cases {
+		start, end := c.p.Window(now)
+		if !start.Equal(c.start) || !end.Equal(c.end) || c.p.ID(now) != c.id {
+			t.Fatalf("%s: expected [%s, %s) %s, got [%s, %s) %s", c.p, c.start, c.end, c.id, start, end, c.p.ID(now))
+		}
+	}
+	if _, err := domain.ParseQuotaPeriod("week"); err == nil {
+		t.Fatalf("expected unknown period to be rejected")
+	}
+}
+
+func TestQuotaStore_MonthRollover(t *testing.T) {
+	clk := newFakeClock()
+	clk.advance(30*24*time.Hour + 22*time.Hour) // 31/01 22:00 UTC
+	s := NewQuotaStore(domain.QuotaMonth, 2, WithClock(clk.now))
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	if allowN(lim, 3) != 2 {
+		t.Fatalf("expected 2 requests within the monthly quota")
+	}
+	res := lim.Take()
+	if res.Allowed || res.RetryAfter != 2*time.Hour || res.Reset != 2*time.Hour {
+		t.Fatalf("expected deny until the start of February, got %+v", res)
+	}
+
+	clk.advance(2 * time.Hour)
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 || res.Window != 29*24*time.Hour {
+		t.Fatalf("expected a fresh February (leap year) window, got %+v", res)
+	}
+}
+
+func TestQuotaStore_LimitChangeKeepsUsage(t *testing.T) {
+	clk := newFakeClock()
+	s := NewQuotaStore(domain.QuotaDay, 3, WithClock(clk.now), WithMemoryIdleTTL(time.Minute))
+
+	if allowN(s.Get(domain.Key("k")), 3) != 3 {
+		t.Fatalf("expected the daily quota to be available")
+	}
+	s.SetLimit(4)
+	clk.advance(time.Hour)
+	s.Cleanup()
+	if allowN(s.Get(domain.Key("k")), 2) != 1 {
+		t.Fatalf("expected only the raised difference to be available")
+	}
+	if !s.GetWithPolicy(domain.Key("other"), domain.Policy{Burst: 1}).Allow() {
+		t.Fatalf("expected the policy limit to apply to another key")
+	}
+}
+
+func TestRedisQuotaStore_PersistsPerWindow(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	clk := newFakeClock()
+	clk.advance(23 * time.Hour) // 23:00 UTC
+	mr.SetTime(clk.now())
+
+	newStore := func() *RedisQuotaStore {
+		s := NewRedisQuotaStore(rdb, domain.QuotaDay, 2)
+		s.now = clk.now
+		return s
+	}
+	lim := newStore().Get(domain.Key("k")).(domain.ReservingLimiter)
+	if res, _ := lim.Reserve(); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected first request allowed, got %+v", res)
+	}
+	if !mr.Exists("ratelimit:bucket:q:day:20240101:k") {
+		t.Fatalf("expected counter keyed by day, got keys %v", mr.Keys())
+	}
+	_, cancel := lim.Reserve()
+	cancel()
+
+	// outra instância (restart) enxerga o mesmo consumo
+	lim = newStore().Get(domain.Key("k")).(domain.ReservingLimiter)
+	if res, _ := lim.Reserve(); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected refunded slot to be usable after restart, got %+v", res)
+	}
+	res, _ := lim.Reserve()
+	if res.Allowed || res.RetryAfter != time.Hour {
+		t.Fatalf("expected deny until midnight, got %+v", res)
+	}
+
+	clk.advance(time.Hour)
+	if res, _ := lim.Reserve(); !res.Allowed {
+		t.Fatalf("expected new day to reset the quota, got %+v", res)
+	}
+}
@@ -0,0 +1,301 @@
+package infra
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// WindowLimit converte uma taxa (req/s) no número máximo de requisições por
+// janela. Ex: rps=100/60 com janela de 60s => 100. Sempre >= 1.
+func WindowLimit(rps float64, window time.Duration) int {
+	return max(1, int(math.Round(rps*window.Seconds())))
+}
+
+// windowParams guarda limite/janela de um store de janela, trocáveis em tempo
+// de execução (SetLimits).
+type windowParams struct {
+	mu     sync.RWMutex
+	limit  int
+	window time.Duration
+}
+
+func (p *windowParams) params() (int, time.Duration) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.limit, p.window
+}
+
+// RPS é a taxa média equivalente (limite / janela), usada em headers e logs.
+func (p *windowParams) RPS() float64 {
+	limit, window := p.params()
+	return float64(limit) / window.Seconds()
+}
+
+// Burst é o limite por janela (o máximo que pode passar de uma vez).
+func (p *windowParams) Burst() int {
+	limit, _ := p.params()
+	return limit
+}
+
+func (p *windowParams) Window() time.Duration {
+	_, window := p.params()
+	return window
+}
+
+// SetLimits troca o limite padrão a partir de uma taxa (burst é ignorado:
+// nos algoritmos de janela o máximo instantâneo é o próprio limite).
+func (p *windowParams) SetLimits(rps float64, _ int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = WindowLimit(rps, p.window)
+}
+
+// limitFor traduz uma política (rps) para o limite por janela deste store.
+func (p *windowParams) limitFor(pol domain.Policy) (int, time.Duration) {
+	_, window := p.params()
+	return WindowLimit(pol.RPS, window), window
+}
+
+// SlidingLogStore implementa domain.LimiterStore com sliding-window log:
+// guarda o horário de cada requisição aceita e permite no máximo `limit`
+// em qualquer intervalo de `window`. É exato, mas usa memória O(limit) por chave.
+type SlidingLogStore struct {
+	memKeyed[*slidingLog]
+	windowParams
+}
+
+// NewSlidingLogStore cria o store; limit < 1 vira 1, como em WindowLimit.
+func NewSlidingLogStore(limit int, window time.Duration, opts ...MemoryOption) *SlidingLogStore {
+	return &SlidingLogStore{
+		memKeyed:     newMemKeyed[*slidingLog](opts),
+		windowParams: windowParams{limit: max(1, limit), window: window},
+	}
+}
+
+// Get implementa domain.LimiterStore.
+func (s *SlidingLogStore) Get(key domain.Key) domain.Limiter {
+	limit, window := s.params()
+	return s.getLog(string(key), limit, window)
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *SlidingLogStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	limit, window := s.limitFor(p)
+	return s.getLog(string(key), limit, window)
+}
+
+func (s *SlidingLogStore) getLog(key string, limit int, window time.Duration) *slidingLog {
+	return s.memKeyed.get(key,
+		func(l *slidingLog) bool { return l.limit == limit && l.window == window },
+		func() *slidingLog { return &slidingLog{limit: limit, window: window, now: s.now} },
+	)
+}
+
+type slidingLog struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	now    func() time.Time
+	times  []time.Time
+}
+
+func (l *slidingLog) Allow() bool { return l.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (l *slidingLog) Take() domain.LimitResult {
+	res, _ := l.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter.
+func (l *slidingLog) Reserve() (domain.LimitResult, func()) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evict(now)
+	res := domain.LimitResult{Limit: l.limit, Window: l.window}
+	if len(l.times) >= l.limit {
+		// só volta a passar quando o registro que ocupa a vaga mais antiga sair
+		res.RetryAfter = l.times[len(l.times)-l.limit].Add(l.window).Sub(now)
+		res.Reset = l.times[len(l.times)-1].Add(l.window).Sub(now)
+		return res, nil
+	}
+	l.times = append(l.times, now)
+	res.Allowed = true
+	res.Remaining = l.limit - len(l.times)
+	res.Reset = l.window
+	return res, func() { l.remove(now) }
+}
+
+// remove apaga um registro com horário at (o mais recente, se houver vários).
+func (l *slidingLog) remove(at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.times) - 1; i >= 0; i-- {
+		if l.times[i].Equal(at) {
+			l.times = append(l.times[:i], l.times[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *slidingLog) expired(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.times) == 0 || !l.times[len(l.times)-1].After(now.Add(-l.window))
+}
+
+// evict descarta os registros que já saíram da janela (now-window, now].
+func (l *slidingLog) evict(now time.Time) {
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.times) && !l.times[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		l.times = append(l.times[:0], l.times[i:]...)
+	}
+}
+
+// SlidingCounterStore implementa domain.LimiterStore com sliding-window counter:
+// mantém só os contadores da janela fixa atual e da anterior, e estima a
+// contagem na janela deslizante ponderando a anterior pelo quanto ela ainda
+// se sobrepõe. Usa memória O(1) por chave, com pequena imprecisão.
+type SlidingCounterStore struct {
+	memKeyed[*slidingCounter]
+	windowParams
+}
+
+// NewSlidingCounterStore cria o store; limit < 1 vira 1, como em WindowLimit.
+func NewSlidingCounterStore(limit int, window time.Duration, opts ...MemoryOption) *SlidingCounterStore {
+	return &SlidingCounterStore{
+		memKeyed:     newMemKeyed[*slidingCounter](opts),
+		windowParams: windowParams{limit: max(1, limit), window: window},
+	}
+}
+
+// Get implementa domain.LimiterStore.
+func (s *SlidingCounterStore) Get(key domain.Key) domain.Limiter {
+	limit, window := s.params()
+	return s.getCounter(string(key), limit, window)
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *SlidingCounterStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	limit, window := s.limitFor(p)
+	return s.getCounter(string(key), limit, window)
+}
+
+func (s *SlidingCounterStore) getCounter(key string, limit int, window time.Duration) *slidingCounter {
+	return s.memKeyed.get(key,
+		func(c *slidingCounter) bool { return c.limit == limit && c.window == window },
+		func() *slidingCounter { return &slidingCounter{limit: limit, window: window, now: s.now} },
+	)
+}
+
+type slidingCounter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	start      time.Time // início da janela fixa atual
+	curr, prev int
+}
+
+func (c *slidingCounter) Allow() bool { return c.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (c *slidingCounter) Take() domain.LimitResult {
+	res, _ := c.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter.
+func (c *slidingCounter) Reserve() (domain.LimitResult, func()) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advance(now)
+	res := domain.LimitResult{Limit: c.limit, Window: c.window}
+	if c.estimate(now)+1 > float64(c.limit) {
+		res.RetryAfter = c.retryAfter(now)
+		res.Reset = c.resetAfter(now)
+		return res, nil
+	}
+	c.curr++
+	res.Allowed = true
+	res.Remaining = max(0, int(float64(c.limit)-c.estimate(now)))
+	res.Reset = c.resetAfter(now)
+	start := c.start
+	return res, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.start.Equal(start) && c.curr > 0 {
+			c.curr--
+		}
+	}
+}
+
+// retryAfter calcula quando a estimativa abre espaço para mais uma requisição:
+// ainda na janela atual (o peso da anterior cai com o tempo) ou na próxima.
+func (c *slidingCounter) retryAfter(now time.Time) time.Duration {
+	w := float64(c.window)
+	free := float64(c.limit - 1 - c.curr)
+	if c.prev > 0 && free >= 0 {
+		at := c.start.Add(time.Duration(w * (1 - free/float64(c.prev))))
+		if at.Before(c.start.Add(c.window)) {
+			return max(0, at.Sub(now))
+		}
+	}
+	next := c.start.Add(c.window)
+	if c.curr > 0 {
+		next = next.Add(time.Duration(max(0, w*(1-float64(c.limit-1)/float64(c.curr)))))
+	}
+	return next.Sub(now)
+}
+
+// resetAfter é o tempo até a estimativa zerar.
+func (c *slidingCounter) resetAfter(now time.Time) time.Duration {
+	switch {
+	case c.curr > 0:
+		return c.start.Add(2 * c.window).Sub(now)
+	case c.prev > 0:
+		return c.start.Add(c.window).Sub(now)
+	}
+	return 0
+}
+
+func (c *slidingCounter) expired(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !now.Before(c.start.Add(2 * c.window))
+}
+
+// advance move a janela fixa atual para a que contém now.
+func (c *slidingCounter) advance(now time.Time) {
+	ws := now.Truncate(c.window)
+	if ws.Equal(c.start) {
+		return
+	}
+	if ws.Sub(c.start) == c.window {
+		c.prev = c.curr
+	} else {
+		c.prev = 0
+	}
+	c.curr = 0
+	c.start = ws
+}
+
+// estimate é a contagem estimada na janela deslizante (now-window, now].
+func (c *slidingCounter) estimate(now time.Time) float64 {
+	weight := 1 - float64(now.Sub(c.start))/float64(c.window)
+	return float64(c.prev)*weight + float64(c.curr)
+}
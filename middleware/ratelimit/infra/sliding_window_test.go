@@ -0,0 +1,159 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func allowN(l domain.Limiter, n int) int {
+	ok := 0
+	for i := 0; i < n; i++ {
+		if l.Allow() {
+			ok++
+		}
+	}
+	return ok
+}
+
+func TestWindowLimit(t *testing.T) {
+	if got := WindowLimit(100.0/60, time.Minute); got != 100 {
+		t.Fatalf("expected 100 per minute, got %d", got)
+	}
+	if got := WindowLimit(0.01, time.Second); got != 1 {
+		t.Fatalf("expected limit to be at least 1, got %d", got)
+	}
+}
+
+func TestWindowStores_ClampLimitToOne(t *testing.T) {
+	cases := []struct {
+		name  string
+		store func(limit int) domain.LimiterStore
+		limit int
+	}{
+		{"log limit 0", func(n int) domain.LimiterStore { return NewSlidingLogStore(n, time.Second) }, 0},
+		{"log negative limit", func(n int) domain.LimiterStore { return NewSlidingLogStore(n, time.Second) }, -1},
+		{"counter limit 0", func(n int) domain.LimiterStore { return NewSlidingCounterStore(n, time.Second) }, 0},
+		{"fixed limit 0", func(n int) domain.LimiterStore { return NewFixedWindowStore(n, time.Second) }, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := allowN(c.store(c.limit).Get(domain.Key("ip")), 3); got != 1 {
+				t.Fatalf("expected limit %d to be clamped to 1, got %d allowed", c.limit, got)
+			}
+		})
+	}
+}
+
+func TestSlidingLogStore_AllowsAtMostLimitInAnyWindow(t *testing.T) {
+	clk := newFakeClock()
+	s := NewSlidingLogStore(3, time.Second, WithClock(clk.now))
+	lim := s.Get(domain.Key("k"))
+
+	if got := allowN(lim, 2); got != 2 {
+		t.Fatalf("expected 2 allowed, got %d", got)
+	}
+	clk.advance(600 * time.Millisecond)
+	if got := allowN(lim, 2); got != 1 {
+		t.Fatalf("expected only 1 more allowed within the window, got %d", got)
+	}
+
+	// os dois primeiros saem da janela em t=1s; o terceiro só em t=1.6s
+	clk.advance(400 * time.Millisecond)
+	if got := allowN(lim, 3); got != 2 {
+		t.Fatalf("expected 2 allowed after the first requests left the window, got %d", got)
+	}
+	clk.advance(599 * time.Millisecond)
+	if lim.Allow() {
+		t.Fatalf("expected deny while 3 requests are still in the window")
+	}
+	clk.advance(1 * time.Millisecond)
+	if !lim.Allow() {
+		t.Fatalf("expected allow once the oldest request left the window")
+	}
+}
+
+func TestSlidingCounterStore_WeightsPreviousWindow(t *testing.T) {
+	clk := newFakeClock()
+	s := NewSlidingCounterStore(10, time.Second, WithClock(clk.now))
+	lim := s.Get(domain.Key("k"))
+
+	if got := allowN(lim, 12); got != 10 {
+		t.Fatalf("expected 10 allowed in the first window, got %d", got)
+	}
+
+	// 25% da nova janela: a anterior pesa 75% => 7.5, sobram 2
+	clk.advance(1250 * time.Millisecond)
+	if got := allowN(lim, 5); got != 2 {
+		t.Fatalf("expected 2 allowed at 25%% of the next window, got %d", got)
+	}
+
+	// duas janelas sem tráfego zeram a estimativa
+	clk.advance(2 * time.Second)
+	if got := allowN(lim, 12); got != 10 {
+		t.Fatalf("expected full limit after idle windows, got %d", got)
+	}
+}
+
+func TestSlidingStores_GetWithPolicyAndSetLimits(t *testing.T) {
+	clk := newFakeClock()
+	stores := map[string]interface {
+		domain.PolicyLimiterStore
+		SetLimits(float64, int)
+		Burst() int
+	}{
+		"log":     NewSlidingLogStore(1, time.Minute, WithClock(clk.now)),
+		"counter": NewSlidingCounterStore(1, time.Minute, WithClock(clk.now)),
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			lim := s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 5.0 / 60})
+			if got := allowN(lim, 6); got != 5 {
+				t.Fatalf("expected policy limit of 5 per minute, got %d", got)
+			}
+			if s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 5.0 / 60}) != lim {
+				t.Fatalf("expected same limiter for same policy")
+			}
+
+			s.SetLimits(3.0/60, 0)
+			if s.Burst() != 3 {
+				t.Fatalf("expected limit 3 after SetLimits, got %d", s.Burst())
+			}
+			if got := allowN(s.Get(domain.Key("ip")), 4); got != 3 {
+				t.Fatalf("expected new default limit of 3, got %d", got)
+			}
+		})
+	}
+}
+
+func TestSlidingLogStore_BlockAndCleanup(t *testing.T) {
+	clk := newFakeClock()
+	s := NewSlidingLogStore(1, time.Second, WithClock(clk.now), WithMemoryIdleTTL(time.Minute))
+
+	s.Block(domain.Key("k"), 10*time.Second)
+	s.Block(domain.Key("k"), time.Hour)
+	if got := s.BlockedFor(domain.Key("k")); got != 10*time.Second {
+		t.Fatalf("expected active block not to be extended, got %s", got)
+	}
+
+	before := s.Get(domain.Key("k"))
+	clk.advance(2 * time.Minute)
+	s.Cleanup()
+	if s.BlockedFor(domain.Key("k")) != 0 {
+		t.Fatalf("expected block to expire")
+	}
+	if s.Get(domain.Key("k")) == before {
+		t.Fatalf("expected idle limiter to be recreated after cleanup")
+	}
+}
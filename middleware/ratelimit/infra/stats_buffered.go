@@ -0,0 +1,258 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// BufferedStatsStore tira a gravação das estatísticas do caminho da
+// requisição: Record só enfileira o evento e uma goroutine grava no store de
+// baixo em lotes, a cada intervalo ou quando o lote enche. Se o store de baixo
+// implementa domain.StatsBatchRecorder (ex: RedisStatsStore), o lote vai numa
+// chamada só. Antes de gravar, os eventos iguais no mesmo minuto (rota, chave,
+// resultado, limite) viram um só, com StatsEvent.Count.
+//
+// A fila é limitada: cheia, o evento é descartado e contado em Dropped (as
+// estatísticas são best-effort; a requisição nunca espera). Close grava o que
+// ainda estiver na fila; chame-o depois de parar de receber requisições.
+type BufferedStatsStore struct {
+	next  domain.StatsStore
+	queue chan domain.StatsEvent
+
+	flushEvery   time.Duration
+	batchSize    int
+	flushTimeout time.Duration
+	onError      func(error)
+
+	// mu sincroniza Record com Close: depois que Close marca closed, nenhum
+	// evento entra na fila, e o que já entrou é gravado pela goroutine.
+	mu        sync.RWMutex
+	closed    bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+type BufferedStatsOption func(*BufferedStatsStore)
+
+// WithBufferFlushEvery define o intervalo máximo entre gravações (padrão 1s).
+func WithBufferFlushEvery(d time.Duration) BufferedStatsOption {
+	return func(s *BufferedStatsStore) { s.flushEvery = d }
+}
+
+// WithBufferBatchSize grava assim que o lote atinge n eventos (padrão 500).
+func WithBufferBatchSize(n int) BufferedStatsOption {
+	return func(s *BufferedStatsStore) { s.batchSize = n }
+}
+
+// WithBufferQueueSize limita os eventos à espera de gravação (padrão 10000;
+// n <= 0 mantém o padrão).
+func WithBufferQueueSize(n int) BufferedStatsOption {
+	return func(s *BufferedStatsStore) {
+		if n > 0 {
+			s.queue = make(chan domain.StatsEvent, n)
+		}
+	}
+}
+
+// WithBufferFlushTimeout limita cada gravação no store de baixo (padrão 5s).
+func WithBufferFlushTimeout(d time.Duration) BufferedStatsOption {
+	return func(s *BufferedStatsStore) { s.flushTimeout = d }
+}
+
+// WithBufferOnError é chamado quando uma gravação falha (os eventos que não
+// foram gravados se perdem e são contados em Failed).
+func WithBufferOnError(fn func(error)) BufferedStatsOption {
+	return func(s *BufferedStatsStore) { s.onError = fn }
+}
+
+// NewBufferedStatsStore cria o buffer na frente de next e inicia a goroutine
+// de gravação; pare com Close.
+func NewBufferedStatsStore(next domain.StatsStore, opts ...BufferedStatsOption) *BufferedStatsStore {
+	s := &BufferedStatsStore{
+		next:         next,
+		queue:        make(chan domain.StatsEvent, 10000),
+		flushEvery:   time.Second,
+		batchSize:    500,
+		flushTimeout: 5 * time.Second,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.flushEvery <= 0 {
+		s.flushEvery = time.Second
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 1
+	}
+	go s.run()
+	return s
+}
+
+// Record enfileira ev sem bloquear. Com a fila cheia ou depois de Close, o
+// evento é descartado (Dropped).
+func (s *BufferedStatsStore) Record(_ context.Context, ev domain.StatsEvent) error {
+	if ev.At.IsZero() {
+		// o minuto da série é o do evento, não o da gravação
+		ev.At = time.Now()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return nil
+	}
+	select {
+	case s.queue <- ev:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// Close para de aceitar eventos e grava os que estão na fila. Devolve
+// ctx.Err() se ctx acabar antes (a gravação continua em segundo plano).
+func (s *BufferedStatsStore) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.stop)
+	})
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped conta os eventos descartados com a fila cheia ou depois de Close.
+func (s *BufferedStatsStore) Dropped() uint64 { return s.dropped.Load() }
+
+// Failed conta os eventos perdidos em gravações com erro.
+func (s *BufferedStatsStore) Failed() uint64 { return s.failed.Load() }
+
+// Pending é o número de eventos na fila.
+func (s *BufferedStatsStore) Pending() int { return len(s.queue) }
+
+func (s *BufferedStatsStore) run() {
+	defer close(s.done)
+
+	t := time.NewTicker(s.flushEvery)
+	defer t.Stop()
+
+	// o lote conta os eventos recebidos; agregados, ocupam no máximo isso
+	b := statsBatch{index: make(map[statsBatchKey]int)}
+	add := func(ev domain.StatsEvent) {
+		b.add(ev)
+		if b.n >= int64(s.batchSize) {
+			s.flush(&b)
+		}
+	}
+	for {
+		select {
+		case ev := <-s.queue:
+			add(ev)
+		case <-t.C:
+			s.flush(&b)
+		case <-s.stop:
+			// Close já impediu novos eventos: esvaziar a fila basta
+			for {
+				select {
+				case ev := <-s.queue:
+					add(ev)
+				default:
+					s.flush(&b)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush grava o lote no store de baixo e o esvazia para reuso.
+func (s *BufferedStatsStore) flush(b *statsBatch) {
+	if b.n == 0 {
+		return
+	}
+	defer b.reset()
+	ctx, cancel := context.WithTimeout(context.Background(), s.flushTimeout)
+	defer cancel()
+
+	var (
+		err    error
+		failed int64
+	)
+	if br, ok := s.next.(domain.StatsBatchRecorder); ok {
+		if err = br.RecordBatch(ctx, b.evs); err != nil {
+			failed = b.n
+		}
+	} else {
+		var errs []error
+		for _, ev := range b.evs {
+			if e := s.next.Record(ctx, ev); e != nil {
+				errs = append(errs, e)
+				failed += ev.N()
+			}
+		}
+		err = errors.Join(errs...)
+	}
+	if err != nil {
+		s.failed.Add(uint64(failed))
+		if s.onError != nil {
+			s.onError(err)
+		}
+	}
+}
+
+// statsBatch agrega os eventos à espera de gravação: eventos iguais no mesmo
+// minuto viram um só, com Count.
+type statsBatch struct {
+	evs   []domain.StatsEvent
+	index map[statsBatchKey]int
+	n     int64 // eventos recebidos
+}
+
+type statsBatchKey struct {
+	minute                       int64
+	key                          domain.Key
+	allowed, followUp            bool
+	limit, outcome, method, path string
+}
+
+func (b *statsBatch) add(ev domain.StatsEvent) {
+	n := ev.N()
+	b.n += n
+	k := statsBatchKey{
+		minute:   ev.At.Unix() / 60,
+		key:      ev.Key,
+		allowed:  ev.Allowed,
+		followUp: ev.FollowUp,
+		limit:    ev.Limit,
+		outcome:  ev.Outcome,
+		method:   ev.Method,
+		path:     ev.Path,
+	}
+	if i, ok := b.index[k]; ok {
+		b.evs[i].Count = b.evs[i].N() + n
+		return
+	}
+	b.index[k] = len(b.evs)
+	b.evs = append(b.evs, ev)
+}
+
+func (b *statsBatch) reset() {
+	clear(b.index)
+	b.evs, b.n = b.evs[:0], 0
+}
@@ -0,0 +1,224 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// batchStats grava os lotes recebidos (quantos eventos cada um representa, e
+// quantos eventos agregados chegaram); block segura a gravação até ser fechado.
+type batchStats struct {
+	mu      sync.Mutex
+	batches []int
+	total   int
+	sent    int
+	block   chan struct{}
+	err     error
+}
+
+func (b *batchStats) Record(ctx context.Context, ev domain.StatsEvent) error {
+	return b.RecordBatch(ctx, []domain.StatsEvent{ev})
+}
+
+func (b *batchStats) RecordBatch(_ context.Context, evs []domain.StatsEvent) error {
+	if b.block != nil {
+		<-b.block
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, ev := range evs {
+		n += int(ev.N())
+	}
+	b.batches = append(b.batches, n)
+	b.total += n
+	b.sent += len(evs)
+	return b.err
+}
+
+func (b *batchStats) snapshot() ([]int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]int(nil), b.batches...), b.total
+}
+
+func TestBufferedStatsStore_FlushesBySizeAndOnClose(t *testing.T) {
+	next := &batchStats{}
+	s := NewBufferedStatsStore(next, WithBufferBatchSize(3), WithBufferFlushEvery(time.Hour))
+	for i := 0; i < 7; i++ {
+		_ = s.Record(context.Background(), domain.StatsEvent{Allowed: true})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, total := next.snapshot(); total == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two full batches before the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	batches, total := next.snapshot()
+	if total != 7 || len(batches) != 3 || batches[2] != 1 {
+		t.Fatalf("expected the remainder flushed on close, got %v", batches)
+	}
+
+	_ = s.Record(context.Background(), domain.StatsEvent{})
+	if s.Dropped() != 1 {
+		t.Fatalf("expected events after close to be dropped, got %d", s.Dropped())
+	}
+}
+
+func TestBufferedStatsStore_FlushesOnInterval(t *testing.T) {
+	next := &batchStats{}
+	s := NewBufferedStatsStore(next, WithBufferFlushEvery(10*time.Millisecond))
+	defer func() { _ = s.Close(context.Background()) }()
+
+	_ = s.Record(context.Background(), domain.StatsEvent{Allowed: true})
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, total := next.snapshot(); total == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the event flushed by the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBufferedStatsStore_DropsWhenFullAndCountsFailures(t *testing.T) {
+	next := &batchStats{block: make(chan struct{}), err: errors.New("redis down")}
+	var errs int
+	s := NewBufferedStatsStore(next,
+		WithBufferBatchSize(1),
+		WithBufferQueueSize(2),
+		WithBufferOnError(func(error) { errs++ }),
+	)
+
+	// o primeiro evento fica preso na gravação; a fila guarda mais 2
+	_ = s.Record(context.Background(), domain.StatsEvent{})
+	deadline := time.Now().Add(time.Second)
+	for s.Pending() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the first event to leave the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.Record(context.Background(), domain.StatsEvent{}); err != nil {
+			t.Fatalf("expected Record to never fail, got %v", err)
+		}
+	}
+	if s.Dropped() != 3 || s.Pending() != 2 {
+		t.Fatalf("expected 3 dropped and 2 pending, got %d and %d", s.Dropped(), s.Pending())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Close to give up with a stuck store, got %v", err)
+	}
+	close(next.block)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.Failed() != 3 || errs != 3 {
+		t.Fatalf("expected 3 failed events reported, got %d (%d errors)", s.Failed(), errs)
+	}
+}
+
+func TestBufferedStatsStore_AggregatesPerMinuteRouteAndKey(t *testing.T) {
+	next := &batchStats{}
+	s := NewBufferedStatsStore(next, WithBufferFlushEvery(time.Hour))
+	at := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		_ = s.Record(context.Background(), domain.StatsEvent{Key: "a", Allowed: true, Method: "GET", Path: "/x", At: at.Add(time.Duration(i) * time.Second)})
+	}
+	_ = s.Record(context.Background(), domain.StatsEvent{Key: "b", Allowed: true, Method: "GET", Path: "/x", At: at})
+	_ = s.Record(context.Background(), domain.StatsEvent{Key: "a", Allowed: true, Method: "GET", Path: "/x", At: at.Add(time.Minute)})
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, total := next.snapshot(); total != 6 || next.sent != 3 {
+		t.Fatalf("expected 6 decisions in 3 aggregated events, got %d in %d", total, next.sent)
+	}
+}
+
+func TestBufferedStatsStore_RecordRacingCloseIsWrittenOrDropped(t *testing.T) {
+	for range 50 {
+		next := &batchStats{}
+		s := NewBufferedStatsStore(next)
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 50 {
+					_ = s.Record(context.Background(), domain.StatsEvent{Allowed: true})
+				}
+			}()
+		}
+		_ = s.Close(context.Background())
+		wg.Wait()
+		if _, total := next.snapshot(); uint64(total)+s.Dropped() != 400 {
+			t.Fatalf("expected every event written or dropped, got %d written and %d dropped", total, s.Dropped())
+		}
+	}
+}
+
+func TestBufferedStatsStore_IgnoresInvalidQueueSize(t *testing.T) {
+	next := &batchStats{block: make(chan struct{})}
+	s := NewBufferedStatsStore(next, WithBufferQueueSize(0), WithBufferBatchSize(1))
+	for i := 0; i < 10; i++ {
+		_ = s.Record(context.Background(), domain.StatsEvent{})
+	}
+	if s.Dropped() != 0 {
+		t.Fatalf("expected the default queue for size 0, got %d dropped", s.Dropped())
+	}
+	close(next.block)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisStatsStore_RecordBatchAggregates(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	s := NewRedisStatsStore(rdb, WithStatsTrackKeys(true))
+	at := time.Now()
+	err := s.RecordBatch(context.Background(), []domain.StatsEvent{
+		{Key: "a", Allowed: true, Method: "GET", Path: "/x", At: at},
+		{Key: "a", Allowed: false, Method: "GET", Path: "/x", Limit: "global", At: at},
+		{Key: "a", Allowed: false, Method: "GET", Path: "/x", Limit: "global", At: at},
+		{Allowed: false, Method: "GET", Path: "/x", Outcome: domain.OutcomeConcurrency, FollowUp: true, At: at},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := mr.HGet("ratelimit:stats:total", "denied"); v != "2" {
+		t.Fatalf("expected 2 denied in total, got %q", v)
+	}
+	if v := mr.HGet("ratelimit:stats:outcome", domain.OutcomeConcurrency); v != "1" {
+		t.Fatalf("expected the follow-up counted by outcome, got %q", v)
+	}
+	if v := mr.HGet("ratelimit:stats:route", "GET /x:allowed"); v != "1" {
+		t.Fatalf("expected 1 allowed for the route, got %q", v)
+	}
+	if v := mr.HGet("ratelimit:stats:limit", "global"); v != "2" {
+		t.Fatalf("expected 2 denials by the global limit, got %q", v)
+	}
+	if mr.TTL("ratelimit:stats:key:a") <= 0 || mr.TTL("ratelimit:stats:total") != 0 {
+		t.Fatalf("expected only the per-key and series hashes to expire")
+	}
+}
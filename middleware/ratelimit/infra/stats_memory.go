@@ -3,24 +3,29 @@ package infra
 import (
 	"context"
 	"sync"
+	"time"
 
 	"middleware-gateway/middleware/ratelimit/domain"
 )
 
-type Counters struct {
-	Allowed int64
-	Denied  int64
-}
+type Counters = domain.StatsCounts
 
 // MemoryStatsStore é uma implementação simples em memória.
 // Útil para testes e desenvolvimento.
 //
-// Não faz expiração e não é indicada para produção.
+// Não faz expiração (exceto da série por minuto, ver WithSeriesRetention) e
+// não é indicada para produção.
 type MemoryStatsStore struct {
 	mu      sync.Mutex
 	total   Counters
 	byRoute map[string]Counters
 	byKey   map[string]Counters
+	byLimit map[string]int64
+	// byOutcome conta as decisões fora dos limites (StatsEvent.Outcome).
+	byOutcome map[string]int64
+	// byMinute é a série temporal, por minuto (Unix) do evento.
+	byMinute  map[int64]Counters
+	retention time.Duration
 
 	trackKeys bool
 }
@@ -31,10 +36,21 @@ func WithTrackKeys(track bool) MemoryStatsOption {
 	return func(s *MemoryStatsStore) { s.trackKeys = track }
 }
 
+// WithSeriesRetention define por quanto tempo a série por minuto é mantida
+// (padrão 24h).
+func WithSeriesRetention(d time.Duration) MemoryStatsOption {
+	return func(s *MemoryStatsStore) { s.retention = d }
+}
+
 func NewMemoryStatsStore(opts ...MemoryStatsOption) *MemoryStatsStore {
 	s := &MemoryStatsStore{
 		byRoute: make(map[string]Counters),
 		byKey:   make(map[string]Counters),
+		byLimit: make(map[string]int64),
+
+		byOutcome: make(map[string]int64),
+		byMinute:  make(map[int64]Counters),
+		retention: 24 * time.Hour,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -45,30 +61,41 @@ func NewMemoryStatsStore(opts ...MemoryStatsOption) *MemoryStatsStore {
 func (s *MemoryStatsStore) Record(_ context.Context, ev domain.StatsEvent) error {
 	key := string(ev.Key)
 	route := ev.Method + " " + ev.Path
+	n := ev.N()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if ev.Outcome != "" {
+		s.byOutcome[ev.Outcome] += n
+	}
+	if ev.FollowUp {
+		return nil
+	}
+	s.addToSeries(ev)
 	if ev.Allowed {
-		s.total.Allowed++
+		s.total.Allowed += n
 		c := s.byRoute[route]
-		c.Allowed++
+		c.Allowed += n
 		s.byRoute[route] = c
 		if s.trackKeys {
 			k := s.byKey[key]
-			k.Allowed++
+			k.Allowed += n
 			s.byKey[key] = k
 		}
 		return nil
 	}
 
-	s.total.Denied++
+	s.total.Denied += n
 	c := s.byRoute[route]
-	c.Denied++
+	c.Denied += n
 	s.byRoute[route] = c
+	if ev.Limit != "" {
+		s.byLimit[ev.Limit] += n
+	}
 	if s.trackKeys {
 		k := s.byKey[key]
-		k.Denied++
+		k.Denied += n
 		s.byKey[key] = k
 	}
 	return nil
@@ -99,3 +126,27 @@ func (s *MemoryStatsStore) ByKey() map[string]Counters {
 	}
 	return out
 }
+
+// ByLimit conta as negações por limite (StatsEvent.Limit), quando a
+// requisição passa por vários limites.
+func (s *MemoryStatsStore) ByLimit() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int64, len(s.byLimit))
+	for k, v := range s.byLimit {
+		out[k] = v
+	}
+	return out
+}
+
+// ByOutcome conta as decisões tomadas fora dos limites (allowlist,
+// denylist, isenções), por StatsEvent.Outcome.
+func (s *MemoryStatsStore) ByOutcome() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int64, len(s.byOutcome))
+	for k, v := range s.byOutcome {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,22 @@
+package infra
+
+import (
+	"context"
+	"errors"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// MultiStatsStore repassa cada evento a todos os stores (ex: Redis e
+// Prometheus). Os erros são juntados; um store com erro não impede os demais.
+type MultiStatsStore []domain.StatsStore
+
+func (m MultiStatsStore) Record(ctx context.Context, ev domain.StatsEvent) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Record(ctx, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
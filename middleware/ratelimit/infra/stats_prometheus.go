@@ -0,0 +1,158 @@
+package infra
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// PrometheusStatsStore agrega os StatsEvent em contadores no formato do
+// Prometheus (ver WritePrometheus):
+//
+//	ratelimit_requests_total{route,outcome}   decisões por rota
+//	ratelimit_denied_total{limit}             negações por limite (Options.Limits)
+//	ratelimit_key_requests_total{key,outcome} por chave, só com WithPromTrackKeys
+//	ratelimit_followup_total{route,outcome}   recusas depois do rate limit
+//	                                          (StatsEvent.FollowUp, ex: concurrency)
+//
+// outcome é StatsEvent.Outcome ou, sem ele, "allowed"/"denied".
+//
+// Cardinalidade (ver domain.StatsEvent): a rota passa por um template (ids
+// numéricos, UUIDs e hashes viram ":id") e, assim como as chaves, é limitada
+// a um número máximo de valores distintos; o excedente é contado em "other".
+type PrometheusStatsStore struct {
+	mu       sync.Mutex
+	requests *promCounterVec
+	denied   *promCounterVec
+	keys     *promCounterVec
+	followUp *promCounterVec
+	routes   labelCap
+	keyCap   labelCap
+
+	routeFn   func(method, path string) string
+	trackKeys bool
+}
+
+type PromStatsOption func(*PrometheusStatsStore)
+
+// WithPromRouteFunc troca o template de rota (padrão TemplateRoute).
+func WithPromRouteFunc(fn func(method, path string) string) PromStatsOption {
+	return func(s *PrometheusStatsStore) { s.routeFn = fn }
+}
+
+// WithPromMaxRoutes limita as rotas distintas (padrão 100; 0 = sem limite).
+func WithPromMaxRoutes(n int) PromStatsOption {
+	return func(s *PrometheusStatsStore) { s.routes.max = n }
+}
+
+// WithPromTrackKeys liga a série por chave (desligada por padrão).
+func WithPromTrackKeys(track bool) PromStatsOption {
+	return func(s *PrometheusStatsStore) { s.trackKeys = track }
+}
+
+// WithPromMaxKeys limita as chaves distintas com WithPromTrackKeys (padrão 1000).
+func WithPromMaxKeys(n int) PromStatsOption {
+	return func(s *PrometheusStatsStore) { s.keyCap.max = n }
+}
+
+func NewPrometheusStatsStore(opts ...PromStatsOption) *PrometheusStatsStore {
+	s := &PrometheusStatsStore{
+		requests: newPromCounterVec("ratelimit_requests_total", "Rate limit decisions by route and outcome.", "route", "outcome"),
+		denied:   newPromCounterVec("ratelimit_denied_total", "Rate limit denials by limit.", "limit"),
+		keys:     newPromCounterVec("ratelimit_key_requests_total", "Rate limit decisions by client key.", "key", "outcome"),
+		followUp: newPromCounterVec("ratelimit_followup_total", "Rejections after the rate limit by route and outcome.", "route", "outcome"),
+		routes:   newLabelCap(100),
+		keyCap:   newLabelCap(1000),
+		routeFn:  TemplateRoute,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *PrometheusStatsStore) Record(_ context.Context, ev domain.StatsEvent) error {
+	outcome := ev.Outcome
+	if outcome == "" {
+		outcome = "denied"
+		if ev.Allowed {
+			outcome = "allowed"
+		}
+	}
+	route := s.routeFn(ev.Method, ev.Path)
+	n := float64(ev.N())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ev.FollowUp {
+		s.followUp.add(n, s.routes.value(route), outcome)
+		return nil
+	}
+	s.requests.add(n, s.routes.value(route), outcome)
+	if !ev.Allowed && ev.Limit != "" {
+		s.denied.add(n, ev.Limit)
+	}
+	if s.trackKeys && ev.Key != "" {
+		s.keys.add(n, s.keyCap.value(string(ev.Key)), outcome)
+	}
+	return nil
+}
+
+// Route é o valor do label route para a requisição (template e limite de
+// cardinalidade aplicados), para outras métricas usarem as mesmas rotas.
+func (s *PrometheusStatsStore) Route(method, path string) string {
+	route := s.routeFn(method, path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.routes.value(route)
+}
+
+// WritePrometheus escreve os contadores no formato texto.
+func (s *PrometheusStatsStore) WritePrometheus(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	s.mu.Lock()
+	s.requests.write(w)
+	s.denied.write(w)
+	s.followUp.write(w)
+	if s.trackKeys {
+		s.keys.write(w)
+	}
+	s.mu.Unlock()
+
+	return w.Flush()
+}
+
+// TemplateRoute é "MÉTODO /caminho" com os segmentos que parecem
+// identificadores trocados por ":id", ex: "GET /users/42/orders" =>
+// "GET /users/:id/orders".
+func TemplateRoute(method, path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if looksLikeID(seg) {
+			segs[i] = ":id"
+		}
+	}
+	return strings.TrimSpace(method + " " + strings.Join(segs, "/"))
+}
+
+// looksLikeID reconhece números, UUIDs e hashes hexadecimais longos.
+func looksLikeID(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	digits, hex := true, true
+	for _, r := range seg {
+		isDigit := r >= '0' && r <= '9'
+		isHex := isDigit || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') || r == '-'
+		digits = digits && isDigit
+		hex = hex && isHex
+	}
+	return digits || (hex && len(seg) >= 16)
+}
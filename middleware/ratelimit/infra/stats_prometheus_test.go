@@ -0,0 +1,89 @@
+package infra
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestPrometheusStatsStore_CountsByRouteAndOutcome(t *testing.T) {
+	ctx := context.Background()
+	s := NewPrometheusStatsStore(WithPromMaxRoutes(2))
+	_ = s.Record(ctx, domain.StatsEvent{Key: "a", Allowed: true, Method: "GET", Path: "/users/42"})
+	_ = s.Record(ctx, domain.StatsEvent{Key: "a", Allowed: true, Method: "GET", Path: "/users/7"})
+	_ = s.Record(ctx, domain.StatsEvent{Key: "b", Limit: "global", Method: "GET", Path: "/users/7"})
+	_ = s.Record(ctx, domain.StatsEvent{Key: "c", Outcome: domain.OutcomeDenylisted, Method: "POST", Path: "/login"})
+	_ = s.Record(ctx, domain.StatsEvent{Key: "d", Allowed: true, Method: "GET", Path: "/health"})
+
+	var b strings.Builder
+	if err := s.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE ratelimit_requests_total counter\n",
+		`ratelimit_requests_total{route="GET /users/:id",outcome="allowed"} 2` + "\n",
+		`ratelimit_requests_total{route="GET /users/:id",outcome="denied"} 1` + "\n",
+		`ratelimit_requests_total{route="POST /login",outcome="denylisted"} 1` + "\n",
+		`ratelimit_requests_total{route="other",outcome="allowed"} 1` + "\n",
+		`ratelimit_denied_total{limit="global"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ratelimit_key_requests_total") {
+		t.Fatalf("expected key series to be off by default")
+	}
+}
+
+func TestPrometheusStatsStore_TrackKeysIsCapped(t *testing.T) {
+	s := NewPrometheusStatsStore(WithPromTrackKeys(true), WithPromMaxKeys(1))
+	_ = s.Record(context.Background(), domain.StatsEvent{Key: "a\"b", Allowed: true})
+	_ = s.Record(context.Background(), domain.StatsEvent{Key: "c", Allowed: true})
+
+	var b strings.Builder
+	_ = s.WritePrometheus(&b)
+	if !strings.Contains(b.String(), `ratelimit_key_requests_total{key="a\"b",outcome="allowed"} 1`) ||
+		!strings.Contains(b.String(), `ratelimit_key_requests_total{key="other",outcome="allowed"} 1`) {
+		t.Fatalf("unexpected key series:\n%s", b.String())
+	}
+}
+
+func TestPromHistogram_CumulativeBuckets(t *testing.T) {
+	h := NewPromHistogram("lat_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "r")
+	h.Observe(0.1, "r")
+	h.Observe(0.5, "r")
+	h.Observe(3, "r")
+
+	var b strings.Builder
+	_ = h.WritePrometheus(&b)
+	for _, want := range []string{
+		`lat_seconds_bucket{route="r",le="0.1"} 2`,
+		`lat_seconds_bucket{route="r",le="1"} 3`,
+		`lat_seconds_bucket{route="r",le="+Inf"} 4`,
+		`lat_seconds_sum{route="r"} 3.65`,
+		`lat_seconds_count{route="r"} 4`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Fatalf("expected %q in output:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestTemplateRoute(t *testing.T) {
+	cases := map[string]string{
+		"/users/42/orders": "GET /users/:id/orders",
+		"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301": "GET /orders/:id",
+		"/blobs/deadbeefdeadbeef":                      "GET /blobs/:id",
+		"/api/v1/cafe":                                 "GET /api/v1/cafe",
+	}
+	for path, want := range cases {
+		if got := TemplateRoute("GET", path); got != want {
+			t.Fatalf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+}
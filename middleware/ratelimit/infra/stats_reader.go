@@ -0,0 +1,136 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// Leitura das estatísticas (domain.StatsReader) do MemoryStatsStore.
+
+// maxSeriesPoints limita o intervalo de Series (7 dias de minutos).
+const maxSeriesPoints = 7 * 24 * 60
+
+var (
+	// ErrSeriesRange: o intervalo pedido a Series passa de 7 dias.
+	ErrSeriesRange = errors.New("stats: series range is limited to 7 days")
+	// ErrNoSeries: o store não guarda a série por minuto (bucket "none").
+	ErrNoSeries = errors.New("stats: time series is disabled")
+)
+
+// addToSeries soma ev ao minuto dele e descarta os minutos fora da retenção.
+// Chame com s.mu.
+func (s *MemoryStatsStore) addToSeries(ev domain.StatsEvent) {
+	at := ev.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	minute := at.Unix() / 60
+	c, seen := s.byMinute[minute]
+	if ev.Allowed {
+		c.Allowed += ev.N()
+	} else {
+		c.Denied += ev.N()
+	}
+	s.byMinute[minute] = c
+
+	if !seen && s.retention > 0 {
+		oldest := minute - int64(s.retention/time.Minute)
+		for m := range s.byMinute {
+			if m < oldest {
+				delete(s.byMinute, m)
+			}
+		}
+	}
+}
+
+// Totals implementa domain.StatsReader.
+func (s *MemoryStatsStore) Totals(_ context.Context) (domain.StatsCounts, error) {
+	return s.Total(), nil
+}
+
+// Series implementa domain.StatsReader.
+func (s *MemoryStatsStore) Series(_ context.Context, from, to time.Time) ([]domain.StatsPoint, error) {
+	minutes, err := seriesMinutes(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]domain.StatsPoint, len(minutes))
+	for i, m := range minutes {
+		out[i] = domain.StatsPoint{At: m, StatsCounts: s.byMinute[m.Unix()/60]}
+	}
+	return out, nil
+}
+
+// TopRoutes implementa domain.StatsReader.
+func (s *MemoryStatsStore) TopRoutes(_ context.Context, n int) ([]domain.RouteStats, error) {
+	routes := s.ByRoute()
+	out := make([]domain.RouteStats, 0, len(routes))
+	for r, c := range routes {
+		out = append(out, domain.RouteStats{Route: r, StatsCounts: c})
+	}
+	return topRoutes(out, n), nil
+}
+
+// TopDeniedKeys implementa domain.StatsReader (só com WithTrackKeys).
+func (s *MemoryStatsStore) TopDeniedKeys(_ context.Context, n int) ([]domain.KeyStats, error) {
+	keys := s.ByKey()
+	out := make([]domain.KeyStats, 0, len(keys))
+	for k, c := range keys {
+		if c.Denied > 0 {
+			out = append(out, domain.KeyStats{Key: domain.Key(k), StatsCounts: c})
+		}
+	}
+	return topDeniedKeys(out, n), nil
+}
+
+// seriesMinutes são os inícios dos minutos em [from, to), em UTC.
+func seriesMinutes(from, to time.Time) ([]time.Time, error) {
+	from, to = from.UTC().Truncate(time.Minute), to.UTC()
+	if !to.After(from) {
+		return nil, nil
+	}
+	n := int((to.Sub(from) + time.Minute - 1) / time.Minute)
+	if n > maxSeriesPoints {
+		return nil, ErrSeriesRange
+	}
+	out := make([]time.Time, n)
+	for i := range out {
+		out[i] = from.Add(time.Duration(i) * time.Minute)
+	}
+	return out, nil
+}
+
+func topRoutes(rs []domain.RouteStats, n int) []domain.RouteStats {
+	sort.Slice(rs, func(i, j int) bool {
+		ti, tj := rs[i].Allowed+rs[i].Denied, rs[j].Allowed+rs[j].Denied
+		if ti != tj {
+			return ti > tj
+		}
+		return rs[i].Route < rs[j].Route
+	})
+	if n > 0 && len(rs) > n {
+		rs = rs[:n]
+	}
+	return rs
+}
+
+func topDeniedKeys(ks []domain.KeyStats, n int) []domain.KeyStats {
+	sort.Slice(ks, func(i, j int) bool {
+		if ks[i].Denied != ks[j].Denied {
+			return ks[i].Denied > ks[j].Denied
+		}
+		return ks[i].Key < ks[j].Key
+	})
+	if n > 0 && len(ks) > n {
+		ks = ks[:n]
+	}
+	return ks
+}
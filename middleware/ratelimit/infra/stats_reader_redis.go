@@ -0,0 +1,132 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Leitura das estatísticas (domain.StatsReader) do RedisStatsStore.
+
+// Totals implementa domain.StatsReader.
+func (s *RedisStatsStore) Totals(ctx context.Context) (domain.StatsCounts, error) {
+	m, err := s.rdb.HGetAll(ctx, s.prefix+":total").Result()
+	if err != nil {
+		return domain.StatsCounts{}, err
+	}
+	return countsFromHash(m), nil
+}
+
+// Series implementa domain.StatsReader com um HGETALL por minuto, em
+// pipeline. Minutos mais antigos que o TTL do store já expiraram e voltam
+// zerados.
+func (s *RedisStatsStore) Series(ctx context.Context, from, to time.Time) ([]domain.StatsPoint, error) {
+	if s.bucket != "minute" {
+		return nil, ErrNoSeries
+	}
+	minutes, err := seriesMinutes(from, to)
+	if err != nil || len(minutes) == 0 {
+		return nil, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(minutes))
+	for i, m := range minutes {
+		cmds[i] = pipe.HGetAll(ctx, fmt.Sprintf("%s:minute:%s", s.prefix, m.Format("200601021504")))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make([]domain.StatsPoint, len(minutes))
+	for i, m := range minutes {
+		out[i] = domain.StatsPoint{At: m, StatsCounts: countsFromHash(cmds[i].Val())}
+	}
+	return out, nil
+}
+
+// TopRoutes implementa domain.StatsReader. O hash de rotas é lido inteiro:
+// sua cardinalidade é a do número de rotas (ver domain.StatsEvent).
+func (s *RedisStatsStore) TopRoutes(ctx context.Context, n int) ([]domain.RouteStats, error) {
+	m, err := s.rdb.HGetAll(ctx, s.prefix+":route").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byRoute := make(map[string]domain.StatsCounts)
+	for field, v := range m {
+		// campo "<rota>:<allowed|denied>"; a rota pode conter ":"
+		i := strings.LastIndex(field, ":")
+		if i < 0 {
+			continue
+		}
+		route := field[:i]
+		c := byRoute[route]
+		addCount(&c, field[i+1:], v)
+		byRoute[route] = c
+	}
+
+	out := make([]domain.RouteStats, 0, len(byRoute))
+	for r, c := range byRoute {
+		out = append(out, domain.RouteStats{Route: r, StatsCounts: c})
+	}
+	return topRoutes(out, n), nil
+}
+
+// TopDeniedKeys implementa domain.StatsReader a partir do ranking gravado
+// com WithStatsTrackKeys; sem ele a lista é vazia.
+func (s *RedisStatsStore) TopDeniedKeys(ctx context.Context, n int) ([]domain.KeyStats, error) {
+	stop := int64(-1)
+	if n > 0 {
+		stop = int64(n) - 1
+	}
+	ranked, err := s.rdb.ZRevRangeWithScores(ctx, s.prefix+":denied_keys", 0, stop).Result()
+	if err != nil || len(ranked) == 0 {
+		return nil, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ranked))
+	for i, z := range ranked {
+		cmds[i] = pipe.HGetAll(ctx, s.prefix+":key:"+z.Member.(string))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make([]domain.KeyStats, len(ranked))
+	for i, z := range ranked {
+		c := countsFromHash(cmds[i].Val())
+		// o hash da chave pode ter expirado antes do ranking
+		c.Denied = max(c.Denied, int64(z.Score))
+		out[i] = domain.KeyStats{Key: domain.Key(z.Member.(string)), StatsCounts: c}
+	}
+	return topDeniedKeys(out, n), nil
+}
+
+func countsFromHash(m map[string]string) domain.StatsCounts {
+	var c domain.StatsCounts
+	for field, v := range m {
+		addCount(&c, field, v)
+	}
+	return c
+}
+
+func addCount(c *domain.StatsCounts, field, v string) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return
+	}
+	switch field {
+	case "allowed":
+		c.Allowed += n
+	case "denied":
+		c.Denied += n
+	}
+}
@@ -0,0 +1,101 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// recordSample grava eventos em 3 minutos a partir de t0 (conferidos por
+// checkReader).
+func recordSample(t *testing.T, s domain.StatsStore, t0 time.Time) {
+	t.Helper()
+	evs := []domain.StatsEvent{
+		{Key: "a", Allowed: true, Method: "GET", Path: "/x", At: t0},
+		{Key: "a", Allowed: false, Method: "GET", Path: "/x", At: t0.Add(10 * time.Second)},
+		{Key: "b", Allowed: false, Method: "GET", Path: "/x", At: t0.Add(70 * time.Second)},
+		{Key: "b", Allowed: false, Method: "POST", Path: "/y", At: t0.Add(80 * time.Second)},
+		{Key: "c", Allowed: true, Method: "POST", Path: "/y", At: t0.Add(150 * time.Second)},
+	}
+	for _, ev := range evs {
+		if err := s.Record(context.Background(), ev); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+}
+
+func checkReader(t *testing.T, r domain.StatsReader, t0 time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	tot, err := r.Totals(ctx)
+	if err != nil || tot.Allowed != 2 || tot.Denied != 3 {
+		t.Fatalf("expected 2 allowed and 3 denied, got %+v (%v)", tot, err)
+	}
+
+	series, err := r.Series(ctx, t0.Add(-time.Minute), t0.Add(3*time.Minute))
+	if err != nil || len(series) != 4 {
+		t.Fatalf("expected 4 points, got %d (%v)", len(series), err)
+	}
+	want := []domain.StatsCounts{{}, {Allowed: 1, Denied: 1}, {Denied: 2}, {Allowed: 1}}
+	for i, p := range series {
+		if p.StatsCounts != want[i] || !p.At.Equal(t0.Add(time.Duration(i-1)*time.Minute)) {
+			t.Fatalf("point %d: expected %+v at %s, got %+v at %s", i, want[i], t0.Add(time.Duration(i-1)*time.Minute), p.StatsCounts, p.At)
+		}
+	}
+
+	routes, err := r.TopRoutes(ctx, 1)
+	if err != nil || len(routes) != 1 || routes[0].Route != "GET /x" || routes[0].Allowed != 1 || routes[0].Denied != 2 {
+		t.Fatalf("expected GET /x as top route, got %+v (%v)", routes, err)
+	}
+
+	keys, err := r.TopDeniedKeys(ctx, 10)
+	if err != nil || len(keys) != 2 || keys[0].Key != "b" || keys[0].Denied != 2 || keys[1].Key != "a" || keys[1].Allowed != 1 {
+		t.Fatalf("expected b then a as top denied keys, got %+v (%v)", keys, err)
+	}
+}
+
+func TestMemoryStatsStore_Reader(t *testing.T) {
+	t0 := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	s := NewMemoryStatsStore(WithTrackKeys(true))
+	recordSample(t, s, t0)
+	checkReader(t, s, t0)
+
+	if _, err := s.Series(context.Background(), t0, t0.Add(8*24*time.Hour)); !errors.Is(err, ErrSeriesRange) {
+		t.Fatalf("expected ErrSeriesRange, got %v", err)
+	}
+}
+
+func TestMemoryStatsStore_SeriesRetention(t *testing.T) {
+	t0 := time.Now().UTC().Truncate(time.Minute)
+	s := NewMemoryStatsStore(WithSeriesRetention(time.Hour))
+	_ = s.Record(context.Background(), domain.StatsEvent{Allowed: true, At: t0.Add(-2 * time.Hour)})
+	_ = s.Record(context.Background(), domain.StatsEvent{Allowed: true, At: t0})
+
+	series, _ := s.Series(context.Background(), t0.Add(-2*time.Hour), t0.Add(time.Minute))
+	if series[0].Allowed != 0 || series[len(series)-1].Allowed != 1 {
+		t.Fatalf("expected minutes older than the retention to be dropped, got %+v and %+v", series[0], series[len(series)-1])
+	}
+}
+
+func TestRedisStatsStore_Reader(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	t0 := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	s := NewRedisStatsStore(rdb, WithStatsTrackKeys(true))
+	recordSample(t, s, t0)
+	checkReader(t, s, t0)
+}
+
+func TestRedisStatsStore_SeriesDisabled(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	s := NewRedisStatsStore(rdb, WithStatsBucket("none"))
+	if _, err := s.Series(context.Background(), time.Now().Add(-time.Hour), time.Now()); !errors.Is(err, ErrNoSeries) {
+		t.Fatalf("expected ErrNoSeries, got %v", err)
+	}
+	if keys, err := s.TopDeniedKeys(context.Background(), 10); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no keys without tracking, got %+v (%v)", keys, err)
+	}
+}
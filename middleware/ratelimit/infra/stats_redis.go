@@ -58,53 +58,97 @@ func NewRedisStatsStore(rdb *redis.Client, opts ...RedisStatsOption) *RedisStats
 }
 
 func (s *RedisStatsStore) Record(ctx context.Context, ev domain.StatsEvent) error {
-	if s == nil || s.rdb == nil {
+	return s.RecordBatch(ctx, []domain.StatsEvent{ev})
+}
+
+// statsField é um campo de hash a incrementar.
+type statsField struct{ key, field string }
+
+// RecordBatch implementa domain.StatsBatchRecorder: soma os incrementos dos
+// eventos e os grava num único pipeline (um HINCRBY por campo, não por evento).
+func (s *RedisStatsStore) RecordBatch(ctx context.Context, evs []domain.StatsEvent) error {
+	if s == nil || s.rdb == nil || len(evs) == 0 {
 		return nil
 	}
 
-	at := ev.At
-	if at.IsZero() {
-		at = time.Now()
-	}
+	var (
+		hincr  = make(map[statsField]int64)
+		zincr  = make(map[string]float64)
+		expire = make(map[string]bool)
+	)
+	for _, ev := range evs {
+		n := ev.N()
+		if ev.FollowUp {
+			// já contado como decisão: só o outcome
+			if ev.Outcome != "" {
+				hincr[statsField{s.prefix + ":outcome", ev.Outcome}] += n
+			}
+			continue
+		}
+		at := ev.At
+		if at.IsZero() {
+			at = time.Now()
+		}
 
-	field := "denied"
-	if ev.Allowed {
-		field = "allowed"
-	}
+		field := "denied"
+		if ev.Allowed {
+			field = "allowed"
+		}
 
-	totalKey := s.prefix + ":total"
+		hincr[statsField{s.prefix + ":total", field}] += n
 
-	pipe := s.rdb.Pipeline()
-	pipe.HIncrBy(ctx, totalKey, field, 1)
+		if s.bucket == "minute" {
+			bucketKey := fmt.Sprintf("%s:minute:%s", s.prefix, at.UTC().Format("200601021504"))
+			hincr[statsField{bucketKey, field}] += n
+			expire[bucketKey] = true
+		}
 
-	if s.bucket == "minute" {
-		bucketKey := fmt.Sprintf("%s:minute:%s", s.prefix, at.UTC().Format("200601021504"))
-		pipe.HIncrBy(ctx, bucketKey, field, 1)
-		if s.ttl > 0 {
-			pipe.Expire(ctx, bucketKey, s.ttl)
+		if ev.Method != "" || ev.Path != "" {
+			routeField := strings.TrimSpace(ev.Method) + " " + strings.TrimSpace(ev.Path)
+			routeField = strings.TrimSpace(routeField)
+			if routeField != "" {
+				hincr[statsField{s.prefix + ":route", routeField + ":" + field}] += n
+			}
 		}
-	}
 
-	if ev.Method != "" || ev.Path != "" {
-		routeKey := s.prefix + ":route"
-		routeField := strings.TrimSpace(ev.Method) + " " + strings.TrimSpace(ev.Path)
-		routeField = strings.TrimSpace(routeField)
-		if routeField != "" {
-			pipe.HIncrBy(ctx, routeKey, routeField+":"+field, 1)
+		if !ev.Allowed && ev.Limit != "" {
+			// negações por limite, quando a requisição passa por vários limites
+			hincr[statsField{s.prefix + ":limit", ev.Limit}] += n
 		}
-	}
 
-	if s.trackKeys {
-		k := strings.TrimSpace(string(ev.Key))
-		if k != "" {
-			keyKey := s.prefix + ":key:" + k
-			pipe.HIncrBy(ctx, keyKey, field, 1)
-			if s.ttl > 0 {
-				pipe.Expire(ctx, keyKey, s.ttl)
+		if ev.Outcome != "" {
+			// decisões fora dos limites (allowlist, denylist, isenções)
+			hincr[statsField{s.prefix + ":outcome", ev.Outcome}] += n
+		}
+
+		if s.trackKeys {
+			k := strings.TrimSpace(string(ev.Key))
+			if k != "" {
+				keyKey := s.prefix + ":key:" + k
+				hincr[statsField{keyKey, field}] += n
+				expire[keyKey] = true
+				if !ev.Allowed {
+					// ranking para TopDeniedKeys
+					zincr[k] += float64(n)
+					expire[s.prefix+":denied_keys"] = true
+				}
 			}
 		}
 	}
 
+	pipe := s.rdb.Pipeline()
+	for f, n := range hincr {
+		pipe.HIncrBy(ctx, f.key, f.field, n)
+	}
+	for k, n := range zincr {
+		pipe.ZIncrBy(ctx, s.prefix+":denied_keys", n, k)
+	}
+	if s.ttl > 0 {
+		for k := range expire {
+			pipe.Expire(ctx, k, s.ttl)
+		}
+	}
+
 	_, err := pipe.Exec(ctx)
 	return err
 }
@@ -14,6 +14,8 @@ import (
 type Store struct {
 	mu           sync.Mutex
 	entries      map[string]*storeEntry
+	blocked      map[string]time.Time
+	exempt       map[string]time.Time
 	rps          rate.Limit
 	burst        int
 	idleTTL      time.Duration
@@ -25,6 +27,11 @@ type storeEntry struct {
 	lastSeen time.Time
 }
 
+// matches informa se o limiter da entrada foi criado com os mesmos parâmetros.
+func (e *storeEntry) matches(rps rate.Limit, burst int) bool {
+	return e.lim.Limit() == rps && e.lim.Burst() == burst
+}
+
 type StoreOption func(*Store)
 
 func WithIdleTTL(d time.Duration) StoreOption {
@@ -38,6 +45,8 @@ func WithCleanupEvery(d time.Duration) StoreOption {
 func NewStore(rps float64, burst int, opts ...StoreOption) *Store {
 	s := &Store{
 		entries:      make(map[string]*storeEntry),
+		blocked:      make(map[string]time.Time),
+		exempt:       make(map[string]time.Time),
 		rps:          rate.Limit(rps),
 		burst:        burst,
 		idleTTL:      15 * time.Minute,
@@ -49,33 +58,154 @@ func NewStore(rps float64, burst int, opts ...StoreOption) *Store {
 	return s
 }
 
-func (s *Store) RPS() float64  { return float64(s.rps) }
-func (s *Store) Burst() int   { return s.burst }
+func (s *Store) RPS() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return float64(s.rps)
+}
+
+func (s *Store) Burst() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.burst
+}
+
 func (s *Store) CleanupEvery() time.Duration { return s.cleanupEvery }
 
+// SetLimits troca o limite padrão (ex: hot reload). Chaves cujo limiter já
+// está com os novos parâmetros mantêm o estado; as demais são recriadas
+// no próximo acesso.
+func (s *Store) SetLimits(rps float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rps = rate.Limit(rps)
+	s.burst = burst
+}
+
 // Get implementa domain.LimiterStore.
 func (s *Store) Get(key domain.Key) domain.Limiter {
-	return s.GetString(string(key))
+	return tokenBucket{s.GetString(string(key))}
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+//
+// Se a chave já tinha um limiter com outros parâmetros, ele é recriado.
+func (s *Store) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	return tokenBucket{s.getLimiter(string(key), rate.Limit(p.RPS), p.Burst)}
 }
 
 func (s *Store) GetString(key string) *rate.Limiter {
-	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLimiterLocked(key, s.rps, s.burst)
+}
 
+func (s *Store) getLimiter(key string, rps rate.Limit, burst int) *rate.Limiter {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.getLimiterLocked(key, rps, burst)
+}
+
+func (s *Store) getLimiterLocked(key string, rps rate.Limit, burst int) *rate.Limiter {
+	now := time.Now()
 
-	if ent, ok := s.entries[key]; ok {
+	if ent, ok := s.entries[key]; ok && ent.matches(rps, burst) {
 		ent.lastSeen = now
 		return ent.lim
 	}
 
-	lim := rate.NewLimiter(s.rps, s.burst)
+	lim := rate.NewLimiter(rps, burst)
 	s.entries[key] = &storeEntry{lim: lim, lastSeen: now}
 	return lim
 }
 
+// tokenBucket adapta *rate.Limiter para domain.ReportingLimiter.
+type tokenBucket struct {
+	*rate.Limiter
+}
+
+// Take implementa domain.ReportingLimiter.
+func (l tokenBucket) Take() domain.LimitResult {
+	res, _ := l.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter: reserva um token e, se ele não
+// estiver disponível agora, cancela a reserva e informa a espera.
+func (l tokenBucket) Reserve() (domain.LimitResult, func()) {
+	now := time.Now()
+	res := domain.LimitResult{Limit: l.Burst(), Window: refillWindow(float64(l.Limit()), l.Burst())}
+
+	r := l.ReserveN(now, 1)
+	if !r.OK() {
+		// burst 0: nunca vai passar
+		return res, nil
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		res.RetryAfter = delay
+		res.Reset = l.refillTime(now)
+		return res, nil
+	}
+
+	res.Allowed = true
+	res.Remaining = max(0, int(l.TokensAt(now)))
+	res.Reset = l.refillTime(now)
+	// CancelAt só devolve o token para um instante até o da reserva
+	return res, func() { r.CancelAt(now) }
+}
+
+// refillWindow é o tempo para encher um bucket vazio (burst / rps).
+func refillWindow(rps float64, burst int) time.Duration {
+	if rps <= 0 || rate.Limit(rps) == rate.Inf {
+		return 0
+	}
+	return time.Duration(float64(burst) / rps * float64(time.Second))
+}
+
+// refillTime é o tempo até o bucket voltar a ficar cheio.
+func (l tokenBucket) refillTime(now time.Time) time.Duration {
+	missing := float64(l.Burst()) - l.TokensAt(now)
+	if missing <= 0 || l.Limit() <= 0 || l.Limit() == rate.Inf {
+		return 0
+	}
+	return time.Duration(missing / float64(l.Limit()) * float64(time.Second))
+}
+
+// BlockedFor implementa domain.BlockStore (lockout em memória).
+func (s *Store) BlockedFor(key domain.Key) time.Duration {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.blocked[string(key)]
+	if !ok {
+		return 0
+	}
+	if !until.After(now) {
+		delete(s.blocked, string(key))
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// Block implementa domain.BlockStore. Um bloqueio ainda ativo não é estendido.
+func (s *Store) Block(key domain.Key, d time.Duration) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if until, ok := s.blocked[string(key)]; ok && until.After(now) {
+		return
+	}
+	s.blocked[string(key)] = now.Add(d)
+}
+
 func (s *Store) Cleanup() {
-	cutoff := time.Now().Add(-s.idleTTL)
+	now := time.Now()
+	cutoff := now.Add(-s.idleTTL)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -85,6 +215,16 @@ func (s *Store) Cleanup() {
 			delete(s.entries, k)
 		}
 	}
+	for k, until := range s.blocked {
+		if !until.After(now) {
+			delete(s.blocked, k)
+		}
+	}
+	for k, until := range s.exempt {
+		if !until.After(now) {
+			delete(s.exempt, k)
+		}
+	}
 }
 
 // StartJanitor inicia uma goroutine que limpa chaves inativas periodicamente.
@@ -0,0 +1,413 @@
+package infra
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Scripts Lua dos algoritmos suportados pelo RedisStore. Todos são atômicos e
+// usam o relógio do próprio Redis (TIME), para que várias réplicas do gateway
+// enxerguem o mesmo "agora" mesmo com clocks locais diferentes.
+//
+// Todos retornam {allowed (0/1), remaining, retry em ms, reset em ms}.
+
+// tokenBucketScript implementa o token bucket.
+//
+// Estado por chave (hash): tokens (float) e ts (microssegundos do último acesso).
+//
+// KEYS[1] = chave do bucket
+// ARGV[1] = rps, ARGV[2] = burst
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local st = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(st[1])
+local ts = tonumber(st[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts) / 1000000
+tokens = math.min(burst, tokens + elapsed * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', string.format('%.0f', now))
+-- expira quando o bucket estaria cheio de novo (estado equivalente a "sem chave")
+redis.call('PEXPIRE', key, math.ceil(burst / rate * 1000) + 1000)
+
+local reset = math.ceil((burst - tokens) / rate * 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// gcraScript implementa o GCRA: guarda só o TAT (theoretical arrival time,
+// em microssegundos) por chave.
+//
+// KEYS[1] = chave
+// ARGV[1] = rps, ARGV[2] = burst
+var gcraScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local interval = 1000000 / rate
+local tat = tonumber(redis.call('GET', key))
+if tat == nil or tat < now then
+  tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+if now < allow_at then
+  return {0, 0, math.ceil((allow_at - now) / 1000), math.ceil((tat - now) / 1000)}
+end
+
+local reset = math.ceil((new_tat - now) / 1000)
+redis.call('SET', key, string.format('%.0f', new_tat), 'PX', reset + 1000)
+return {1, math.floor((now - allow_at) / interval), 0, reset}
+`)
+
+// fixedWindowScript implementa a janela fixa alinhada ao relógio (UTC).
+//
+// Estado por chave (hash): start (ms do início da janela) e count.
+// Quando aceita, retorna também o início da janela (para a devolução).
+//
+// KEYS[1] = chave
+// ARGV[1] = limite por janela, ARGV[2] = janela em ms
+var fixedWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local start = now - (now % window)
+local reset = start + window - now
+
+local st = redis.call('HMGET', key, 'start', 'count')
+local count = 0
+if tonumber(st[1]) == start then
+  count = tonumber(st[2]) or 0
+end
+
+if count >= limit then
+  return {0, 0, reset, reset}
+end
+
+count = count + 1
+redis.call('HSET', key, 'start', string.format('%.0f', start), 'count', count)
+redis.call('PEXPIRE', key, reset + 1000)
+return {1, limit - count, 0, reset, start}
+`)
+
+// refundScript devolve uma requisição já aceita (domain.ReservingLimiter),
+// quando outro limite da mesma requisição negou.
+//
+// KEYS[1] = chave
+// ARGV[1] = algoritmo, ARGV[2] = rps|limite, ARGV[3] = burst|janela em ms,
+// ARGV[4] = início da janela em ms (fixed_window)
+var refundScript = redis.NewScript(`
+local key = KEYS[1]
+local alg = ARGV[1]
+
+if alg == 'token_bucket' then
+  local tokens = tonumber(redis.call('HGET', key, 'tokens'))
+  if tokens ~= nil then
+    redis.call('HSET', key, 'tokens', tostring(math.min(tonumber(ARGV[3]), tokens + 1)))
+  end
+elseif alg == 'gcra' then
+  local tat = tonumber(redis.call('GET', key))
+  if tat ~= nil then
+    local ttl = redis.call('PTTL', key)
+    redis.call('SET', key, string.format('%.0f', tat - 1000000 / tonumber(ARGV[2])), 'PX', math.max(ttl, 1))
+  end
+elseif alg == 'fixed_window' then
+  local st = redis.call('HMGET', key, 'start', 'count')
+  if st[1] == ARGV[4] and tonumber(st[2]) ~= nil and tonumber(st[2]) > 0 then
+    redis.call('HINCRBY', key, 'count', -1)
+  end
+end
+return 1
+`)
+
+// RedisStore é uma implementação de domain.LimiterStore com o estado no Redis
+// (token bucket por padrão; GCRA e janela fixa via WithRedisAlgorithm).
+// Todas as réplicas que apontam para o mesmo Redis/prefixo compartilham os
+// mesmos buckets.
+//
+// Como a chave vem do cliente, cada tipo de estado tem um segmento próprio
+// depois do prefixo (<prefix>:b:<algoritmo>:<chave>, <prefix>:blk:<chave>,
+// <prefix>:ex:<chave>): nenhuma chave de cliente alcança o bloqueio ou a
+// isenção de outra.
+type RedisStore struct {
+	rdb *redis.Client
+
+	prefix    string
+	timeout   time.Duration
+	algorithm Algorithm
+	window    time.Duration
+
+	mu    sync.RWMutex
+	rps   float64
+	burst int
+
+	// failOpen define a decisão quando o Redis falha (true = deixa passar).
+	failOpen bool
+	onError  func(error)
+}
+
+type RedisStoreOption func(*RedisStore)
+
+func WithRedisKeyPrefix(prefix string) RedisStoreOption {
+	return func(s *RedisStore) { s.prefix = strings.Trim(prefix, ":") }
+}
+
+// WithRedisTimeout limita o tempo de cada chamada ao Redis (padrão 100ms).
+func WithRedisTimeout(d time.Duration) RedisStoreOption {
+	return func(s *RedisStore) { s.timeout = d }
+}
+
+// WithRedisFailOpen define se a requisição passa (true, padrão) ou é bloqueada
+// quando o Redis está indisponível.
+func WithRedisFailOpen(failOpen bool) RedisStoreOption {
+	return func(s *RedisStore) { s.failOpen = failOpen }
+}
+
+// WithRedisOnError registra um callback para erros do Redis (ex: log).
+func WithRedisOnError(fn func(error)) RedisStoreOption {
+	return func(s *RedisStore) { s.onError = fn }
+}
+
+// WithRedisAlgorithm escolhe o algoritmo: AlgorithmTokenBucket (padrão),
+// AlgorithmGCRA ou AlgorithmFixedWindow.
+func WithRedisAlgorithm(a Algorithm) RedisStoreOption {
+	return func(s *RedisStore) { s.algorithm = a }
+}
+
+// WithRedisWindow define a janela de AlgorithmFixedWindow (padrão 1s); o limite
+// por janela é rps × janela.
+func WithRedisWindow(d time.Duration) RedisStoreOption {
+	return func(s *RedisStore) { s.window = d }
+}
+
+func NewRedisStore(rdb *redis.Client, rps float64, burst int, opts ...RedisStoreOption) *RedisStore {
+	s := &RedisStore{
+		rdb:       rdb,
+		prefix:    "ratelimit:bucket",
+		rps:       rps,
+		burst:     burst,
+		timeout:   100 * time.Millisecond,
+		algorithm: AlgorithmTokenBucket,
+		window:    time.Second,
+		failOpen:  true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *RedisStore) Algorithm() Algorithm { return s.algorithm }
+
+func (s *RedisStore) RPS() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rps
+}
+
+func (s *RedisStore) Burst() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.burst
+}
+
+// SetLimits troca o limite padrão (ex: hot reload). O estado no Redis é
+// mantido: o script aplica a nova taxa sobre os tokens já acumulados.
+func (s *RedisStore) SetLimits(rps float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rps = rps
+	s.burst = burst
+}
+
+// Get implementa domain.LimiterStore.
+//
+// O limiter retornado é apenas um "handle": o estado fica no Redis.
+func (s *RedisStore) Get(key domain.Key) domain.Limiter {
+	return &redisLimiter{store: s, key: s.bucketKey(key), rps: s.RPS(), burst: s.Burst()}
+}
+
+// GetWithPolicy implementa domain.PolicyLimiterStore.
+func (s *RedisStore) GetWithPolicy(key domain.Key, p domain.Policy) domain.Limiter {
+	return &redisLimiter{store: s, key: s.bucketKey(key), rps: p.RPS, burst: p.Burst}
+}
+
+// BlockedFor implementa domain.BlockStore: o bloqueio é uma chave com TTL,
+// visível para todas as réplicas.
+func (s *RedisStore) BlockedFor(key domain.Key) time.Duration {
+	if s == nil || s.rdb == nil {
+		return 0
+	}
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	ttl, err := s.rdb.PTTL(ctx, s.blockKey(key)).Result()
+	if err != nil {
+		// sem Redis não há como saber; quem decide (fail-open/closed) é o limiter
+		s.reportError(err)
+		return 0
+	}
+	if ttl < 0 {
+		// -2: não existe; -1: sem TTL (não deveria acontecer)
+		return 0
+	}
+	return ttl
+}
+
+// Block implementa domain.BlockStore. Usa SET NX para não estender um bloqueio ativo.
+func (s *RedisStore) Block(key domain.Key, d time.Duration) {
+	if s == nil || s.rdb == nil || d <= 0 {
+		return
+	}
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	if err := s.rdb.SetNX(ctx, s.blockKey(key), 1, d).Err(); err != nil {
+		s.reportError(err)
+	}
+}
+
+// bucketKey é a chave do estado no Redis. Cada algoritmo guarda um formato
+// diferente, então o algoritmo também entra na chave.
+func (s *RedisStore) bucketKey(key domain.Key) string {
+	return s.prefix + ":b:" + string(s.algorithm) + ":" + string(key)
+}
+
+func (s *RedisStore) blockKey(key domain.Key) string {
+	return s.prefix + ":blk:" + string(key)
+}
+
+func (s *RedisStore) callContext() (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(context.Background(), s.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func (s *RedisStore) reportError(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
+
+type redisLimiter struct {
+	store *RedisStore
+	key   string
+	rps   float64
+	burst int
+}
+
+func (l *redisLimiter) Allow() bool { return l.Take().Allowed }
+
+// Take implementa domain.ReportingLimiter.
+func (l *redisLimiter) Take() domain.LimitResult {
+	res, _ := l.Reserve()
+	return res
+}
+
+// Reserve implementa domain.ReservingLimiter. A devolução é feita por outro
+// script; se o Redis falhar nela, a cota fica consumida (best-effort).
+func (l *redisLimiter) Reserve() (domain.LimitResult, func()) {
+	res, start := l.take()
+	if !res.Allowed || l.store == nil || l.store.rdb == nil {
+		return res, nil
+	}
+	return res, func() { l.refund(start) }
+}
+
+func (l *redisLimiter) refund(windowStart int64) {
+	s := l.store
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	var args []any
+	switch s.algorithm {
+	case AlgorithmFixedWindow:
+		args = []any{string(s.algorithm), 0, s.window.Milliseconds(), windowStart}
+	default:
+		args = []any{string(s.algorithm), l.rps, l.burst, 0}
+	}
+	if err := refundScript.Run(ctx, s.rdb, []string{l.key}, args...).Err(); err != nil {
+		s.reportError(err)
+	}
+}
+
+// take roda o script do algoritmo. start é o início da janela (ms) no
+// fixed_window, necessário para a devolução.
+func (l *redisLimiter) take() (domain.LimitResult, int64) {
+	s := l.store
+	if s == nil || s.rdb == nil {
+		return domain.LimitResult{Allowed: true, Limit: l.burst, Remaining: l.burst}, 0
+	}
+
+	script, args, limit, window := s.scriptFor(l.rps, l.burst)
+
+	ctx, cancel := s.callContext()
+	defer cancel()
+
+	res, err := script.Run(ctx, s.rdb, []string{l.key}, args...).Int64Slice()
+	if err == nil && len(res) < 4 {
+		err = redis.Nil
+	}
+	if err != nil {
+		s.reportError(err)
+		return domain.LimitResult{Allowed: s.failOpen, Limit: limit, Window: window}, 0
+	}
+	var start int64
+	if len(res) > 4 {
+		start = res[4]
+	}
+	return domain.LimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+		Window:     window,
+	}, start
+}
+
+// scriptFor retorna o script do algoritmo, seus argumentos e o limite/janela reportados.
+func (s *RedisStore) scriptFor(rps float64, burst int) (*redis.Script, []any, int, time.Duration) {
+	switch s.algorithm {
+	case AlgorithmGCRA:
+		return gcraScript, []any{rps, burst}, burst, refillWindow(rps, burst)
+	case AlgorithmFixedWindow:
+		limit := WindowLimit(rps, s.window)
+		return fixedWindowScript, []any{limit, s.window.Milliseconds()}, limit, s.window
+	default:
+		return tokenBucketScript, []any{rps, burst}, burst, refillWindow(rps, burst)
+	}
+}
@@ -0,0 +1,207 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestRedisStore_BurstThenReject(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	s := NewRedisStore(rdb, 1, 2)
+
+	lim := s.Get(domain.Key("k"))
+	if !lim.Allow() || !lim.Allow() {
+		t.Fatalf("expected burst of 2 to be allowed")
+	}
+	if lim.Allow() {
+		t.Fatalf("expected third immediate Allow to be false")
+	}
+
+	// 1s depois, 1 token foi reposto
+	mr.SetTime(time.Unix(1_700_000_001, 0))
+	if !lim.Allow() {
+		t.Fatalf("expected Allow after refill")
+	}
+	if lim.Allow() {
+		t.Fatalf("expected Allow to be false again")
+	}
+}
+
+func TestRedisStore_SharedAcrossInstances(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	// duas "réplicas" apontando para o mesmo Redis
+	a := NewRedisStore(rdb, 0.02, 1)
+	b := NewRedisStore(rdb, 0.02, 1)
+
+	if !a.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected first Allow on replica A")
+	}
+	if b.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected replica B to see the bucket consumed by A")
+	}
+	if !b.Get(domain.Key("other")).Allow() {
+		t.Fatalf("expected different key to have its own bucket")
+	}
+}
+
+func TestRedisStore_FailOpenAndClosed(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.Close()
+
+	var errs int
+	open := NewRedisStore(rdb, 1, 1, WithRedisOnError(func(error) { errs++ }))
+	if !open.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected fail-open to allow when redis is down")
+	}
+	if errs != 1 {
+		t.Fatalf("expected error callback once, got %d", errs)
+	}
+
+	closed := NewRedisStore(rdb, 1, 1, WithRedisFailOpen(false))
+	if closed.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected fail-closed to deny when redis is down")
+	}
+}
+
+func TestRedisStore_BlockSharedWithTTL(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+
+	a := NewRedisStore(rdb, 1, 1)
+	b := NewRedisStore(rdb, 1, 1)
+
+	a.Block(domain.Key("k"), 5*time.Minute)
+	b.Block(domain.Key("k"), time.Hour) // não estende bloqueio ativo
+
+	if got := b.BlockedFor(domain.Key("k")); got != 5*time.Minute {
+		t.Fatalf("expected replica B to see 5m block, got %s", got)
+	}
+
+	mr.FastForward(5 * time.Minute)
+	if got := a.BlockedFor(domain.Key("k")); got != 0 {
+		t.Fatalf("expected block to expire, got %s", got)
+	}
+}
+
+func TestRedisStore_ClientKeysCannotReachBlocks(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	s := NewRedisStore(rdb, 1, 1)
+
+	// chaves de cliente que imitam os segmentos de bloqueio e isenção
+	for _, k := range []string{"blk:1.2.3.4", "block:1.2.3.4", "ex:1.2.3.4", "b:token_bucket:1.2.3.4"} {
+		s.Get(domain.Key(k)).Allow()
+		s.Block(domain.Key(k), time.Hour)
+	}
+	if got := s.BlockedFor(domain.Key("1.2.3.4")); got != 0 {
+		t.Fatalf("expected the victim not to be blocked, got %s", got)
+	}
+	s.Block(domain.Key("1.2.3.4"), time.Minute)
+	if got := s.BlockedFor(domain.Key("1.2.3.4")); got != time.Minute {
+		t.Fatalf("expected the real block to be set, got %s", got)
+	}
+	if s.ExemptFor(domain.Key("1.2.3.4")) != 0 || !s.Get(domain.Key("1.2.3.4")).Allow() {
+		t.Fatalf("expected the victim's bucket and exemption untouched")
+	}
+}
+
+func TestRedisStore_TokenBucketReportsRemaining(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	lim := NewRedisStore(rdb, 1, 2).Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 || res.Limit != 2 || res.Reset != time.Second {
+		t.Fatalf("unexpected first result: %+v", res)
+	}
+	lim.Take()
+	if res := lim.Take(); res.Allowed || res.RetryAfter != time.Second || res.Reset != 2*time.Second {
+		t.Fatalf("expected deny with retry=1s reset=2s, got %+v", res)
+	}
+}
+
+func TestRedisStore_GCRA(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+
+	s := NewRedisStore(rdb, 1, 2, WithRedisAlgorithm(AlgorithmGCRA))
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("unexpected first result: %+v", res)
+	}
+	if res := lim.Take(); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("unexpected second result: %+v", res)
+	}
+	if res := lim.Take(); res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("expected deny with retry=1s, got %+v", res)
+	}
+
+	// outra réplica enxerga o mesmo estado
+	other := NewRedisStore(rdb, 1, 2, WithRedisAlgorithm(AlgorithmGCRA))
+	mr.SetTime(time.Unix(1_700_000_001, 0))
+	if !other.Get(domain.Key("k")).Allow() || other.Get(domain.Key("k")).Allow() {
+		t.Fatalf("expected exactly one request after 1s across replicas")
+	}
+}
+
+func TestRedisStore_FixedWindow(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(start.Add(40 * time.Second))
+
+	s := NewRedisStore(rdb, 2.0/60, 0, WithRedisAlgorithm(AlgorithmFixedWindow), WithRedisWindow(time.Minute))
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 || res.Limit != 2 || res.Reset != 20*time.Second {
+		t.Fatalf("unexpected first result: %+v", res)
+	}
+	lim.Take()
+	if res := lim.Take(); res.Allowed || res.RetryAfter != 20*time.Second {
+		t.Fatalf("expected deny until the end of the window, got %+v", res)
+	}
+
+	mr.SetTime(start.Add(time.Minute))
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected a fresh window, got %+v", res)
+	}
+}
+
+func TestRedisStore_ReserveCancelRefunds(t *testing.T) {
+	for _, alg := range []Algorithm{AlgorithmTokenBucket, AlgorithmGCRA, AlgorithmFixedWindow} {
+		t.Run(string(alg), func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			mr.SetTime(time.Unix(1_700_000_000, 0))
+
+			s := NewRedisStore(rdb, 1.0/60, 1, WithRedisAlgorithm(alg), WithRedisWindow(time.Minute))
+			lim := s.Get(domain.Key("k")).(domain.ReservingLimiter)
+
+			res, cancel := lim.Reserve()
+			if !res.Allowed || cancel == nil {
+				t.Fatalf("expected allowed reservation with cancel, got %+v", res)
+			}
+			cancel()
+			if !lim.Allow() {
+				t.Fatalf("expected refunded quota to be available")
+			}
+			if lim.Allow() {
+				t.Fatalf("expected limit enforced after refund was used")
+			}
+		})
+	}
+}
@@ -42,3 +42,99 @@ func TestStore_CleanupRemovesIdleEntries(t *testing.T) {
 		t.Fatalf("expected limiter to be recreated after cleanup")
 	}
 }
+
+func TestStore_GetWithPolicyUsesPolicyLimits(t *testing.T) {
+	s := NewStore(0.02, 1)
+
+	lim := s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 0.02, Burst: 3})
+	for i := 0; i < 3; i++ {
+		if !lim.Allow() {
+			t.Fatalf("expected Allow %d to be true (burst=3)", i+1)
+		}
+	}
+	if lim.Allow() {
+		t.Fatalf("expected fourth Allow to be false")
+	}
+
+	same := s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 0.02, Burst: 3})
+	if same != lim {
+		t.Fatalf("expected same limiter while policy is unchanged")
+	}
+	changed := s.GetWithPolicy(domain.Key("tok"), domain.Policy{Name: "tok", RPS: 0.02, Burst: 5})
+	if changed == lim {
+		t.Fatalf("expected limiter to be rebuilt when policy changes")
+	}
+}
+
+func TestStore_BlockKeepsActiveBlockAndExpires(t *testing.T) {
+	s := NewStore(10, 1)
+
+	if got := s.BlockedFor(domain.Key("k")); got != 0 {
+		t.Fatalf("expected key not blocked, got %s", got)
+	}
+
+	s.Block(domain.Key("k"), 20*time.Millisecond)
+	s.Block(domain.Key("k"), time.Hour) // não estende bloqueio ativo
+	left := s.BlockedFor(domain.Key("k"))
+	if left <= 0 || left > 20*time.Millisecond {
+		t.Fatalf("expected remaining block in (0, 20ms], got %s", left)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if got := s.BlockedFor(domain.Key("k")); got != 0 {
+		t.Fatalf("expected block to expire, got %s", got)
+	}
+}
+
+func TestStore_SetLimitsRebuildsOnlyChangedLimiters(t *testing.T) {
+	s := NewStore(10, 1)
+
+	def := s.Get(domain.Key("ip"))
+	tok := s.GetWithPolicy(domain.Key("tok"), domain.Policy{RPS: 100, Burst: 5})
+
+	s.SetLimits(20, 2)
+	if s.RPS() != 20 || s.Burst() != 2 {
+		t.Fatalf("expected new defaults 20/2, got %v/%d", s.RPS(), s.Burst())
+	}
+	if s.Get(domain.Key("ip")) == def {
+		t.Fatalf("expected default limiter to be rebuilt after rate change")
+	}
+	if s.GetWithPolicy(domain.Key("tok"), domain.Policy{RPS: 100, Burst: 5}) != tok {
+		t.Fatalf("expected unchanged policy to keep its limiter state")
+	}
+}
+
+func TestStore_TakeReportsRemainingAndRetry(t *testing.T) {
+	s := NewStore(1, 2)
+	lim := s.Get(domain.Key("k")).(domain.ReportingLimiter)
+
+	if res := lim.Take(); !res.Allowed || res.Remaining != 1 || res.Limit != 2 {
+		t.Fatalf("unexpected first result: %+v", res)
+	}
+	lim.Take()
+	res := lim.Take()
+	if res.Allowed || res.RetryAfter <= 900*time.Millisecond || res.RetryAfter > time.Second {
+		t.Fatalf("expected deny with ~1s retry, got %+v", res)
+	}
+	// a negação não consome token: o bucket vazio leva ~2s para encher de novo
+	if res.Reset <= time.Second || res.Reset > 2*time.Second {
+		t.Fatalf("expected reset until bucket is full (~2s), got %s", res.Reset)
+	}
+}
+
+func TestStore_ReserveCancelRefundsToken(t *testing.T) {
+	s := NewStore(0.001, 1)
+	lim := s.Get(domain.Key("k")).(domain.ReservingLimiter)
+
+	res, cancel := lim.Reserve()
+	if !res.Allowed || cancel == nil {
+		t.Fatalf("expected allowed reservation with cancel, got %+v", res)
+	}
+	cancel()
+	if !lim.Allow() {
+		t.Fatalf("expected refunded token to be available")
+	}
+	if _, cancel := lim.Reserve(); cancel != nil {
+		t.Fatalf("expected no cancel when denied")
+	}
+}
@@ -0,0 +1,177 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// TokenPolicies é uma tabela estática token -> política.
+// Implementa domain.PolicyResolver.
+type TokenPolicies struct {
+	policies map[domain.Key]domain.Policy
+}
+
+// NewTokenPolicies cria a tabela. Políticas sem Name recebem "token".
+func NewTokenPolicies(policies map[string]domain.Policy) *TokenPolicies {
+	t := &TokenPolicies{policies: make(map[domain.Key]domain.Policy, len(policies))}
+	for tok, p := range policies {
+		if p.Name == "" {
+			p.Name = "token"
+		}
+		t.policies[domain.Key(tok)] = p
+	}
+	return t
+}
+
+// Resolve implementa domain.PolicyResolver.
+func (t *TokenPolicies) Resolve(key domain.Key) (domain.Policy, bool) {
+	if t == nil {
+		return domain.Policy{}, false
+	}
+	// os tokens chegam do header marcados (domain.HeaderKeyPrefix)
+	p, ok := t.policies[domain.Key(strings.TrimPrefix(string(key), domain.HeaderKeyPrefix))]
+	return p, ok
+}
+
+// HasBlock informa se algum token tem tempo de bloqueio (lockout).
+func (t *TokenPolicies) HasBlock() bool {
+	if t == nil {
+		return false
+	}
+	for _, p := range t.policies {
+		if p.BlockDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TokenPolicies) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.policies)
+}
+
+// ParseTokenPolicies lê a tabela no formato compacto usado em variável de ambiente:
+//
+//	token=rps[:burst[:bloqueio]],token2=rps[:burst[:bloqueio]]
+//
+// Ex: "abc123=100:200:5m,xyz=5". Se o burst for omitido, usa max(1, rps);
+// o bloqueio (time.ParseDuration) é opcional.
+func ParseTokenPolicies(spec string) (map[string]domain.Policy, error) {
+	out := make(map[string]domain.Policy)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		tok, limits, ok := strings.Cut(item, "=")
+		tok = strings.TrimSpace(tok)
+		if !ok || tok == "" {
+			return nil, fmt.Errorf("token policy %q: expected token=rps[:burst[:block]]", redact(item))
+		}
+
+		parts := strings.Split(strings.TrimSpace(limits), ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("token policy %q: expected token=rps[:burst[:block]]", redact(tok))
+		}
+		rps, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("token policy %q: invalid rps: %w", redact(tok), err)
+		}
+		burst := defaultBurst(rps)
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+			burst, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return nil, fmt.Errorf("token policy %q: invalid burst: %w", redact(tok), err)
+			}
+		}
+		var block time.Duration
+		if len(parts) > 2 {
+			block, err = time.ParseDuration(strings.TrimSpace(parts[2]))
+			if err != nil {
+				return nil, fmt.Errorf("token policy %q: invalid block duration: %w", redact(tok), err)
+			}
+		}
+
+		p := domain.Policy{Name: "token", RPS: rps, Burst: burst, BlockDuration: block}
+		if err := validatePolicy(p); err != nil {
+			return nil, fmt.Errorf("token policy %q: %w", redact(tok), err)
+		}
+		out[tok] = p
+	}
+	return out, nil
+}
+
+type tokenPolicyFileEntry struct {
+	Name  string  `json:"name"`
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+	Block string  `json:"block"`
+}
+
+// LoadTokenPoliciesFile lê a tabela de um arquivo JSON:
+//
+//	{"abc123": {"rps": 100, "burst": 200, "block": "5m"}, "xyz": {"name": "free", "rps": 5}}
+func LoadTokenPoliciesFile(path string) (map[string]domain.Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]tokenPolicyFileEntry
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	out := make(map[string]domain.Policy, len(raw))
+	for tok, e := range raw {
+		p := domain.Policy{Name: e.Name, RPS: e.RPS, Burst: e.Burst}
+		if e.Block != "" {
+			d, err := time.ParseDuration(e.Block)
+			if err != nil {
+				return nil, fmt.Errorf("%s: token %q: invalid block duration: %w", path, redact(tok), err)
+			}
+			p.BlockDuration = d
+		}
+		if p.Name == "" {
+			p.Name = "token"
+		}
+		if p.Burst == 0 {
+			p.Burst = defaultBurst(p.RPS)
+		}
+		if err := validatePolicy(p); err != nil {
+			return nil, fmt.Errorf("%s: token %q: %w", path, redact(tok), err)
+		}
+		out[tok] = p
+	}
+	return out, nil
+}
+
+func defaultBurst(rps float64) int {
+	if rps < 1 {
+		return 1
+	}
+	return int(rps)
+}
+
+func validatePolicy(p domain.Policy) error {
+	if p.RPS <= 0 {
+		return fmt.Errorf("rps must be > 0")
+	}
+	if p.Burst <= 0 {
+		return fmt.Errorf("burst must be > 0")
+	}
+	if p.BlockDuration < 0 {
+		return fmt.Errorf("block duration must be >= 0")
+	}
+	return nil
+}
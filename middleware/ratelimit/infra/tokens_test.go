@@ -0,0 +1,62 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+func TestParseTokenPolicies(t *testing.T) {
+	got, err := ParseTokenPolicies(" abc123=100:200, xyz=5 ,slow=1:1:5m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := got["abc123"]; p.RPS != 100 || p.Burst != 200 {
+		t.Fatalf("unexpected abc123 policy: %+v", p)
+	}
+	if p := got["xyz"]; p.RPS != 5 || p.Burst != 5 {
+		t.Fatalf("expected xyz burst to default to rps, got %+v", p)
+	}
+
+	if p := got["slow"]; p.BlockDuration != 5*time.Minute {
+		t.Fatalf("expected slow block of 5m, got %+v", p)
+	}
+
+	for _, bad := range []string{"abc", "abc=x", "abc=1:y", "abc=0", "=1", "abc=1:1:zz", "abc=1:1:1m:2"} {
+		if _, err := ParseTokenPolicies(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+
+	if _, err := ParseTokenPolicies("s3cr3t-token=x"); err == nil || strings.Contains(err.Error(), "s3cr3t-token") {
+		t.Fatalf("expected the token to be redacted from the error, got %v", err)
+	}
+}
+
+func TestLoadTokenPoliciesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	data := `{"abc123": {"rps": 100, "burst": 200, "block": "5m"}, "free": {"name": "free", "rps": 0.5}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadTokenPoliciesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tp := NewTokenPolicies(got)
+	if p, ok := tp.Resolve(domain.Key("abc123")); !ok || p.Name != "token" || p.Burst != 200 || p.BlockDuration != 5*time.Minute {
+		t.Fatalf("unexpected abc123 policy: %+v ok=%v", p, ok)
+	}
+	if p, ok := tp.Resolve(domain.Key("free")); !ok || p.Name != "free" || p.Burst != 1 {
+		t.Fatalf("unexpected free policy: %+v ok=%v", p, ok)
+	}
+	if _, ok := tp.Resolve(domain.Key("10.0.0.1")); ok {
+		t.Fatalf("expected unknown key to have no policy")
+	}
+}
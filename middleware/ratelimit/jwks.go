@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// JWKS é um conjunto local de chaves de verificação (RFC 7517), lido de
+// arquivo: nenhuma chave é buscada pela rede.
+type JWKS struct {
+	keys map[string]jwk
+}
+
+type jwk struct {
+	alg string
+	key any
+}
+
+// LoadJWKS lê um arquivo JWKS ({"keys": [...]}).
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return set, nil
+}
+
+// ParseJWKS interpreta um JWKS com chaves RSA, EC (P-256/384/521), OKP
+// (Ed25519) ou oct (HMAC). Chaves com "use" diferente de "sig" são ignoradas.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var raw struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	set := &JWKS{keys: make(map[string]jwk, len(raw.Keys))}
+	for i, k := range raw.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key any
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k.N, k.E)
+		case "EC":
+			key, err = ecKey(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = okpKey(k.Crv, k.X)
+		case "oct":
+			key, err = b64(k.K)
+		default:
+			err = fmt.Errorf("unsupported kty %q", k.Kty)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, k.Kid, err)
+		}
+		if _, dup := set.keys[k.Kid]; dup {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, k.Kid)
+		}
+		set.keys[k.Kid] = jwk{alg: k.Alg, key: key}
+	}
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no signing keys")
+	}
+	return set, nil
+}
+
+func (s *JWKS) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.keys)
+}
+
+// lookup escolhe a chave pelo kid; sem kid, só vale se houver uma chave.
+func (s *JWKS) lookup(kid, alg string) (any, error) {
+	k, ok := s.keys[kid]
+	if !ok && kid == "" && len(s.keys) == 1 {
+		for _, only := range s.keys {
+			k, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("kid %q does not allow alg %s", kid, alg)
+	}
+	return k.key, nil
+}
+
+func b64(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url value")
+	}
+	return b, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := b64(n)
+	if err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	eb, err := b64(e)
+	if err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("e: invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported crv %q", crv)
+	}
+	xb, err := b64(x)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	yb, err := b64(y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) != size || len(yb) != size {
+		return nil, fmt.Errorf("invalid coordinate size for %s", crv)
+	}
+	key, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, xb...), yb...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s point: %w", crv, err)
+	}
+	return key, nil
+}
+
+func okpKey(crv, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported crv %q", crv)
+	}
+	xb, err := b64(x)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("x: invalid Ed25519 key size")
+	}
+	return ed25519.PublicKey(xb), nil
+}
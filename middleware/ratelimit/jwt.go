@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTOptions configura o JWTIdentifier.
+//
+// A verificação é local: Secret (HMAC, HS256/384/512) ou JWKS (chaves públicas
+// lidas de arquivo). Requisições sem token caem no KeyFn padrão (IP); tokens
+// inválidos (assinatura, exp ausente ou vencido, nbf, iss/aud ou sem a claim) também, a menos que
+// RejectInvalid esteja ligado, quando são recusados com 401.
+type JWTOptions struct {
+	// Header de onde vem o token (padrão Authorization, com prefixo "Bearer ").
+	Header string
+	// Claim usada como chave do limite (padrão "sub"), ex: "tenant_id".
+	Claim string
+	// PlanClaim (opcional) é a claim do plano (ex: "plan"), mapeado para uma
+	// política em Tiers. Planos fora de Tiers usam a política padrão.
+	PlanClaim string
+	Tiers     map[string]domain.Policy
+
+	Secret []byte
+	JWKS   *JWKS
+
+	// Issuer e Audience (opcionais) são exigidos em iss/aud.
+	Issuer   string
+	Audience string
+	// Leeway é a tolerância de relógio para exp/nbf/iat.
+	Leeway time.Duration
+
+	RejectInvalid bool
+}
+
+// JWTIdentifier é um Identifier que valida um JWT e usa uma claim como chave
+// ("<claim>:<valor>", ex: "sub:alice").
+type JWTIdentifier struct {
+	header, prefix string
+	claim          string
+	planClaim      string
+	tiers          map[string]domain.Policy
+	reject         bool
+
+	parser *jwt.Parser
+	keyFn  jwt.Keyfunc
+}
+
+var (
+	hmacMethods = []string{"HS256", "HS384", "HS512"}
+	jwksMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+func NewJWTIdentifier(opts JWTOptions) (*JWTIdentifier, error) {
+	j := &JWTIdentifier{
+		header:    opts.Header,
+		claim:     opts.Claim,
+		planClaim: opts.PlanClaim,
+		reject:    opts.RejectInvalid,
+		tiers:     make(map[string]domain.Policy, len(opts.Tiers)),
+	}
+	if j.header == "" || strings.EqualFold(j.header, "Authorization") {
+		j.header, j.prefix = "Authorization", "bearer "
+	}
+	if j.claim == "" {
+		j.claim = "sub"
+	}
+	for plan, p := range opts.Tiers {
+		if p.Name == "" {
+			p.Name = plan
+		}
+		j.tiers[plan] = p
+	}
+
+	var methods []string
+	switch {
+	case len(opts.Secret) > 0 && opts.JWKS != nil:
+		return nil, errors.New("jwt: use either a secret or a JWKS, not both")
+	case len(opts.Secret) > 0:
+		secret := opts.Secret
+		methods = hmacMethods
+		j.keyFn = func(*jwt.Token) (any, error) { return secret, nil }
+	case opts.JWKS.Len() > 0:
+		set := opts.JWKS
+		methods = slices.Concat(jwksMethods, hmacMethods)
+		j.keyFn = func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			return set.lookup(kid, t.Method.Alg())
+		}
+	default:
+		return nil, errors.New("jwt: a secret or a JWKS is required")
+	}
+
+	// sem exp, um token vazado valeria para sempre
+	popts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithJSONNumber(), jwt.WithLeeway(opts.Leeway), jwt.WithExpirationRequired()}
+	if opts.Issuer != "" {
+		popts = append(popts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		popts = append(popts, jwt.WithAudience(opts.Audience))
+	}
+	j.parser = jwt.NewParser(popts...)
+	return j, nil
+}
+
+// Identify implementa Identifier.
+func (j *JWTIdentifier) Identify(r *http.Request) (Identity, error) {
+	raw := strings.TrimSpace(r.Header.Get(j.header))
+	if j.prefix != "" {
+		if len(raw) < len(j.prefix) || !strings.EqualFold(raw[:len(j.prefix)], j.prefix) {
+			return Identity{}, nil
+		}
+		raw = strings.TrimSpace(raw[len(j.prefix):])
+	}
+	if raw == "" {
+		return Identity{}, nil
+	}
+
+	id, err := j.identify(raw)
+	if err != nil {
+		if !j.reject {
+			return Identity{}, nil
+		}
+		return Identity{}, &AuthError{Challenge: `Bearer error="invalid_token"`, Err: err}
+	}
+	return id, nil
+}
+
+func (j *JWTIdentifier) identify(raw string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(raw, claims, j.keyFn); err != nil {
+		return Identity{}, err
+	}
+	sub, ok := claimString(claims[j.claim])
+	if !ok {
+		return Identity{}, fmt.Errorf("token has no %q claim", j.claim)
+	}
+
+	id := Identity{Key: j.claim + ":" + sub}
+	if j.planClaim != "" {
+		if plan, ok := claimString(claims[j.planClaim]); ok {
+			if p, ok := j.tiers[plan]; ok {
+				id.Policy = &p
+			}
+		}
+	}
+	return id, nil
+}
+
+// claimString aceita claims string ou numéricas (ex: tenant_id: 42).
+func claimString(v any) (string, bool) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return "", false
+	}
+	s = strings.TrimSpace(s)
+	return s, s != ""
+}
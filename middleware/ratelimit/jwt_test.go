@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+	"middleware-gateway/middleware/ratelimit/infra"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("s3cr3t")
+
+// withExp completa claims com um exp futuro (o parser exige exp).
+func withExp(claims jwt.MapClaims) jwt.MapClaims {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	return claims
+}
+
+func signHS(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, withExp(claims)).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func bearer(r *http.Request, tok string) *http.Request {
+	r.Header.Set("Authorization", "Bearer "+tok)
+	return r
+}
+
+func TestJWTIdentifier_KeysOnClaimAndMapsPlan(t *testing.T) {
+	j, err := NewJWTIdentifier(JWTOptions{
+		Secret:    testSecret,
+		Claim:     "tenant_id",
+		PlanClaim: "plan",
+		Tiers:     map[string]domain.Policy{"pro": {RPS: 10, Burst: 20}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := signHS(t, jwt.MapClaims{"tenant_id": 42, "plan": "pro", "exp": time.Now().Add(time.Hour).Unix()})
+	id, err := j.Identify(bearer(httptest.NewRequest(http.MethodGet, "/", nil), tok))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id.Key != "tenant_id:42" {
+		t.Fatalf("expected key from numeric claim, got %q", id.Key)
+	}
+	if id.Policy == nil || id.Policy.Name != "pro" || id.Policy.Burst != 20 {
+		t.Fatalf("expected pro tier policy, got %+v", id.Policy)
+	}
+
+	free := signHS(t, jwt.MapClaims{"tenant_id": "t1", "plan": "free"})
+	if id, _ := j.Identify(bearer(httptest.NewRequest(http.MethodGet, "/", nil), free)); id.Policy != nil {
+		t.Fatalf("expected unmapped plan to use the default policy, got %+v", id.Policy)
+	}
+}
+
+func TestJWTIdentifier_InvalidTokens(t *testing.T) {
+	expired := signHS(t, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+	noExp, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString(testSecret)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, withExp(jwt.MapClaims{"sub": "alice"})).SignedString([]byte("other"))
+	noClaim := signHS(t, jwt.MapClaims{"name": "alice"})
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, withExp(jwt.MapClaims{"sub": "alice"})).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	lenient, _ := NewJWTIdentifier(JWTOptions{Secret: testSecret})
+	strict, _ := NewJWTIdentifier(JWTOptions{Secret: testSecret, RejectInvalid: true})
+
+	for name, tok := range map[string]string{"expired": expired, "no exp": noExp, "forged": forged, "no claim": noClaim, "alg none": unsigned, "garbage": "abc"} {
+		r := bearer(httptest.NewRequest(http.MethodGet, "/", nil), tok)
+		if id, err := lenient.Identify(r); err != nil || id.Key != "" {
+			t.Fatalf("%s: expected fallback to IP, got %+v %v", name, id, err)
+		}
+		if _, err := strict.Identify(r); err == nil || authChallenge(err) == "" {
+			t.Fatalf("%s: expected auth error with challenge, got %v", name, err)
+		}
+	}
+
+	// sem token não é inválido: cai no IP mesmo no modo estrito
+	if id, err := strict.Identify(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil || id.Key != "" {
+		t.Fatalf("expected anonymous request to fall back to IP, got %+v %v", id, err)
+	}
+}
+
+func b64url(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func TestJWTIdentifier_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPub, err := ecKey.PublicKey.Bytes() // 0x04 || X || Y
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "r1", "alg": "RS256", "n": b64url(rsaKey.N.Bytes()), "e": b64url(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "e1", "crv": "P-256", "x": b64url(ecPub[1:33]), "y": b64url(ecPub[33:])},
+	}})
+	jwks, err := ParseJWKS(set)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	j, err := NewJWTIdentifier(JWTOptions{JWKS: jwks, RejectInvalid: true, Issuer: "idp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(m jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(m, withExp(claims))
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	identify := func(tok string) (Identity, error) {
+		return j.Identify(bearer(httptest.NewRequest(http.MethodGet, "/", nil), tok))
+	}
+
+	if id, err := identify(sign(jwt.SigningMethodRS256, "r1", rsaKey, jwt.MapClaims{"sub": "a", "iss": "idp"})); err != nil || id.Key != "sub:a" {
+		t.Fatalf("expected RS256 token accepted, got %+v %v", id, err)
+	}
+	if id, err := identify(sign(jwt.SigningMethodES256, "e1", ecKey, jwt.MapClaims{"sub": "b", "iss": "idp"})); err != nil || id.Key != "sub:b" {
+		t.Fatalf("expected ES256 token accepted, got %+v %v", id, err)
+	}
+	if _, err := identify(sign(jwt.SigningMethodRS256, "r1", rsaKey, jwt.MapClaims{"sub": "a", "iss": "evil"})); err == nil {
+		t.Fatalf("expected wrong issuer to be rejected")
+	}
+	if _, err := identify(sign(jwt.SigningMethodRS256, "nope", rsaKey, jwt.MapClaims{"sub": "a", "iss": "idp"})); err == nil {
+		t.Fatalf("expected unknown kid to be rejected")
+	}
+	// confusão de algoritmo: HS256 "assinado" com a chave pública RSA
+	pub := rsaKey.N.Bytes()
+	if _, err := identify(sign(jwt.SigningMethodHS256, "r1", pub, jwt.MapClaims{"sub": "a", "iss": "idp"})); err == nil {
+		t.Fatalf("expected HS256 with an RSA kid to be rejected")
+	}
+}
+
+func TestMiddleware_IdentifierKeysTiersAndRejects(t *testing.T) {
+	j, err := NewJWTIdentifier(JWTOptions{
+		Secret:        testSecret,
+		PlanClaim:     "plan",
+		Tiers:         map[string]domain.Policy{"pro": {RPS: 0.02, Burst: 3}},
+		RejectInvalid: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(Options{
+		Store:      infra.NewStore(0.02, 1),
+		Identifier: j,
+		Headers:    HeadersLegacy,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(tok string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		if tok != "" {
+			bearer(r, tok)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	pro := signHS(t, jwt.MapClaims{"sub": "alice", "plan": "pro"})
+	for i := 0; i < 3; i++ {
+		if w := do(pro); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "3" {
+			t.Fatalf("request %d: expected 200 with the pro tier limit, got %d %q", i+1, w.Code, w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+	if w := do(pro); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the pro burst, got %d", w.Code)
+	}
+	// outro sub tem bucket próprio; sem token, vale o IP
+	if w := do(signHS(t, jwt.MapClaims{"sub": "bob"})); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for another subject, got %d", w.Code)
+	}
+	if w := do(""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the first anonymous request, got %d", w.Code)
+	}
+
+	w := do("not-a-jwt")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 with WWW-Authenticate, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestMiddleware_IdentifierKeysPolicyFileRules(t *testing.T) {
+	j, err := NewJWTIdentifier(JWTOptions{Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := ParsePolicyFile([]byte(`
+rules:
+  - name: api
+    path: /api/
+    rps: 0.02
+    burst: 1
+  - name: login
+    path: /login
+    key: ip
+    rps: 0.02
+    burst: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := NewRuleResolver(pf, RuleKeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(Options{
+		Store:      infra.NewStore(100, 100),
+		Identifier: j,
+		Routes:     routes,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(path, sub string) int {
+		r := bearer(httptest.NewRequest(http.MethodGet, "http://example"+path, nil), signHS(t, jwt.MapClaims{"sub": sub}))
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// regra sem key: cada sub tem o próprio bucket, mesmo atrás do mesmo IP
+	if c := do("/api/x", "alice"); c != http.StatusOK {
+		t.Fatalf("expected 200 for alice, got %d", c)
+	}
+	if c := do("/api/x", "bob"); c != http.StatusOK {
+		t.Fatalf("expected 200 for bob on his own rule bucket, got %d", c)
+	}
+	if c := do("/api/x", "alice"); c != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after alice's burst, got %d", c)
+	}
+	// key: ip explícito mantém o IP
+	if c := do("/login", "alice"); c != http.StatusOK {
+		t.Fatalf("expected 200 on login, got %d", c)
+	}
+	if c := do("/login", "bob"); c != http.StatusTooManyRequests {
+		t.Fatalf("expected login to share the IP bucket, got %d", c)
+	}
+}
@@ -13,7 +13,7 @@ func TestDefaultKeyFunc_PrefersHeaderWhenSet(t *testing.T) {
 	r.RemoteAddr = "10.0.0.1:1234"
 	r.Header.Set("X-Client", " client-123 ")
 
-	if got := fn(r); got != "client-123" {
+	if got := fn(r); got != "hdr:client-123" {
 		t.Fatalf("expected header key, got %q", got)
 	}
 }
@@ -40,3 +40,71 @@ func TestDefaultKeyFunc_FallbacksToRemoteAddrHost(t *testing.T) {
 		t.Fatalf("expected remote host, got %q", got)
 	}
 }
+
+func TestClientIP_TrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10, fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		remote string
+		header map[string]string
+		want   string
+	}{
+		{"untrusted remote ignores headers", "203.0.113.7:1000", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.7"},
+		{"xff right to left skips trusted hops", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.2, 10.1.1.1"}, "198.51.100.2"},
+		{"all hops trusted uses leftmost", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "192.168.1.10, 10.2.2.2"}, "192.168.1.10"},
+		{"forwarded takes precedence", "10.0.0.1:1000", map[string]string{
+			"Forwarded":       `for=198.51.100.9;proto=https, for="[fd00::1]:4711"`,
+			"X-Forwarded-For": "6.6.6.6",
+		}, "198.51.100.9"},
+		{"forwarded ipv6 client", "10.0.0.1:1000", map[string]string{"Forwarded": `for="[2001:db8::7]:443"`}, "2001:db8::7"},
+		{"obfuscated hop stops at last known address", "10.0.0.1:1000", map[string]string{"Forwarded": "for=6.6.6.6, for=_hidden"}, "10.0.0.1"},
+		{"x-real-ip without chain", "10.0.0.1:1000", map[string]string{"X-Real-IP": "198.51.100.3"}, "198.51.100.3"},
+		{"no headers uses remote", "10.0.0.1:1000", nil, "10.0.0.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+			r.RemoteAddr = tc.remote
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIP(r, proxies); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies_RejectsInvalid(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Fatalf("expected invalid CIDR error")
+	}
+	if _, err := ParseTrustedProxies("proxy.local"); err == nil {
+		t.Fatalf("expected invalid IP error")
+	}
+}
+
+func TestIPAggregation_Key(t *testing.T) {
+	agg := IPAggregation{IPv4Bits: 24, IPv6Bits: 56}
+	cases := map[string]string{
+		"2001:db8:aa:bbcc::1":   "2001:db8:aa:bb00::/56",
+		"fe80::1%eth0":          "fe80::/56",
+		"::ffff:198.51.100.200": "198.51.100.0/24",
+		"198.51.100.7":          "198.51.100.0/24",
+		"tok-123":               "tok-123",
+	}
+	for in, want := range cases {
+		if got := agg.Key(in); got != want {
+			t.Fatalf("Key(%q): expected %q, got %q", in, want, got)
+		}
+	}
+
+	// zero mantém o IP completo, só normalizado
+	if got := (IPAggregation{}).Key("::ffff:10.0.0.1"); got != "10.0.0.1" {
+		t.Fatalf("expected mapped IPv4 to be unmapped, got %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/application"
+	"middleware-gateway/middleware/ratelimit/domain"
+)
+
+// Limit é um limite extra avaliado junto com o principal (Options.Limits),
+// ex: por API key E global, além do limite por IP.
+type Limit struct {
+	// Name identifica o limite (Decision.Tripped, estatísticas) e isola os
+	// buckets dele no Store.
+	Name string
+	// KeyFn extrai a chave; chave vazia significa que o limite não se aplica
+	// à requisição (ex: sem API key). Se nil, usa a chave do limite principal
+	// (ex: cotas por cliente).
+	KeyFn    KeyFunc
+	Store    domain.LimiterStore
+	Policies domain.PolicyResolver
+	// PolicyFn (opcional) dá a política da requisição quando Policies não
+	// resolve a chave (ex: a cota da API key), no lugar da política do Store.
+	PolicyFn func(r *http.Request) (domain.Policy, bool)
+	// BlockDuration (opcional) ativa o lockout deste limite, guardado no Store
+	// se ele implementar domain.BlockStore.
+	BlockDuration time.Duration
+	// RejectStatus, Reason e OnReject (opcionais) substituem os do middleware
+	// quando é este limite que nega (ex: cota esgotada com status e corpo
+	// próprios).
+	RejectStatus int
+	Reason       string
+	OnReject     RejectHandler
+}
+
+// HeaderKeyFunc usa o valor do header como chave, com domain.HeaderKeyPrefix
+// (vazio se ausente).
+func HeaderKeyFunc(header string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+			return domain.HeaderKeyPrefix + v
+		}
+		return ""
+	}
+}
+
+// StaticKeyFunc usa sempre a mesma chave (ex: limite global).
+func StaticKeyFunc(key string) KeyFunc {
+	return func(*http.Request) string { return key }
+}
+
+type compiledLimit struct {
+	name     string
+	keyFn    KeyFunc
+	policyFn func(r *http.Request) (domain.Policy, bool)
+	svc      application.Service
+
+	rejectStatus int
+	reason       string
+	onReject     RejectHandler
+}
+
+func compileLimits(limits []Limit) []compiledLimit {
+	out := make([]compiledLimit, 0, len(limits))
+	for _, l := range limits {
+		if l.Store == nil {
+			continue
+		}
+		svc := application.Service{
+			Store:         l.Store,
+			Policies:      l.Policies,
+			BlockDuration: l.BlockDuration,
+		}
+		if l.BlockDuration > 0 {
+			svc.Blocks, _ = l.Store.(domain.BlockStore)
+		}
+		svc.DefaultPolicy.Name = l.Name
+		if ri, ok := l.Store.(rateInfo); ok {
+			svc.DefaultPolicy.RPS, svc.DefaultPolicy.Burst = ri.RPS(), ri.Burst()
+		}
+		out = append(out, compiledLimit{
+			name:         l.Name,
+			keyFn:        l.KeyFn,
+			policyFn:     l.PolicyFn,
+			svc:          svc,
+			rejectStatus: l.RejectStatus,
+			reason:       l.Reason,
+			onReject:     l.OnReject,
+		})
+	}
+	return out
+}
+
+func appendLimitChecks(checks []application.LimitCheck, limits []compiledLimit, r *http.Request, mainKey string) []application.LimitCheck {
+	for _, l := range limits {
+		key := mainKey
+		if l.keyFn != nil {
+			key = l.keyFn(r)
+		}
+		if key == "" {
+			continue
+		}
+		c := application.LimitCheck{
+			Name:    l.name,
+			Service: l.svc,
+			Key:     domain.Key(key),
+			Scope:   l.name,
+		}
+		if l.policyFn != nil {
+			if p, ok := l.policyFn(r); ok {
+				c.Default = &p
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// decideOne mantém o comportamento de limite único (sem Tripped).
+func decideOne(c application.LimitCheck) domain.Decision {
+	if c.Default != nil {
+		return c.Service.DecideWithPolicy(c.Scope, c.Key, *c.Default)
+	}
+	return c.Service.Decide(c.Key)
+}
+
+// limitNamed retorna o limite extra com esse nome (o que negou).
+func limitNamed(limits []compiledLimit, name string) (compiledLimit, bool) {
+	for _, l := range limits {
+		if name != "" && l.name == name {
+			return l, true
+		}
+	}
+	return compiledLimit{}, false
+}
+
+// trippedKey é a chave do limite que negou (para a resposta de rejeição).
+func trippedKey(checks []application.LimitCheck, tripped, fallback string) string {
+	for _, c := range checks {
+		if tripped != "" && c.Name == tripped {
+			return string(c.Key)
+		}
+	}
+	return fallback
+}
@@ -8,19 +8,88 @@ import (
 
 	"middleware-gateway/middleware/ratelimit/application"
 	"middleware-gateway/middleware/ratelimit/domain"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type KeyFunc func(r *http.Request) string
 
+// Options configura o Middleware de rate limit. Só Store é obrigatório; os
+// demais campos são opcionais e, vazios, mantêm o comportamento padrão (chave
+// por IP, 429 em texto puro, sem headers de cota).
 type Options struct {
-	Store               domain.LimiterStore
-	Stats               domain.StatsStore
-	KeyFn               KeyFunc
-	KeyHeader           string
-	TrustXForwardedFor  bool
-	RejectStatus        int
-	RetryAfter          time.Duration
+	Store domain.LimiterStore
+	// Policies resolve limites próprios por chave (ex: tokens de API_KEY), com
+	// precedência sobre o limite padrão do Store.
+	Policies domain.PolicyResolver
+	Stats    domain.StatsStore
+
+	KeyFn     KeyFunc
+	KeyHeader string
+	// TrustXForwardedFor, sem TrustedProxies, usa o primeiro IP do
+	// X-Forwarded-For, que o próprio cliente pode forjar.
+	TrustXForwardedFor bool
+	// TrustedProxies faz o IP do cliente ser extraído com ClientIP:
+	// Forwarded/X-Forwarded-For/X-Real-IP só valem quando vêm desses proxies.
+	TrustedProxies *TrustedProxies
+	// IPAggregation agrega o IP da chave padrão em sub-redes (ex: /64 em
+	// IPv6). Para um KeyFn próprio, use AggregateIPs.
+	IPAggregation IPAggregation
+
+	RejectStatus int
+	// RetryAfter é só o fallback do header Retry-After: limiters que
+	// implementam domain.ReportingLimiter (todos os de infra) informam o tempo
+	// real até a próxima vaga.
+	RetryAfter time.Duration
+	// AddRateLimitHeaders adiciona os headers de debug X-RateLimit-RPS/Burst
+	// (e, se Headers estiver vazio, liga HeadersLegacy).
 	AddRateLimitHeaders bool
+	// Headers escolhe os headers de cota enviados ao cliente (HeadersNone,
+	// HeadersLegacy, HeadersIETF ou HeadersBoth), calculados a partir do
+	// estado real do limiter.
+	Headers HeaderMode
+	// ExposeKey expõe a chave do cliente em X-RateLimit-Key.
+	ExposeKey bool
+
+	// BlockDuration ativa o lockout: ao estourar o limite a chave fica
+	// bloqueada por esse tempo.
+	BlockDuration time.Duration
+	// Blocks guarda o lockout. Se nil e o Store implementar domain.BlockStore
+	// (infra.Store, infra.RedisStore), usa o Store quando BlockDuration,
+	// Routes ou Policies declaram bloqueio (HasBlock); com Policies que não
+	// implementam HasBlock, defina Blocks explicitamente.
+	Blocks domain.BlockStore
+	// Routes escolhe uma regra por requisição (path/método), cada uma com sua
+	// política, fonte de chave e status de rejeição. Requisições sem regra
+	// correspondente seguem o comportamento padrão.
+	Routes RouteResolver
+	// OnReject escreve a resposta de rejeição; o padrão é texto puro com
+	// StatusText (ver TextRejecter, ProblemJSONRejecter e TemplateRejecter).
+	OnReject RejectHandler
+	// Limits são limites extras avaliados em ordem depois do principal
+	// (Store/KeyFn/Routes): a requisição só passa se todos permitirem, e quem
+	// negou aparece em Decision.Tripped, nas estatísticas e na rejeição.
+	Limits []Limit
+	// Identifier identifica o cliente por credencial (ex: JWTIdentifier): a
+	// chave dele substitui a do KeyFn, inclusive nas regras sem key: (só as
+	// com fonte de chave explícita mantêm a delas), e a política dele, se
+	// houver, tem precedência. Um erro recusa a requisição com 401, escrita
+	// por OnUnauthorized (padrão TextRejecter("")).
+	Identifier     Identifier
+	OnUnauthorized RejectHandler
+	// Exemptions isenta chaves temporariamente (ex: liberadas pela API admin):
+	// a requisição de uma chave isenta não passa por nenhum limite. É uma
+	// consulta a mais por requisição, então só defina se for usar.
+	Exemptions domain.ExemptionStore
+	// Access aplica listas de acesso antes de qualquer limite (e do
+	// Identifier): clientes na denylist são recusados com 403 por OnDeny
+	// (padrão TextRejecter("")) e os da allowlist passam direto. As duas
+	// decisões vão para as estatísticas com StatsEvent.Outcome próprio.
+	Access *AccessList
+	OnDeny RejectHandler
+	// Tracer cria o span SpanDecision, filho do span da requisição, com a
+	// chave, a política, o limite que negou e o resultado (AttrOutcome).
+	Tracer trace.Tracer
 }
 
 type rateInfo interface {
@@ -28,11 +97,13 @@ type rateInfo interface {
 	Burst() int
 }
 
+// DefaultKeyFunc usa o header keyHeader (marcado com domain.HeaderKeyPrefix)
+// ou, sem ele, o IP do cliente.
 func DefaultKeyFunc(keyHeader string, trustXFF bool) KeyFunc {
 	return func(r *http.Request) string {
 		if keyHeader != "" {
 			if v := strings.TrimSpace(r.Header.Get(keyHeader)); v != "" {
-				return v
+				return domain.HeaderKeyPrefix + v
 			}
 		}
 
@@ -68,44 +139,199 @@ func Middleware(opts Options) func(next http.Handler) http.Handler {
 	if opts.RetryAfter == 0 {
 		opts.RetryAfter = 1 * time.Second
 	}
+	if opts.OnReject == nil {
+		opts.OnReject = TextRejecter("")
+	}
+	if opts.OnUnauthorized == nil {
+		opts.OnUnauthorized = TextRejecter("")
+	}
+	if opts.OnDeny == nil {
+		opts.OnDeny = TextRejecter("")
+	}
+	if opts.Headers == "" {
+		opts.Headers = HeadersNone
+		if opts.AddRateLimitHeaders {
+			opts.Headers = HeadersLegacy
+		}
+	}
 	if opts.KeyFn == nil {
-		opts.KeyFn = DefaultKeyFunc(opts.KeyHeader, opts.TrustXForwardedFor)
+		if opts.TrustedProxies != nil {
+			opts.KeyFn = ProxyKeyFunc(opts.KeyHeader, opts.TrustedProxies)
+		} else {
+			opts.KeyFn = DefaultKeyFunc(opts.KeyHeader, opts.TrustXForwardedFor)
+		}
+		if opts.IPAggregation != (IPAggregation{}) {
+			opts.KeyFn = AggregateIPs(opts.KeyFn, opts.IPAggregation)
+		}
+	}
+	if opts.Blocks == nil && (opts.BlockDuration > 0 || declaresBlock(opts.Routes) || declaresBlock(opts.Policies)) {
+		if bs, ok := opts.Store.(domain.BlockStore); ok {
+			opts.Blocks = bs
+		}
 	}
 
 	svc := application.Service{
 		Store:      opts.Store,
 		RetryAfter: opts.RetryAfter,
+		Policies:   opts.Policies,
+
+		Blocks:        opts.Blocks,
+		BlockDuration: opts.BlockDuration,
+	}
+	if ri, ok := opts.Store.(rateInfo); ok {
+		svc.DefaultPolicy = domain.Policy{Name: "default", RPS: ri.RPS(), Burst: ri.Burst()}
 	}
 
+	extra := compileLimits(opts.Limits)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := opts.KeyFn(r)
+			var (
+				key          string
+				dec          domain.Decision
+				rejectStatus = opts.RejectStatus
+				main         = application.LimitCheck{Name: "default", Service: svc}
+				keyFn        = opts.KeyFn
+				span         = startSpan(opts.Tracer, r, SpanDecision)
+			)
+			if opts.Identifier != nil || len(extra) > 0 {
+				r = r.WithContext(withAPIKeyMemo(r.Context()))
+			}
+			switch access, who := opts.Access.Check(r); access {
+			case AccessDeny:
+				recordOutcome(opts.Stats, r, who, false, domain.OutcomeDenylisted)
+				endDecision(r, span, domain.OutcomeDenylisted, who, "", "", ReasonDenied)
+				opts.OnDeny(w, r, Rejection{Status: http.StatusForbidden, Reason: ReasonDenied, Key: who})
+				return
+			case AccessAllow:
+				recordOutcome(opts.Stats, r, who, true, domain.OutcomeAllowlisted)
+				endDecision(r, span, domain.OutcomeAllowlisted, who, "", "", "")
+				next.ServeHTTP(w, r)
+				return
+			}
+			if opts.Identifier != nil {
+				id, err := opts.Identifier.Identify(r)
+				if err != nil {
+					if c := authChallenge(err); c != "" {
+						w.Header().Set("WWW-Authenticate", c)
+					}
+					endDecision(r, span, OutcomeRejected, "", "", "", ReasonUnauthorized)
+					opts.OnUnauthorized(w, r, Rejection{Status: http.StatusUnauthorized, Reason: ReasonUnauthorized})
+					return
+				}
+				if id.Key != "" {
+					keyFn = StaticKeyFunc(id.Key)
+					if id.Policy != nil {
+						main.Service.Policies = identityPolicies{key: domain.Key(id.Key), policy: *id.Policy, next: svc.Policies}
+					}
+				}
+			}
+			if rt, ok := routeFor(opts.Routes, r); ok {
+				if rt.KeyFn != nil {
+					keyFn = rt.KeyFn
+				}
+				key = keyFn(r)
+				main.Name, main.Scope, main.Default = rt.Name, rt.Name, &rt.Policy
+				if rt.RejectStatus != 0 {
+					rejectStatus = rt.RejectStatus
+				}
+			} else {
+				key = keyFn(r)
+			}
+			main.Key = domain.Key(key)
 
-			if opts.AddRateLimitHeaders {
-				w.Header().Set("X-RateLimit-Key", key)
-				if ri, ok := opts.Store.(rateInfo); ok {
-					w.Header().Set("X-RateLimit-RPS", formatFloat(ri.RPS()))
-					w.Header().Set("X-RateLimit-Burst", formatInt(ri.Burst()))
+			if opts.Exemptions != nil && opts.Exemptions.ExemptFor(main.Key) > 0 {
+				recordOutcome(opts.Stats, r, key, true, domain.OutcomeExempt)
+				endDecision(r, span, domain.OutcomeExempt, key, "", "", "")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rejectKey, reason, onReject := key, ReasonRateLimited, opts.OnReject
+			if len(extra) == 0 {
+				dec = decideOne(main)
+			} else {
+				checks := append(make([]application.LimitCheck, 0, len(extra)+1), main)
+				checks = appendLimitChecks(checks, extra, r, key)
+				dec = application.DecideAll(checks)
+				rejectKey = trippedKey(checks, dec.Tripped, key)
+				if l, ok := limitNamed(extra, dec.Tripped); ok {
+					if l.rejectStatus != 0 {
+						rejectStatus = l.rejectStatus
+					}
+					if l.reason != "" {
+						reason = l.reason
+					}
+					if l.onReject != nil {
+						onReject = l.onReject
+					}
 				}
 			}
 
-			dec := svc.Decide(domain.Key(key))
+			writeQuotaHeaders(w.Header(), opts.Headers, dec)
+			if opts.ExposeKey {
+				w.Header().Set("X-RateLimit-Key", key)
+			}
+			if opts.AddRateLimitHeaders && dec.Policy.RPS > 0 {
+				w.Header().Set("X-RateLimit-RPS", formatFloat(dec.Policy.RPS))
+				w.Header().Set("X-RateLimit-Burst", formatInt(dec.Policy.Burst))
+			}
 			if opts.Stats != nil {
 				_ = opts.Stats.Record(r.Context(), domain.StatsEvent{
 					Key:     domain.Key(key),
 					Allowed: dec.Allowed,
+					Limit:   dec.Tripped,
 					Method:  r.Method,
 					Path:    r.URL.Path,
 					At:      time.Now(),
 				})
 			}
 			if !dec.Allowed {
-				w.Header().Set("Retry-After", formatInt(int(dec.RetryAfter.Seconds())))
-				http.Error(w, http.StatusText(opts.RejectStatus), opts.RejectStatus)
+				endDecision(r, span, OutcomeRejected, key, policyName(dec.Policy), dec.Tripped, reason)
+				w.Header().Set("Retry-After", formatSeconds(dec.RetryAfter))
+				onReject(w, r, Rejection{
+					Status:     rejectStatus,
+					Reason:     reason,
+					Key:        rejectKey,
+					Policy:     policyName(dec.Policy),
+					Limit:      dec.Tripped,
+					RetryAfter: dec.RetryAfter,
+				})
 				return
 			}
 
+			endDecision(r, span, OutcomeAllowed, key, policyName(dec.Policy), "", "")
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// recordOutcome registra uma decisão tomada fora dos limites (listas de
+// acesso, isenções).
+func recordOutcome(stats domain.StatsStore, r *http.Request, key string, allowed bool, outcome string) {
+	if stats == nil {
+		return
+	}
+	_ = stats.Record(r.Context(), domain.StatsEvent{
+		Key:     domain.Key(key),
+		Allowed: allowed,
+		Outcome: outcome,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		At:      time.Now(),
+	})
+}
+
+// declaresBlock informa se as regras ou políticas (RuleResolver,
+// infra.TokenPolicies) declaram bloqueio (lockout).
+func declaresBlock(v any) bool {
+	hb, ok := v.(interface{ HasBlock() bool })
+	return ok && hb.HasBlock()
+}
+
+func routeFor(rr RouteResolver, r *http.Request) (Route, bool) {
+	if rr == nil {
+		return Route{}, false
+	}
+	return rr.Route(r)
+}
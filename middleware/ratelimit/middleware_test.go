@@ -59,8 +59,8 @@ func TestMiddleware_AllowsThenRejectsSameKey(t *testing.T) {
 	if w1.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", w1.Code)
 	}
-	if got := w1.Header().Get("X-RateLimit-Key"); got == "" {
-		t.Fatalf("expected X-RateLimit-Key header to be set")
+	if got := w1.Header().Get("X-RateLimit-Key"); got != "" {
+		t.Fatalf("expected X-RateLimit-Key not to be exposed by default, got %q", got)
 	}
 	if got := w1.Header().Get("X-RateLimit-RPS"); got == "" {
 		t.Fatalf("expected X-RateLimit-RPS header to be set")
@@ -119,16 +119,38 @@ func TestMiddleware_KeyByHeader(t *testing.T) {
 	}
 }
 
-func TestMiddleware_RetryAfterUsesSeconds(t *testing.T) {
+func TestMiddleware_HeaderKeyCannotSpendAnotherClientsIP(t *testing.T) {
 	store := infra.NewStore(0.02, 1)
+	h := Middleware(Options{Store: store, KeyHeader: "X-Api-Key"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// o atacante manda no header o IP da vítima
+	r1 := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r1.Header.Set("X-Api-Key", "10.0.0.2")
+	r1.RemoteAddr = "10.0.0.1:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r1)
+
+	r2 := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r2.RemoteAddr = "10.0.0.2:1234"
+	w2 := httptest.NewRecorder()
+	h.ServeHTTP(w2, r2)
+	if w2.Code != http.StatusOK {
+		t.Fatalf("expected victim IP to keep its own bucket, got %d", w2.Code)
+	}
+}
+
+func TestMiddleware_RetryAfterIsTimeUntilNextToken(t *testing.T) {
+	store := infra.NewStore(0.5, 1)
 
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
 	h := Middleware(Options{
-		Store:      store,
-		RetryAfter: 2500 * time.Millisecond,
+		Store:               store,
+		RetryAfter:          30 * time.Second,
+		AddRateLimitHeaders: true,
 	})(next)
 
 	r1 := httptest.NewRequest(http.MethodGet, "http://example/", nil)
@@ -138,6 +160,9 @@ func TestMiddleware_RetryAfterUsesSeconds(t *testing.T) {
 	if w1.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", w1.Code)
 	}
+	if got := w1.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Fatalf("expected X-RateLimit-Remaining=0, got %q", got)
+	}
 
 	r2 := httptest.NewRequest(http.MethodGet, "http://example/", nil)
 	r2.RemoteAddr = "10.0.0.1:1234"
@@ -147,11 +172,30 @@ func TestMiddleware_RetryAfterUsesSeconds(t *testing.T) {
 		t.Fatalf("expected 429, got %d", w2.Code)
 	}
 	if got := strings.TrimSpace(w2.Header().Get("Retry-After")); got != "2" {
-		// int(2.5s.Seconds()) == 2
+		// 0.5 rps => próximo token em ~2s (arredondado para cima), não o RetryAfter fixo
 		t.Fatalf("expected Retry-After=2, got %q", got)
 	}
 }
 
+type allowNever struct{}
+
+func (allowNever) Allow() bool                   { return false }
+func (allowNever) Get(domain.Key) domain.Limiter { return allowNever{} }
+
+func TestMiddleware_RetryAfterFallsBackToOptionForPlainLimiters(t *testing.T) {
+	h := Middleware(Options{
+		Store:      allowNever{},
+		RetryAfter: 2500 * time.Millisecond,
+	})(http.NotFoundHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Fatalf("expected Retry-After=3 (2.5s rounded up), got %q", got)
+	}
+}
+
 func TestMiddleware_RecordsStatsAllowedAndDenied(t *testing.T) {
 	store := infra.NewStore(0.02, 1)
 	stats := &fakeStatsStore{}
@@ -198,3 +242,284 @@ func TestMiddleware_RecordsStatsAllowedAndDenied(t *testing.T) {
 		t.Fatalf("expected method/path GET /showTela, got %q %q", evs[0].Method, evs[0].Path)
 	}
 }
+
+func TestMiddleware_TokenPolicyOverridesIPAndSetsHeaders(t *testing.T) {
+	store := infra.NewStore(0.02, 1)
+	tokens := infra.NewTokenPolicies(map[string]domain.Policy{
+		"abc123": {RPS: 100, Burst: 3},
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := Middleware(Options{
+		Store:               store,
+		Policies:            tokens,
+		KeyHeader:           "API_KEY",
+		AddRateLimitHeaders: true,
+	})(next)
+
+	// token: burst=3 (política do token), apesar do IP ter burst=1
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("API_KEY", "abc123")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 for token, got %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-RPS"); got != "100" {
+			t.Fatalf("expected X-RateLimit-RPS=100, got %q", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Burst"); got != "3" {
+			t.Fatalf("expected X-RateLimit-Burst=3, got %q", got)
+		}
+	}
+
+	// sem token: cai na política por IP
+	r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for first IP request, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-RPS"); got != "0.02" {
+		t.Fatalf("expected X-RateLimit-RPS=0.02, got %q", got)
+	}
+}
+
+func TestMiddleware_BlockDurationLocksKeyOut(t *testing.T) {
+	// rps alto: sem lockout, a chave voltaria a passar em poucos ms
+	store := infra.NewStore(1000, 1)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := Middleware(Options{
+		Store:         store,
+		BlockDuration: 5 * time.Minute,
+	})(next)
+
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w := do()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "300" {
+		t.Fatalf("expected Retry-After=300, got %q", got)
+	}
+
+	time.Sleep(5 * time.Millisecond) // bucket já reabasteceu
+	if w := do(); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected key to stay blocked, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_PolicyBlockWiresStoreBlocks(t *testing.T) {
+	// o bloqueio vem só da política do token, sem BlockDuration nem Blocks
+	h := Middleware(Options{
+		Store: infra.NewStore(1000, 1000),
+		Policies: infra.NewTokenPolicies(map[string]domain.Policy{
+			"tok": {Name: "token", RPS: 1000, Burst: 1, BlockDuration: time.Minute},
+		}),
+		KeyHeader: "X-API-Key",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.Header.Set("X-API-Key", "tok")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	do()
+	do()
+	time.Sleep(5 * time.Millisecond) // bucket já reabasteceu
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the token policy to lock the key out, got %d", code)
+	}
+}
+
+func TestMiddleware_HeaderModes(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(h http.Handler) http.Header {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Header()
+	}
+
+	none := do(Middleware(Options{Store: infra.NewStore(1, 5)})(next))
+	for _, name := range []string{"RateLimit", "RateLimit-Policy", "X-RateLimit-Limit", "X-RateLimit-Key"} {
+		if got := none.Get(name); got != "" {
+			t.Fatalf("expected no %s header by default, got %q", name, got)
+		}
+	}
+
+	both := do(Middleware(Options{Store: infra.NewStore(1, 5), Headers: HeadersBoth, ExposeKey: true})(next))
+	want := map[string]string{
+		"X-RateLimit-Limit":     "5",
+		"X-RateLimit-Remaining": "4",
+		"X-RateLimit-Reset":     "1",
+		"RateLimit-Policy":      `"default";q=5;w=5`,
+		"RateLimit":             `"default";r=4;t=1`,
+		"X-RateLimit-Key":       "10.0.0.1",
+	}
+	for name, v := range want {
+		if got := both.Get(name); got != v {
+			t.Fatalf("expected %s=%q, got %q", name, v, got)
+		}
+	}
+
+	ietf := do(Middleware(Options{Store: infra.NewFixedWindowStore(100, time.Minute), Headers: HeadersIETF})(next))
+	if got := ietf.Get("RateLimit-Policy"); got != `"default";q=100;w=60` {
+		t.Fatalf("expected window policy, got %q", got)
+	}
+	if got := ietf.Get("X-RateLimit-Limit"); got != "" {
+		t.Fatalf("expected no legacy headers in ietf mode, got %q", got)
+	}
+}
+
+func TestParseHeaderMode(t *testing.T) {
+	if m, err := ParseHeaderMode(" IETF "); err != nil || m != HeadersIETF {
+		t.Fatalf("expected ietf, got %q (%v)", m, err)
+	}
+	if m, err := ParseHeaderMode(""); err != nil || m != HeadersNone {
+		t.Fatalf("expected empty to mean none, got %q (%v)", m, err)
+	}
+	if _, err := ParseHeaderMode("draft"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
+
+func TestMiddleware_IPv6PrefixSharesBucket(t *testing.T) {
+	h := Middleware(Options{
+		Store:         infra.NewStore(0.02, 1),
+		IPAggregation: IPAggregation{IPv6Bits: 64},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(remote string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if do("[2001:db8:1:2::1]:1000") != http.StatusOK {
+		t.Fatalf("expected first address of the /64 to pass")
+	}
+	if code := do("[2001:db8:1:2::ffff]:1000"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected another address of the same /64 to share the bucket, got %d", code)
+	}
+	if do("[2001:db8:1:3::1]:1000") != http.StatusOK {
+		t.Fatalf("expected a different /64 to have its own bucket")
+	}
+}
+
+func TestMiddleware_LimitsDenyWithoutConsumingOthers(t *testing.T) {
+	stats := &fakeStatsStore{}
+	h := Middleware(Options{
+		Store: infra.NewStore(0.02, 2),
+		Stats: stats,
+		Limits: []Limit{{
+			Name:  "api-key",
+			KeyFn: HeaderKeyFunc("X-API-Key"),
+			Store: infra.NewStore(0.02, 1),
+		}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(apiKey string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := do("k1"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := do("k1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 from the api-key limit, got %d", code)
+	}
+	// a negação pela API key devolveu o token do IP: ainda resta 1
+	if code := do(""); code != http.StatusOK {
+		t.Fatalf("expected IP quota untouched by the api-key denial, got %d", code)
+	}
+	if code := do(""); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 from the IP limit, got %d", code)
+	}
+
+	var tripped []string
+	for _, ev := range stats.Events() {
+		tripped = append(tripped, ev.Limit)
+	}
+	if got := strings.Join(tripped, ","); got != ",api-key,,default" {
+		t.Fatalf("unexpected tripped limits in stats: %q", got)
+	}
+}
+
+func TestMiddleware_QuotaLimitRejectsWithOwnStatus(t *testing.T) {
+	h := Middleware(Options{
+		Store: infra.NewStore(100, 100),
+		Limits: []Limit{{
+			Name:         "quota-day",
+			Store:        infra.NewQuotaStore(domain.QuotaDay, 1),
+			RejectStatus: http.StatusForbidden,
+			Reason:       ReasonQuotaExceeded,
+			OnReject:     ProblemJSONRejecter("quota exceeded"),
+		}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the quota, got %d", w.Code)
+	}
+	w := do("10.0.0.1")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"reason":"quota_exceeded"`) {
+		t.Fatalf("expected 403 quota_exceeded, got %d %s", w.Code, w.Body.String())
+	}
+	// Retry-After aponta para a virada do dia UTC, não para o próximo token
+	_, end := domain.QuotaDay.Window(time.Now())
+	got := w.Header().Get("Retry-After")
+	if got != formatSeconds(time.Until(end)) && got != formatSeconds(time.Until(end)+time.Second) {
+		t.Fatalf("expected Retry-After until midnight UTC (%s), got %s", time.Until(end), got)
+	}
+	// sem KeyFn a cota usa a chave principal: outro IP tem a própria
+	if w := do("10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for another client, got %d", w.Code)
+	}
+}
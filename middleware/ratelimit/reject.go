@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Motivos de rejeição informados em Rejection.Reason.
+const (
+	ReasonRateLimited = "rate_limited"
+	ReasonConcurrency = "concurrency"
+	// ReasonUnauthorized: credencial inválida recusada por um Identifier (401).
+	ReasonUnauthorized = "unauthorized"
+	// ReasonQuotaExceeded: cota de calendário (hora/dia/mês) esgotada.
+	ReasonQuotaExceeded = "quota_exceeded"
+	// ReasonDenied: cliente na denylist (Options.Access), recusado com 403.
+	ReasonDenied = "denied"
+)
+
+// Rejection descreve uma requisição recusada, para o RejectHandler montar a resposta.
+type Rejection struct {
+	Status int
+	// Reason é ReasonRateLimited, ReasonConcurrency, ReasonUnauthorized,
+	// ReasonQuotaExceeded, ReasonDenied ou o Reason de um Limit.
+	Reason string
+	// Key é a chave usada no limite (IP, token...); vazia na concorrência.
+	Key string
+	// Policy é o nome da política/regra aplicada; vazio na concorrência.
+	Policy string
+	// Limit é o nome do limite que negou (Options.Limits); vazio com um limite só.
+	Limit      string
+	RetryAfter time.Duration
+}
+
+// RetryAfterSeconds é RetryAfter em segundos inteiros (arredondado para cima),
+// o mesmo valor do header Retry-After.
+func (r Rejection) RetryAfterSeconds() int {
+	return int(math.Ceil(r.RetryAfter.Seconds()))
+}
+
+// RejectHandler escreve a resposta de uma requisição recusada. Quando é
+// chamado, Retry-After e os headers de cota já estão no ResponseWriter.
+type RejectHandler func(w http.ResponseWriter, r *http.Request, rej Rejection)
+
+// TextRejecter responde em texto puro com message (ou StatusText, se vazio).
+// É o padrão dos middlewares.
+func TextRejecter(message string) RejectHandler {
+	return func(w http.ResponseWriter, _ *http.Request, rej Rejection) {
+		msg := message
+		if msg == "" {
+			msg = http.StatusText(rej.Status)
+		}
+		http.Error(w, msg, rej.Status)
+	}
+}
+
+// problem é o corpo application/problem+json (RFC 9457), com extensões.
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	Reason     string `json:"reason"`
+	Key        string `json:"key,omitempty"`
+	Policy     string `json:"policy,omitempty"`
+	Limit      string `json:"limit,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// ProblemJSONRejecter responde com application/problem+json (RFC 9457).
+// message vai em "detail"; key, policy, limit e retry_after (segundos) vão como
+// extensões.
+func ProblemJSONRejecter(message string) RejectHandler {
+	return func(w http.ResponseWriter, r *http.Request, rej Rejection) {
+		body, err := json.Marshal(problem{
+			Type:       "about:blank",
+			Title:      http.StatusText(rej.Status),
+			Status:     rej.Status,
+			Detail:     message,
+			Instance:   r.URL.Path,
+			Reason:     rej.Reason,
+			Key:        rej.Key,
+			Policy:     rej.Policy,
+			Limit:      rej.Limit,
+			RetryAfter: rej.RetryAfterSeconds(),
+		})
+		if err != nil {
+			http.Error(w, http.StatusText(rej.Status), rej.Status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(rej.Status)
+		_, _ = w.Write(append(body, '\n'))
+	}
+}
+
+// TemplateData é o valor passado ao template de TemplateRejecter.
+type TemplateData struct {
+	Rejection
+	Message string
+	Method  string
+	Path    string
+}
+
+// TemplateRejecter renderiza tmpl (text/template) com TemplateData.
+// Se a renderização falhar, responde em texto puro com message.
+func TemplateRejecter(tmpl *template.Template, contentType, message string) RejectHandler {
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	fallback := TextRejecter(message)
+	return func(w http.ResponseWriter, r *http.Request, rej Rejection) {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, TemplateData{
+			Rejection: rej,
+			Message:   message,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+		})
+		if err != nil {
+			fallback(w, r, rej)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(rej.Status)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// LoadRejectTemplate lê e compila um template de resposta de rejeição.
+func LoadRejectTemplate(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("reject").Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+func rejectOnce(t *testing.T, opts Options) *httptest.ResponseRecorder {
+	t.Helper()
+	opts.Store = infra.NewStore(0.5, 1)
+	h := Middleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://example/api/x", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	return w
+}
+
+func TestTextRejecter_UsesMessage(t *testing.T) {
+	const msg = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+	w := rejectOnce(t, Options{OnReject: TextRejecter(msg)})
+	if got := strings.TrimSpace(w.Body.String()); got != msg {
+		t.Fatalf("expected challenge message, got %q", got)
+	}
+
+	w = rejectOnce(t, Options{})
+	if got := strings.TrimSpace(w.Body.String()); got != http.StatusText(http.StatusTooManyRequests) {
+		t.Fatalf("expected StatusText by default, got %q", got)
+	}
+}
+
+func TestProblemJSONRejecter(t *testing.T) {
+	w := rejectOnce(t, Options{OnReject: ProblemJSONRejecter("slow down")})
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected problem+json content type, got %q", ct)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	want := map[string]any{
+		"status": float64(429), "title": "Too Many Requests", "detail": "slow down",
+		"instance": "/api/x", "reason": ReasonRateLimited, "key": "10.0.0.1",
+		"policy": "default", "retry_after": float64(2),
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Fatalf("expected %s=%v, got %v (body %s)", k, v, body[k], w.Body.String())
+		}
+	}
+}
+
+func TestTemplateRejecter(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse(`{{.Message}} [{{.Policy}}] retry in {{.RetryAfterSeconds}}s ({{.Method}} {{.Path}})`))
+	w := rejectOnce(t, Options{OnReject: TemplateRejecter(tmpl, "text/html", "limit")})
+
+	if got := w.Body.String(); got != "limit [default] retry in 2s (GET /api/x)" {
+		t.Fatalf("unexpected template body: %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html" {
+		t.Fatalf("expected template content type, got %q", ct)
+	}
+}
+
+func TestConcurrencyMiddleware_UsesRejectHandler(t *testing.T) {
+	block := make(chan struct{})
+	entered := make(chan struct{})
+	var got Rejection
+	h := ConcurrencyMiddleware(ConcurrencyOptions{
+		Max:            1,
+		AcquireTimeout: time.Millisecond,
+		OnReject: func(w http.ResponseWriter, r *http.Request, rej Rejection) {
+			got = rej
+			w.WriteHeader(rej.Status)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-block
+	}))
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example/", nil))
+	<-entered
+	defer close(block)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example/", nil))
+	if w.Code != http.StatusServiceUnavailable || got.Reason != ReasonConcurrency {
+		t.Fatalf("expected 503 from reject handler, got %d (%+v)", w.Code, got)
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// RequestInfo recebe o que os middlewares decidiram sobre uma requisição,
+// para quem está por fora (ex: log de acesso) registrar. Crie com
+// WithRequestInfo antes de chamar os middlewares e leia depois que eles
+// retornarem.
+type RequestInfo struct {
+	// Key é a chave do cliente (IP, token...) usada no limite ou na lista de acesso.
+	Key    string
+	Policy string
+	// Limit é o limite que negou (Options.Limits), se houver.
+	Limit string
+	// Outcome é OutcomeAllowed, OutcomeRejected ou um domain.Outcome*
+	// (allowlisted, denylisted, exempt, concurrency); vazio se nenhum
+	// middleware decidiu.
+	Outcome string
+	// Reason é o motivo da rejeição (Rejection.Reason).
+	Reason string
+	// ConcurrencyWait é o tempo esperando vaga no ConcurrencyMiddleware.
+	ConcurrencyWait time.Duration
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo devolve um contexto com um RequestInfo vazio, preenchido
+// pelos middlewares que receberem esse contexto.
+func WithRequestInfo(ctx context.Context) (context.Context, *RequestInfo) {
+	info := &RequestInfo{}
+	return context.WithValue(ctx, requestInfoKey{}, info), info
+}
+
+// RequestInfoFrom retorna o RequestInfo do contexto (nil se não houver).
+func RequestInfoFrom(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
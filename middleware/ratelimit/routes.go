@@ -0,0 +1,307 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route é a regra de rate limit escolhida para uma requisição.
+type Route struct {
+	// Name isola o estado: a mesma chave em rotas diferentes tem buckets diferentes.
+	Name   string
+	Policy domain.Policy
+	// KeyFn (opcional) extrai a chave do cliente; se nil, usa Options.KeyFn.
+	KeyFn KeyFunc
+	// RejectStatus (opcional) sobrepõe Options.RejectStatus.
+	RejectStatus int
+}
+
+// RouteResolver escolhe a regra aplicável a uma requisição.
+// Se ok=false, o Middleware usa o comportamento padrão (Options.Store/Policies).
+type RouteResolver interface {
+	Route(r *http.Request) (rt Route, ok bool)
+}
+
+// PolicyFile é o formato do arquivo declarativo de políticas (YAML ou JSON):
+//
+//	rules:
+//	  - name: login
+//	    path: /api/login        # prefixo; aceita glob (path.Match) e sufixo /**
+//	    methods: [POST]         # vazio = todos
+//	    key: ip                 # ip | token | header:X-Nome; vazio = chave do Middleware
+//	    rps: 1
+//	    burst: 5
+//	    block: 5m               # opcional
+//	    reject_status: 429      # opcional
+//
+// As regras são avaliadas em ordem; vale a primeira que casar.
+type PolicyFile struct {
+	Rules []PolicyRule `yaml:"rules" json:"rules"`
+}
+
+type PolicyRule struct {
+	Name         string   `yaml:"name" json:"name"`
+	Path         string   `yaml:"path" json:"path"`
+	Methods      []string `yaml:"methods" json:"methods"`
+	Key          string   `yaml:"key" json:"key"`
+	RPS          float64  `yaml:"rps" json:"rps"`
+	Burst        int      `yaml:"burst" json:"burst"`
+	Block        string   `yaml:"block" json:"block"`
+	RejectStatus int      `yaml:"reject_status" json:"reject_status"`
+}
+
+// LoadPolicyFile lê e valida um arquivo de políticas (YAML; JSON também é aceito).
+func LoadPolicyFile(file string) (PolicyFile, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	return ParsePolicyFile(b)
+}
+
+func ParsePolicyFile(b []byte) (PolicyFile, error) {
+	var pf PolicyFile
+	if err := yaml.Unmarshal(b, &pf); err != nil {
+		return PolicyFile{}, err
+	}
+	seen := make(map[string]bool, len(pf.Rules))
+	for i, rule := range pf.Rules {
+		if rule.Name == "" {
+			return PolicyFile{}, fmt.Errorf("rule #%d: name is required", i+1)
+		}
+		if seen[rule.Name] {
+			return PolicyFile{}, fmt.Errorf("rule %q: duplicated name", rule.Name)
+		}
+		if strings.Contains(rule.Name, ":") {
+			// o bucket da regra é "rule:<nome>:<chave>" (application.SplitScopedKey)
+			return PolicyFile{}, fmt.Errorf("rule %q: name must not contain ':'", rule.Name)
+		}
+		seen[rule.Name] = true
+		if _, err := compileRule(rule, RuleKeyOptions{}); err != nil {
+			return PolicyFile{}, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return pf, nil
+}
+
+// RuleKeyOptions configura como as fontes de chave das regras extraem o cliente.
+type RuleKeyOptions struct {
+	// TokenHeader é o header usado por key: token (padrão "API_KEY").
+	TokenHeader        string
+	TrustXForwardedFor bool
+	// TrustedProxies (opcional): se definido, o IP vem de ClientIP e
+	// TrustXForwardedFor é ignorado.
+	TrustedProxies *TrustedProxies
+	// IPAggregation agrega o IP do cliente em sub-redes (ver IPAggregation.Key).
+	IPAggregation IPAggregation
+}
+
+func (o RuleKeyOptions) keyFunc(header string) KeyFunc {
+	fn := DefaultKeyFunc(header, o.TrustXForwardedFor)
+	if o.TrustedProxies != nil {
+		fn = ProxyKeyFunc(header, o.TrustedProxies)
+	}
+	if o.IPAggregation != (IPAggregation{}) {
+		fn = AggregateIPs(fn, o.IPAggregation)
+	}
+	return fn
+}
+
+// RuleResolver implementa RouteResolver avaliando regras em ordem (primeira que casar).
+type RuleResolver struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	route   Route
+	path    string
+	glob    bool
+	methods map[string]bool
+}
+
+// NewRuleResolver compila as regras do arquivo.
+func NewRuleResolver(pf PolicyFile, opts RuleKeyOptions) (*RuleResolver, error) {
+	rr := &RuleResolver{rules: make([]compiledRule, 0, len(pf.Rules))}
+	for _, rule := range pf.Rules {
+		cr, err := compileRule(rule, opts)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		rr.rules = append(rr.rules, cr)
+	}
+	return rr, nil
+}
+
+// Route implementa RouteResolver.
+func (rr *RuleResolver) Route(r *http.Request) (Route, bool) {
+	if rr == nil {
+		return Route{}, false
+	}
+	for _, cr := range rr.rules {
+		if cr.matches(r) {
+			return cr.route, true
+		}
+	}
+	return Route{}, false
+}
+
+func (rr *RuleResolver) Len() int {
+	if rr == nil {
+		return 0
+	}
+	return len(rr.rules)
+}
+
+// Policy retorna a política da regra com esse nome.
+func (rr *RuleResolver) Policy(name string) (domain.Policy, bool) {
+	if rr == nil {
+		return domain.Policy{}, false
+	}
+	for _, cr := range rr.rules {
+		if cr.route.Name == name {
+			return cr.route.Policy, true
+		}
+	}
+	return domain.Policy{}, false
+}
+
+// HasBlock informa se alguma regra tem tempo de bloqueio (lockout).
+func (rr *RuleResolver) HasBlock() bool {
+	if rr == nil {
+		return false
+	}
+	for _, cr := range rr.rules {
+		if cr.route.Policy.BlockDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func compileRule(rule PolicyRule, opts RuleKeyOptions) (compiledRule, error) {
+	cr := compiledRule{path: rule.Path}
+	if cr.path == "" {
+		cr.path = "/"
+	}
+	if !strings.HasPrefix(cr.path, "/") {
+		return compiledRule{}, fmt.Errorf("path must start with /")
+	}
+	if strings.HasSuffix(cr.path, "/**") {
+		cr.path = strings.TrimSuffix(cr.path, "/**")
+	} else if strings.ContainsAny(cr.path, "*?[") {
+		if _, err := path.Match(cr.path, ""); err != nil {
+			return compiledRule{}, fmt.Errorf("invalid path glob: %w", err)
+		}
+		cr.glob = true
+	}
+
+	if len(rule.Methods) > 0 {
+		cr.methods = make(map[string]bool, len(rule.Methods))
+		for _, m := range rule.Methods {
+			cr.methods[strings.ToUpper(strings.TrimSpace(m))] = true
+		}
+	}
+
+	keyFn, err := ruleKeyFunc(rule.Key, opts)
+	if err != nil {
+		return compiledRule{}, err
+	}
+
+	if rule.RPS <= 0 {
+		return compiledRule{}, fmt.Errorf("rps must be > 0")
+	}
+	burst := rule.Burst
+	if burst == 0 {
+		burst = max(1, int(rule.RPS))
+	}
+	if burst < 0 {
+		return compiledRule{}, fmt.Errorf("burst must be > 0")
+	}
+	var block time.Duration
+	if rule.Block != "" {
+		block, err = time.ParseDuration(rule.Block)
+		if err != nil || block < 0 {
+			return compiledRule{}, fmt.Errorf("invalid block duration %q", rule.Block)
+		}
+	}
+	if rule.RejectStatus != 0 && (rule.RejectStatus < 400 || rule.RejectStatus > 599) {
+		return compiledRule{}, fmt.Errorf("reject_status must be a 4xx/5xx code")
+	}
+
+	cr.route = Route{
+		Name:         rule.Name,
+		Policy:       domain.Policy{Name: rule.Name, RPS: rule.RPS, Burst: burst, BlockDuration: block},
+		KeyFn:        keyFn,
+		RejectStatus: rule.RejectStatus,
+	}
+	return cr, nil
+}
+
+// ruleKeyFunc traduz a fonte de chave da regra: ip, token ou header:<Nome>.
+// token e header caem para o IP quando o header não vem na requisição. Sem
+// fonte, devolve nil: a regra usa a chave do Middleware (a identidade do
+// Identifier, se houver, ou Options.KeyFn).
+func ruleKeyFunc(src string, opts RuleKeyOptions) (KeyFunc, error) {
+	src = strings.TrimSpace(src)
+	switch {
+	case src == "":
+		return nil, nil
+	case strings.EqualFold(src, "ip"):
+		return opts.keyFunc(""), nil
+	case strings.EqualFold(src, "token"):
+		h := opts.TokenHeader
+		if h == "" {
+			h = "API_KEY"
+		}
+		return opts.keyFunc(h), nil
+	case strings.HasPrefix(strings.ToLower(src), "header:"):
+		h := strings.TrimSpace(src[len("header:"):])
+		if h == "" {
+			return nil, fmt.Errorf("key %q: header name is required", src)
+		}
+		return opts.keyFunc(h), nil
+	default:
+		return nil, fmt.Errorf("key %q: expected ip, token or header:<name>", src)
+	}
+}
+
+func (cr compiledRule) matches(r *http.Request) bool {
+	if cr.methods != nil && !cr.methods[r.Method] {
+		return false
+	}
+	p := cleanPath(r.URL.Path)
+	if cr.glob {
+		ok, _ := path.Match(cr.path, p)
+		return ok
+	}
+	if cr.path == "/" || cr.path == "" {
+		return true
+	}
+	// prefixo respeitando segmentos: /api casa /api e /api/x, mas não /apix
+	return p == cr.path || strings.HasPrefix(p, strings.TrimSuffix(cr.path, "/")+"/")
+}
+
+// cleanPath normaliza o caminho como o upstream fará ("//", "." e "..") para
+// que /x/../api/login não escape da regra de /api/login. A barra final é
+// mantida.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	c := path.Clean(p)
+	if strings.HasSuffix(p, "/") && c != "/" {
+		c += "/"
+	}
+	return c
+}
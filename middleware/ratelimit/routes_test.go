@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+)
+
+const testPolicyYAML = `
+rules:
+  - name: login
+    path: /api/login
+    methods: [post]
+    key: ip
+    rps: 0.01
+    burst: 1
+    block: 1m
+    reject_status: 403
+  - name: reports
+    path: /reports/*/pdf
+    key: header:X-Tenant
+    rps: 0.01
+    burst: 2
+  - name: api
+    path: /api/**
+    key: token
+    rps: 100
+    burst: 100
+`
+
+func TestParsePolicyFile_FirstMatchWins(t *testing.T) {
+	pf, err := ParsePolicyFile([]byte(testPolicyYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rr, err := NewRuleResolver(pf, RuleKeyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		method, path, want string
+	}{
+		{http.MethodPost, "/api/login", "login"},
+		{http.MethodGet, "/api/login", "api"},
+		{http.MethodGet, "/api", "api"},
+		{http.MethodGet, "/apix", ""},
+		{http.MethodGet, "/reports/42/pdf", "reports"},
+		{http.MethodGet, "/reports/42/csv", ""},
+		{http.MethodPost, "/x/../api/login", "login"},
+		{http.MethodPost, "//api/login", "login"},
+		{http.MethodPost, "/api/./login", "login"},
+		{http.MethodGet, "/api/../apix", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "http://example"+c.path, nil)
+		rt, ok := rr.Route(r)
+		if got := rt.Name; got != c.want || ok != (c.want != "") {
+			t.Fatalf("%s %s: expected rule %q, got %q (ok=%v)", c.method, c.path, c.want, got, ok)
+		}
+	}
+
+	rt, _ := rr.Route(httptest.NewRequest(http.MethodPost, "http://example/api/login", nil))
+	if rt.Policy.BlockDuration != time.Minute || rt.RejectStatus != http.StatusForbidden {
+		t.Fatalf("unexpected login route: %+v", rt)
+	}
+}
+
+func TestParsePolicyFile_AcceptsJSONAndRejectsInvalid(t *testing.T) {
+	if _, err := ParsePolicyFile([]byte(`{"rules": [{"name": "all", "rps": 5}]}`)); err != nil {
+		t.Fatalf("expected JSON to be accepted, got %v", err)
+	}
+
+	bad := []string{
+		`rules: [{rps: 1}]`,
+		`rules: [{name: a, rps: 0}]`,
+		`rules: [{name: a, rps: 1, key: cookie}]`,
+		`rules: [{name: a, rps: 1, path: "/x/[", key: ip}]`,
+		`rules: [{name: a, rps: 1, block: soon}]`,
+		`rules: [{name: a, rps: 1, reject_status: 200}]`,
+		`rules: [{name: a, rps: 1}, {name: a, rps: 2}]`,
+		`rules: [{name: "a:b", rps: 1}]`,
+	}
+	for _, b := range bad {
+		if _, err := ParsePolicyFile([]byte(b)); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestMiddleware_RoutesApplyPerRuleLimits(t *testing.T) {
+	pf, err := ParsePolicyFile([]byte(testPolicyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, err := NewRuleResolver(pf, RuleKeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := Middleware(Options{
+		Store:               infra.NewStore(0.01, 1),
+		Routes:              rr,
+		AddRateLimitHeaders: true,
+	})(next)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example"+path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/login"); w.Code != http.StatusOK {
+		t.Fatalf("expected first login 200, got %d", w.Code)
+	}
+	w := do(http.MethodPost, "/api/login")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected login rule reject status 403, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("expected Retry-After=60 from rule block, got %q", got)
+	}
+
+	// mesma chave (IP) em outra regra tem bucket próprio
+	for i := 0; i < 5; i++ {
+		w := do(http.MethodGet, "/api/items")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected api rule to allow, got %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-RPS"); got != "100" {
+			t.Fatalf("expected X-RateLimit-RPS=100, got %q", got)
+		}
+	}
+
+	// sem regra: limite padrão do Store (burst=1)
+	if w := do(http.MethodGet, "/other"); w.Code != http.StatusOK {
+		t.Fatalf("expected first unmatched 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/other"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected unmatched to use default limit and 429, got %d", w.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Nomes dos spans criados com Options.Tracer / ConcurrencyOptions.Tracer.
+const (
+	SpanDecision = "ratelimit.decision"
+	SpanAcquire  = "ratelimit.concurrency.acquire"
+)
+
+// Atributos dos spans. AttrKey é a chave do cliente (IP, token...): trate o
+// backend de traces com o mesmo cuidado dos logs de acesso.
+const (
+	AttrKey     = attribute.Key("ratelimit.key")
+	AttrPolicy  = attribute.Key("ratelimit.policy")
+	AttrLimit   = attribute.Key("ratelimit.limit")
+	AttrOutcome = attribute.Key("ratelimit.outcome")
+	AttrReason  = attribute.Key("ratelimit.reason")
+)
+
+// Valores de AttrOutcome e RequestInfo.Outcome além dos domain.Outcome*.
+const (
+	OutcomeAllowed  = "allowed"
+	OutcomeRejected = "rejected"
+)
+
+// startSpan abre um span filho do contexto da requisição; sem tracer devolve
+// um span que não faz nada.
+func startSpan(tracer trace.Tracer, r *http.Request, name string) trace.Span {
+	if tracer == nil {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := tracer.Start(r.Context(), name, trace.WithSpanKind(trace.SpanKindInternal))
+	return span
+}
+
+// endDecision fecha o span da decisão com o resultado (campos vazios ficam de
+// fora) e o anota no RequestInfo da requisição, se houver. O span não é pai do
+// handler seguinte, que continua no contexto da requisição.
+func endDecision(r *http.Request, span trace.Span, outcome, key, policy, limit, reason string) {
+	if info := RequestInfoFrom(r.Context()); info != nil {
+		info.Outcome, info.Key, info.Policy, info.Limit, info.Reason = outcome, key, policy, limit, reason
+	}
+	if span.IsRecording() {
+		span.SetAttributes(AttrOutcome.String(outcome))
+		for _, a := range []attribute.KeyValue{AttrKey.String(key), AttrPolicy.String(policy), AttrLimit.String(limit), AttrReason.String(reason)} {
+			if a.Value.AsString() != "" {
+				span.SetAttributes(a)
+			}
+		}
+	}
+	span.End()
+}
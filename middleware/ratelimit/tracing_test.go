@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"middleware-gateway/middleware/ratelimit/infra"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware_TracesDecisionAsChildSpan(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tracer := tp.Tracer("test")
+
+	var nextParent string
+	h := Middleware(Options{
+		Store:  infra.NewStore(0.01, 1),
+		Tracer: tracer,
+	})(ConcurrencyMiddleware(ConcurrencyOptions{Max: 1, Tracer: tracer})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextParent = trace.SpanContextFromContext(r.Context()).SpanID().String()
+	})))
+
+	call := func() (int, string) {
+		ctx, root := tracer.Start(context.Background(), "request")
+		defer root.End()
+		r := httptest.NewRequest(http.MethodGet, "http://example/", nil).WithContext(ctx)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code, root.SpanContext().SpanID().String()
+	}
+
+	_, rootID := call()
+	if nextParent != rootID {
+		t.Fatalf("expected next handler to stay in the request span")
+	}
+	if code, _ := call(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+
+	var decisions []sdktrace.ReadOnlySpan
+	acquires := 0
+	for _, s := range exp.GetSpans().Snapshots() {
+		switch s.Name() {
+		case SpanDecision:
+			if !s.Parent().IsValid() {
+				t.Fatalf("expected decision span to have the request as parent")
+			}
+			decisions = append(decisions, s)
+		case SpanAcquire:
+			acquires++
+		}
+	}
+	if len(decisions) != 2 || acquires != 1 {
+		t.Fatalf("expected 2 decision spans and 1 acquire span, got %d and %d", len(decisions), acquires)
+	}
+	want := map[attribute.Key]string{AttrOutcome: OutcomeRejected, AttrKey: "10.0.0.1", AttrPolicy: "default", AttrReason: ReasonRateLimited}
+	got := map[attribute.Key]string{}
+	for _, a := range decisions[1].Attributes() {
+		got[a.Key] = a.Value.AsString()
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("expected %s=%q, got %v", k, v, got)
+		}
+	}
+}